@@ -0,0 +1,316 @@
+// This file contains SendBatch and SendStream, which send many messages
+// with bounded concurrency and report a result for each of them.
+package postalclient
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// DefaultBatchConcurrency is the default number of messages SendBatch and
+// SendStream send at the same time.
+const DefaultBatchConcurrency = 4
+
+// BatchOption configures SendBatch and SendStream.
+type BatchOption func(*batchOptions)
+
+// batchOptions holds the settings collected from BatchOptions.
+type batchOptions struct {
+	concurrency int
+	onProgress  func(BatchProgress)
+
+	// total is the number of messages, if known.
+	total int
+}
+
+// WithBatchConcurrency sets the number of messages sent at the same time.
+// The default is DefaultBatchConcurrency. The client's RateLimiter, if
+// any, still paces the sends.
+func WithBatchConcurrency(n int) BatchOption {
+	return func(o *batchOptions) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+// WithBatchProgress sets a function called after every message, with the
+// counts so far. It is called from one goroutine at a time, in the order
+// the sends complete.
+func WithBatchProgress(fn func(BatchProgress)) BatchOption {
+	return func(o *batchOptions) {
+		o.onProgress = fn
+	}
+}
+
+// BatchResult is the outcome of sending one message of a batch.
+type BatchResult struct {
+	// Index is the position of the message in the input.
+	Index int
+
+	// Request is the message.
+	Request *models.SendMessageRequest
+
+	// Response is Postal's response, or nil if the send failed.
+	Response *models.SendMessageResponse
+
+	// Err is the error returned by SendMessageContext, or nil. It is one of
+	// the client's typed errors, e.g. a *SendError, or the context's error
+	// for messages not sent because the batch was cancelled.
+	Err error
+}
+
+// BatchProgress reports the progress of a batch after a message completes.
+type BatchProgress struct {
+	// Result is the result of the message that just completed.
+	Result BatchResult
+
+	// Completed is the number of messages completed so far.
+	Completed int
+
+	// Succeeded is the number of messages sent so far.
+	Succeeded int
+
+	// Failed is the number of messages that failed so far, other than
+	// those cancelled.
+	Failed int
+
+	// Canceled is the number of messages so far whose send was stopped
+	// because the batch's context was cancelled or its deadline expired.
+	Canceled int
+
+	// Total is the number of messages in the batch, or 0 for SendStream.
+	Total int
+}
+
+// BatchSummary sums up the results of a batch.
+type BatchSummary struct {
+	// Total is the number of messages in the batch.
+	Total int
+
+	// Succeeded is the number of messages Postal accepted.
+	Succeeded int
+
+	// Failed is the number of messages that failed, other than those
+	// cancelled.
+	Failed int
+
+	// Canceled is the number of messages not sent because the batch's
+	// context was cancelled or its deadline expired.
+	Canceled int
+
+	// Errors counts the failures by ErrorType, e.g. "NoRecipients".
+	Errors map[string]int
+
+	// Duration is how long the batch took.
+	Duration time.Duration
+}
+
+// BatchReport is returned by SendBatch.
+type BatchReport struct {
+	// Results holds a result for every message, in input order.
+	Results []BatchResult
+
+	// Summary sums up the results.
+	Summary BatchSummary
+}
+
+// SendBatch sends every message in reqs with SendMessageContext, a few at a
+// time, and returns a result for each. Failures of individual messages do
+// not stop the batch.
+//
+// If ctx is cancelled or its deadline expires, messages not yet sent are
+// given the context's error as their result, and SendBatch returns the
+// report along with ctx.Err().
+//
+// Example:
+//
+//	report, err := client.SendBatch(ctx, newsletters,
+//	    postalclient.WithBatchConcurrency(8),
+//	    postalclient.WithBatchProgress(func(p postalclient.BatchProgress) {
+//	        log.Printf("%d/%d sent", p.Completed, p.Total)
+//	    }),
+//	)
+//	for _, r := range report.Results {
+//	    if r.Err != nil {
+//	        log.Printf("message %d to %v failed: %v", r.Index, r.Request.To, r.Err)
+//	    }
+//	}
+func (c *Client) SendBatch(ctx context.Context, reqs []*models.SendMessageRequest, opts ...BatchOption) (*BatchReport, error) {
+	start := time.Now()
+	o := newBatchOptions(opts)
+	o.total = len(reqs)
+
+	results := make([]BatchResult, len(reqs))
+	done := make([]bool, len(reqs))
+	for r := range c.sendStream(ctx, slices.Values(reqs), o) {
+		results[r.Index] = r
+		done[r.Index] = true
+	}
+	for i, ok := range done {
+		if !ok {
+			results[i] = BatchResult{Index: i, Request: reqs[i], Err: ctx.Err()}
+		}
+	}
+
+	report := &BatchReport{Results: results, Summary: summarizeBatch(results)}
+	report.Summary.Duration = time.Since(start)
+	return report, ctx.Err()
+}
+
+// SendStream sends the messages produced by reqs with SendMessageContext, a
+// few at a time, and yields a result for each in the order the sends
+// complete. It suits inputs too large to hold in memory. Messages are
+// taken from reqs only as workers become free.
+//
+// Sending stops when ctx ends or the caller stops iterating, and the rest
+// of reqs is not consumed. Sends in progress when ctx ends are reported
+// with the context's error.
+//
+// reqs is consumed by a goroutine of its own, which stops as soon as reqs
+// yields or returns after sending has stopped. A goroutine cannot be
+// interrupted while reqs blocks, so a sequence that can block, such as one
+// reading from a channel, must return when ctx ends; otherwise the
+// goroutine is left waiting on it. A caller that stops iterating early
+// should cancel ctx as well. A channel can be used as the input with a
+// small adapter:
+//
+//	ctx, cancel := context.WithCancel(ctx)
+//	defer cancel()
+//	seq := func(yield func(*models.SendMessageRequest) bool) {
+//	    for {
+//	        select {
+//	        case req, ok := <-ch:
+//	            if !ok || !yield(req) {
+//	                return
+//	            }
+//	        case <-ctx.Done():
+//	            return
+//	        }
+//	    }
+//	}
+//	for r := range client.SendStream(ctx, seq) {
+//	    if r.Err != nil {
+//	        log.Printf("message %d failed: %v", r.Index, r.Err)
+//	    }
+//	}
+func (c *Client) SendStream(ctx context.Context, reqs iter.Seq[*models.SendMessageRequest], opts ...BatchOption) iter.Seq[BatchResult] {
+	return c.sendStream(ctx, reqs, newBatchOptions(opts))
+}
+
+// newBatchOptions applies opts to the defaults.
+func newBatchOptions(opts []BatchOption) *batchOptions {
+	o := &batchOptions{concurrency: DefaultBatchConcurrency}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// sendStream implements SendStream with the given options.
+func (c *Client) sendStream(parent context.Context, reqs iter.Seq[*models.SendMessageRequest], o *batchOptions) iter.Seq[BatchResult] {
+	return func(yield func(BatchResult) bool) {
+		ctx, cancel := context.WithCancel(parent)
+		defer cancel()
+
+		// Feed the messages to the workers until sending stops. The input
+		// cannot be interrupted, so if it blocks, this goroutine exits when
+		// it next yields or returns; see SendStream.
+		jobs := make(chan BatchResult)
+		go func() {
+			defer close(jobs)
+			i := 0
+			for req := range reqs {
+				if ctx.Err() != nil {
+					return
+				}
+				select {
+				case jobs <- BatchResult{Index: i, Request: req}:
+				case <-ctx.Done():
+					return
+				}
+				i++
+			}
+		}()
+
+		results := make(chan BatchResult)
+		var wg sync.WaitGroup
+		for w := 0; w < o.concurrency; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					var job BatchResult
+					var ok bool
+					select {
+					case job, ok = <-jobs:
+						if !ok {
+							return
+						}
+					case <-ctx.Done():
+						return
+					}
+					job.Response, job.Err = c.SendMessageContext(ctx, job.Request)
+					results <- job
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		progress := BatchProgress{Total: o.total}
+		for r := range results {
+			progress.Result = r
+			progress.Completed++
+			switch {
+			case r.Err == nil:
+				progress.Succeeded++
+			case canceled(r.Err):
+				progress.Canceled++
+			default:
+				progress.Failed++
+			}
+			if o.onProgress != nil {
+				o.onProgress(progress)
+			}
+			if !yield(r) {
+				// Stop the workers and wait for the sends in progress
+				cancel()
+				for range results {
+				}
+				return
+			}
+		}
+	}
+}
+
+// summarizeBatch counts the outcomes of results.
+func summarizeBatch(results []BatchResult) BatchSummary {
+	summary := BatchSummary{Total: len(results), Errors: make(map[string]int)}
+	for _, r := range results {
+		switch {
+		case r.Err == nil:
+			summary.Succeeded++
+		case canceled(r.Err):
+			summary.Canceled++
+			summary.Errors[ErrorType(r.Err)]++
+		default:
+			summary.Failed++
+			summary.Errors[ErrorType(r.Err)]++
+		}
+	}
+	return summary
+}
+
+// canceled reports whether err means a send was stopped by its context.
+func canceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
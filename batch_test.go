@@ -0,0 +1,279 @@
+package postalclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// batchTestServer is a test server that rejects messages to addresses
+// starting with "bad" and records the highest number of concurrent sends.
+type batchTestServer struct {
+	*httptest.Server
+	sends    atomic.Int32
+	inFlight atomic.Int32
+	maxSeen  atomic.Int32
+}
+
+// newBatchTestServer starts a batchTestServer whose sends take delay.
+func newBatchTestServer(t *testing.T, delay time.Duration) (*batchTestServer, *Client) {
+	t.Helper()
+	s := &batchTestServer{}
+	// Create a test server
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		for {
+			max := s.maxSeen.Load()
+			if n <= max || s.maxSeen.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(delay)
+
+		var req models.SendMessageRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if len(req.To) > 0 && strings.HasPrefix(req.To[0], "bad") {
+			_, _ = w.Write([]byte(`{"status":"error","time":0.1,"flags":{},"data":{"code":"UnauthenticatedFromAddress","message":"rejected"}}`))
+			return
+		}
+		id := s.sends.Add(1)
+		fmt.Fprintf(w, `{"status":"success","time":0.1,"flags":{},"data":{"message_id":%d,"token":"tok"}}`, id)
+	}))
+	t.Cleanup(s.Close)
+
+	client, err := New("test-api-key", WithBaseURL(s.URL+"/api/v1"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return s, client
+}
+
+// batchMessages returns n messages; those at the indexes in bad are
+// rejected by batchTestServer.
+func batchMessages(n int, bad ...int) []*models.SendMessageRequest {
+	reqs := make([]*models.SendMessageRequest, n)
+	for i := range reqs {
+		to := fmt.Sprintf("user%d@example.org", i)
+		for _, b := range bad {
+			if b == i {
+				to = fmt.Sprintf("bad%d@example.org", i)
+			}
+		}
+		reqs[i] = &models.SendMessageRequest{From: "a@example.com", To: []string{to}, PlainBody: "Hi"}
+	}
+	return reqs
+}
+
+func TestSendBatch(t *testing.T) {
+	server, client := newBatchTestServer(t, 10*time.Millisecond)
+	reqs := batchMessages(20, 3, 11)
+
+	var progress []BatchProgress
+	report, err := client.SendBatch(context.Background(), reqs,
+		WithBatchConcurrency(3),
+		WithBatchProgress(func(p BatchProgress) { progress = append(progress, p) }),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(report.Results) != 20 {
+		t.Fatalf("Expected 20 results, got %d", len(report.Results))
+	}
+	for i, r := range report.Results {
+		if r.Index != i || r.Request != reqs[i] {
+			t.Errorf("Expected result %d to be for request %d, got %d", i, i, r.Index)
+		}
+		switch i {
+		case 3, 11:
+			if !errors.Is(r.Err, ErrUnauthenticatedFromAddress) || r.Response != nil {
+				t.Errorf("Expected result %d to fail with ErrUnauthenticatedFromAddress, got %v", i, r.Err)
+			}
+		default:
+			if r.Err != nil || r.Response == nil || r.Response.MessageID == 0 {
+				t.Errorf("Expected result %d to succeed, got %v", i, r.Err)
+			}
+		}
+	}
+
+	s := report.Summary
+	if s.Total != 20 || s.Succeeded != 18 || s.Failed != 2 || s.Canceled != 0 || s.Errors["UnauthenticatedFromAddress"] != 2 || s.Duration <= 0 {
+		t.Errorf("Expected 18 successes and 2 failures, got %+v", s)
+	}
+
+	if got := server.maxSeen.Load(); got > 3 || got < 2 {
+		t.Errorf("Expected at most 3 concurrent sends, got %d", got)
+	}
+
+	if len(progress) != 20 {
+		t.Fatalf("Expected 20 progress reports, got %d", len(progress))
+	}
+	last := progress[19]
+	if last.Completed != 20 || last.Succeeded != 18 || last.Failed != 2 || last.Total != 20 {
+		t.Errorf("Expected final progress of 20/20, got %+v", last)
+	}
+}
+
+func TestSendBatchCancelled(t *testing.T) {
+	server, client := newBatchTestServer(t, 5*time.Millisecond)
+	reqs := batchMessages(50)
+
+	// Cancel after the fifth message
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var last BatchProgress
+	report, err := client.SendBatch(ctx, reqs,
+		WithBatchConcurrency(2),
+		WithBatchProgress(func(p BatchProgress) {
+			if p.Completed == 5 {
+				cancel()
+			}
+			last = p
+		}),
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	s := report.Summary
+	if s.Total != 50 || s.Succeeded < 5 || s.Succeeded+s.Canceled != 50 || s.Failed != 0 {
+		t.Errorf("Expected the rest of the batch to be canceled, got %+v", s)
+	}
+
+	// Progress counts cancelled sends like the summary does
+	if last.Failed != 0 || last.Succeeded+last.Canceled != last.Completed || last.Canceled > s.Canceled {
+		t.Errorf("Expected the progress to count cancelled sends apart from failures, got %+v", last)
+	}
+
+	if int(server.sends.Load()) >= 50 {
+		t.Errorf("Expected sending to stop early, got %d sends", server.sends.Load())
+	}
+
+	for _, r := range report.Results {
+		if r.Err != nil && !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("Expected results to succeed or be canceled, got %v", r.Err)
+		}
+	}
+}
+
+func TestSendStream(t *testing.T) {
+	_, client := newBatchTestServer(t, 0)
+
+	// Use a channel as the input
+	ch := make(chan *models.SendMessageRequest)
+	go func() {
+		defer close(ch)
+		for _, req := range batchMessages(10, 4) {
+			ch <- req
+		}
+	}()
+	seq := func(yield func(*models.SendMessageRequest) bool) {
+		for req := range ch {
+			if !yield(req) {
+				return
+			}
+		}
+	}
+
+	seen := make(map[int]bool)
+	failed := 0
+	for r := range client.SendStream(context.Background(), seq) {
+		seen[r.Index] = true
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if len(seen) != 10 || failed != 1 {
+		t.Errorf("Expected 10 results with 1 failure, got %d and %d", len(seen), failed)
+	}
+}
+
+func TestSendStreamStopEarly(t *testing.T) {
+	_, client := newBatchTestServer(t, 5*time.Millisecond)
+
+	// An endless input
+	var produced atomic.Int32
+	seq := func(yield func(*models.SendMessageRequest) bool) {
+		for {
+			produced.Add(1)
+			if !yield(batchMessages(1)[0]) {
+				return
+			}
+		}
+	}
+
+	count := 0
+	for range client.SendStream(context.Background(), seq, WithBatchConcurrency(2)) {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+
+	if count != 3 {
+		t.Errorf("Expected 3 results, got %d", count)
+	}
+
+	// The input is no longer consumed once the loop is over
+	n := produced.Load()
+	time.Sleep(20 * time.Millisecond)
+	if produced.Load() != n || n > 6 {
+		t.Errorf("Expected the input to stop after the loop, got %d then %d messages", n, produced.Load())
+	}
+}
+
+func TestSendStreamBlockingInput(t *testing.T) {
+	_, client := newBatchTestServer(t, 0)
+
+	// A channel that is never closed, read by a sequence that ends with ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan *models.SendMessageRequest)
+	inputDone := make(chan struct{})
+	seq := func(yield func(*models.SendMessageRequest) bool) {
+		defer close(inputDone)
+		for {
+			select {
+			case req := <-ch:
+				if !yield(req) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	go func() {
+		for _, req := range batchMessages(2) {
+			ch <- req
+		}
+	}()
+
+	count := 0
+	for range client.SendStream(ctx, seq) {
+		count++
+		if count == 2 {
+			cancel()
+		}
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 results, got %d", count)
+	}
+
+	// The goroutine reading the input is gone
+	select {
+	case <-inputDone:
+	case <-time.After(time.Second):
+		t.Error("Expected the input to be released once ctx ended")
+	}
+}
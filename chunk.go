@@ -0,0 +1,145 @@
+// This file contains SendMessageChunked, which works around Postal's limit
+// on recipients per message by splitting BCC recipients over several
+// messages.
+package postalclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// MaxRecipientsPerField is the largest number of addresses Postal accepts
+// in each of the To, CC, and BCC fields of a message.
+const MaxRecipientsPerField = models.MaxRecipientsPerField
+
+// SendChunk is one of the messages sent by SendMessageChunked.
+type SendChunk struct {
+	// BCC lists the BCC recipients of the message.
+	BCC []string
+
+	// Response is Postal's response for the message.
+	Response *models.SendMessageResponse
+}
+
+// ChunkedSendResponse is the aggregate response of SendMessageChunked.
+type ChunkedSendResponse struct {
+	// Chunks holds the messages sent, in order. The first carries the To
+	// and CC recipients.
+	Chunks []SendChunk
+
+	// Unsent lists the BCC recipients of the chunks that were not sent
+	// because an earlier chunk failed. It is empty on success.
+	Unsent []string
+}
+
+// MessageIDs returns the IDs of the messages sent, chunk by chunk. Postal
+// creates a message for each recipient, so a chunk contributes the IDs
+// listed in its response's Messages, or its MessageID if there are none.
+func (r *ChunkedSendResponse) MessageIDs() []int {
+	var ids []int
+	for _, c := range r.Chunks {
+		ids = append(ids, c.Response.MessageIDs()...)
+	}
+	return ids
+}
+
+// Tokens returns the tokens of the messages sent, in the order of
+// MessageIDs.
+func (r *ChunkedSendResponse) Tokens() []string {
+	var tokens []string
+	for _, c := range r.Chunks {
+		tokens = append(tokens, c.Response.Tokens()...)
+	}
+	return tokens
+}
+
+// SendMessageChunked sends req like SendMessageContext, but splits more
+// than MaxRecipientsPerField BCC recipients over several messages. The
+// first message goes to the To and CC recipients and the first chunk of
+// BCC recipients; the others go to the remaining BCC recipients only, so
+// visible recipients receive the message once and see the same headers.
+// Recipients of the later messages see no To or CC header.
+//
+// Splitting To or CC recipients would change what they see, so a request
+// with more than MaxRecipientsPerField of either is refused with a nil
+// response and an error matching ErrTooManyToAddresses or
+// ErrTooManyCCAddresses, without sending anything. A nil request is
+// refused with an *InvalidRequestError.
+//
+// Chunks are sent one after another. If one fails, SendMessageChunked
+// stops and returns the chunks sent so far, with the recipients left in
+// Unsent, along with the error. If ctx carries an idempotency key (see
+// WithIdempotencyKey), each chunk is sent with the key followed by "/" and
+// its index.
+//
+// Example:
+//
+//	resp, err := client.SendMessageChunked(ctx, &models.SendMessageRequest{
+//	    From:      "news@yourdomain.com",
+//	    To:        []string{"news@yourdomain.com"},
+//	    BCC:       subscribers, // any number
+//	    Subject:   "Our monthly update",
+//	    PlainBody: body,
+//	})
+//	if resp != nil {
+//	    fmt.Println(resp.MessageIDs())
+//	}
+//	if err != nil {
+//	    log.Printf("error sending: %v", err)
+//	}
+func (c *Client) SendMessageChunked(ctx context.Context, req *models.SendMessageRequest) (*ChunkedSendResponse, error) {
+	if req == nil {
+		return nil, &InvalidRequestError{Errors: models.ValidationErrors{{Field: "request", Message: "is nil"}}}
+	}
+	if len(req.To) > MaxRecipientsPerField {
+		return nil, fmt.Errorf("%w: %d To recipients exceed the limit of %d and cannot be split without changing what they see",
+			ErrTooManyToAddresses, len(req.To), MaxRecipientsPerField)
+	}
+	if len(req.CC) > MaxRecipientsPerField {
+		return nil, fmt.Errorf("%w: %d CC recipients exceed the limit of %d and cannot be split without changing what they see",
+			ErrTooManyCCAddresses, len(req.CC), MaxRecipientsPerField)
+	}
+
+	chunks := chunkAddresses(req.BCC, MaxRecipientsPerField)
+	key := IdempotencyKeyFromContext(ctx)
+	resp := &ChunkedSendResponse{}
+	for i, bcc := range chunks {
+		chunk := *req
+		chunk.BCC = bcc
+		if i > 0 {
+			chunk.To = nil
+			chunk.CC = nil
+		}
+
+		chunkCtx := ctx
+		if key != "" && len(chunks) > 1 {
+			chunkCtx = WithIdempotencyKey(ctx, fmt.Sprintf("%s/%d", key, i))
+		}
+		sent, err := c.SendMessageContext(chunkCtx, &chunk)
+		if err != nil {
+			for _, rest := range chunks[i:] {
+				resp.Unsent = append(resp.Unsent, rest...)
+			}
+			return resp, fmt.Errorf("error sending chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+		resp.Chunks = append(resp.Chunks, SendChunk{BCC: bcc, Response: sent})
+	}
+	return resp, nil
+}
+
+// chunkAddresses splits addresses into chunks of at most size. It always
+// returns at least one chunk, which is nil for no addresses.
+func chunkAddresses(addresses []string, size int) [][]string {
+	if len(addresses) <= size {
+		return [][]string{addresses}
+	}
+	var chunks [][]string
+	for len(addresses) > 0 {
+		n := min(size, len(addresses))
+		chunks = append(chunks, addresses[:n:n])
+		addresses = addresses[n:]
+	}
+	return chunks
+}
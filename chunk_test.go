@@ -0,0 +1,241 @@
+package postalclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// chunkTestServer records the messages it receives and fails the request
+// numbered failAt (counting from 1), if set. If perRecipient is set, it
+// answers with a message for every recipient, numbered from 100 on.
+type chunkTestServer struct {
+	*httptest.Server
+	mu           sync.Mutex
+	messages     []models.SendMessageRequest
+	keys         []string
+	failAt       int
+	perRecipient bool
+	next         int
+}
+
+// newChunkTestServer starts a chunkTestServer and returns a client for it.
+func newChunkTestServer(t *testing.T, opts ...Option) (*chunkTestServer, *Client) {
+	t.Helper()
+	s := &chunkTestServer{}
+	// Create a test server
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.SendMessageRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.messages = append(s.messages, req)
+		s.keys = append(s.keys, r.Header.Get(IdempotencyKeyHeader))
+		n := len(s.messages)
+		if n == s.failAt {
+			_, _ = w.Write([]byte(`{"status":"error","time":0.1,"flags":{},"data":{"code":"NoRecipients","message":"bad"}}`))
+			return
+		}
+		if s.perRecipient {
+			resp := models.SendMessageResponse{MessageID: n, Token: fmt.Sprintf("tok%d", n), Messages: make(map[string]models.RecipientMessage)}
+			for _, rcpt := range slices.Concat(req.To, req.CC, req.BCC) {
+				s.next++
+				resp.Messages[rcpt] = models.RecipientMessage{ID: 100 + s.next, Token: fmt.Sprintf("rcpt%d", 100+s.next)}
+			}
+			data, _ := json.Marshal(resp)
+			fmt.Fprintf(w, `{"status":"success","time":0.1,"flags":{},"data":%s}`, data)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","time":0.1,"flags":{},"data":{"message_id":%d,"token":"tok%d"}}`, n, n)
+	}))
+	t.Cleanup(s.Close)
+
+	client, err := New("test-api-key", append([]Option{WithBaseURL(s.URL + "/api/v1")}, opts...)...)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return s, client
+}
+
+// addresses returns n distinct addresses with the given prefix.
+func addresses(prefix string, n int) []string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = fmt.Sprintf("%s%d@example.org", prefix, i)
+	}
+	return list
+}
+
+func TestSendMessageChunked(t *testing.T) {
+	server, client := newChunkTestServer(t)
+	req := &models.SendMessageRequest{
+		From:      "news@example.com",
+		To:        []string{"news@example.com"},
+		CC:        []string{"archive@example.com"},
+		BCC:       addresses("sub", 120),
+		Subject:   "Update",
+		PlainBody: "Hi",
+	}
+
+	resp, err := client.SendMessageChunked(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(server.messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(server.messages))
+	}
+
+	// Only the first message goes to the visible recipients
+	first := server.messages[0]
+	if len(first.To) != 1 || len(first.CC) != 1 || len(first.BCC) != 50 {
+		t.Errorf("Expected the first message to have To, CC, and 50 BCC, got %d/%d/%d", len(first.To), len(first.CC), len(first.BCC))
+	}
+	seen := make(map[string]bool)
+	for i, m := range server.messages {
+		if i > 0 && (len(m.To) != 0 || len(m.CC) != 0) {
+			t.Errorf("Expected message %d to have no To or CC, got %v %v", i, m.To, m.CC)
+		}
+		if len(m.BCC) > MaxRecipientsPerField || m.Subject != "Update" {
+			t.Errorf("Expected message %d to have at most 50 BCC and the same content, got %d %q", i, len(m.BCC), m.Subject)
+		}
+		for _, a := range m.BCC {
+			seen[a] = true
+		}
+	}
+	if len(seen) != 120 {
+		t.Errorf("Expected every BCC recipient once, got %d", len(seen))
+	}
+
+	if ids := resp.MessageIDs(); fmt.Sprint(ids) != "[1 2 3]" {
+		t.Errorf("Expected message IDs [1 2 3], got %v", ids)
+	}
+
+	if tokens := resp.Tokens(); fmt.Sprint(tokens) != "[tok1 tok2 tok3]" {
+		t.Errorf("Expected tokens [tok1 tok2 tok3], got %v", tokens)
+	}
+
+	if len(resp.Chunks[2].BCC) != 20 || len(resp.Unsent) != 0 {
+		t.Errorf("Expected a last chunk of 20 and nothing unsent, got %d and %d", len(resp.Chunks[2].BCC), len(resp.Unsent))
+	}
+
+	if len(req.BCC) != 120 || len(req.To) != 1 {
+		t.Error("Expected the caller's request to be unchanged")
+	}
+}
+
+func TestSendMessageChunkedRecipientMessages(t *testing.T) {
+	server, client := newChunkTestServer(t)
+	server.perRecipient = true
+
+	resp, err := client.SendMessageChunked(context.Background(), &models.SendMessageRequest{
+		From: "a@example.com", To: []string{"to@example.org"}, BCC: addresses("bcc", 119), PlainBody: "Hi",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Every recipient's message is listed, not just one per chunk
+	ids, tokens := resp.MessageIDs(), resp.Tokens()
+	if len(resp.Chunks) != 3 || len(ids) != 120 || len(tokens) != 120 {
+		t.Fatalf("Expected 120 IDs and tokens over 3 chunks, got %d and %d over %d", len(ids), len(tokens), len(resp.Chunks))
+	}
+	for i := range ids {
+		if ids[i] != 101+i || tokens[i] != fmt.Sprintf("rcpt%d", 101+i) {
+			t.Errorf("Expected message %d with token rcpt%d at %d, got %d and %s", 101+i, 101+i, i, ids[i], tokens[i])
+			break
+		}
+	}
+}
+
+func TestSendMessageChunkedNilRequest(t *testing.T) {
+	server, client := newChunkTestServer(t)
+	resp, err := client.SendMessageChunked(context.Background(), nil)
+	var invalid *InvalidRequestError
+	if resp != nil || !errors.As(err, &invalid) {
+		t.Errorf("Expected an *InvalidRequestError, got %v", err)
+	}
+	if len(server.messages) != 0 {
+		t.Errorf("Expected nothing to be sent, got %d messages", len(server.messages))
+	}
+}
+
+func TestSendMessageChunkedSmall(t *testing.T) {
+	server, client := newChunkTestServer(t)
+	resp, err := client.SendMessageChunked(context.Background(), &models.SendMessageRequest{
+		From: "a@example.com", To: addresses("to", 50), BCC: addresses("bcc", 50), PlainBody: "Hi",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(server.messages) != 1 || len(resp.Chunks) != 1 {
+		t.Errorf("Expected a single message, got %d", len(server.messages))
+	}
+}
+
+func TestSendMessageChunkedRefusesVisibleSplit(t *testing.T) {
+	server, client := newChunkTestServer(t)
+	_, err := client.SendMessageChunked(context.Background(), &models.SendMessageRequest{
+		From: "a@example.com", To: addresses("to", 51), PlainBody: "Hi",
+	})
+	if !errors.Is(err, ErrTooManyToAddresses) {
+		t.Errorf("Expected ErrTooManyToAddresses, got %v", err)
+	}
+
+	_, err = client.SendMessageChunked(context.Background(), &models.SendMessageRequest{
+		From: "a@example.com", CC: addresses("cc", 51), BCC: addresses("bcc", 10), PlainBody: "Hi",
+	})
+	if !errors.Is(err, ErrTooManyCCAddresses) {
+		t.Errorf("Expected ErrTooManyCCAddresses, got %v", err)
+	}
+
+	if len(server.messages) != 0 {
+		t.Errorf("Expected nothing to be sent, got %d messages", len(server.messages))
+	}
+}
+
+func TestSendMessageChunkedPartialFailure(t *testing.T) {
+	server, client := newChunkTestServer(t)
+	server.failAt = 2
+
+	resp, err := client.SendMessageChunked(context.Background(), &models.SendMessageRequest{
+		From: "a@example.com", BCC: addresses("bcc", 130), PlainBody: "Hi",
+	})
+	if !errors.Is(err, ErrNoRecipients) {
+		t.Fatalf("Expected ErrNoRecipients, got %v", err)
+	}
+
+	if len(resp.Chunks) != 1 || len(resp.Unsent) != 80 {
+		t.Errorf("Expected 1 chunk sent and 80 recipients unsent, got %d and %d", len(resp.Chunks), len(resp.Unsent))
+	}
+
+	if len(server.messages) != 2 {
+		t.Errorf("Expected sending to stop after the failure, got %d messages", len(server.messages))
+	}
+}
+
+func TestSendMessageChunkedIdempotencyKeys(t *testing.T) {
+	server, client := newChunkTestServer(t, WithMiddleware(IdempotencyMiddleware(IdempotencyConfig{})))
+	ctx := WithIdempotencyKey(context.Background(), "news-1")
+	req := &models.SendMessageRequest{From: "a@example.com", BCC: addresses("bcc", 60), PlainBody: "Hi"}
+
+	if _, err := client.SendMessageChunked(ctx, req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := client.SendMessageChunked(ctx, req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if fmt.Sprint(server.keys) != "[news-1/0 news-1/1]" {
+		t.Errorf("Expected each chunk to be sent once with its own key, got %v", server.keys)
+	}
+}
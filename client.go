@@ -6,7 +6,12 @@
 //
 // Basic usage:
 //
-//	client := postalclient.NewClient("your-api-key")
+//	client, err := postalclient.New("your-api-key",
+//	    postalclient.WithBaseURL("https://postal.yourdomain.com/api/v1"),
+//	)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
 //
 //	// Send a message
 //	req := &models.SendMessageRequest{
@@ -20,9 +25,11 @@ package postalclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -54,6 +61,48 @@ type Client struct {
 	// This can be customized to add features like request tracing,
 	// custom transport options, or different timeout values.
 	HTTPClient *http.Client
+
+	// RetryPolicy controls how failed requests are retried.
+	// If nil, every request is attempted exactly once.
+	RetryPolicy *RetryPolicy
+
+	// RateLimiter paces requests on the client side and slows down when
+	// the server throttles them. If nil, requests are not limited.
+	RateLimiter *RateLimiter
+
+	// UserAgent is sent as the User-Agent header of every request.
+	// If empty, the HTTP client's default is used.
+	UserAgent string
+
+	// Header holds extra headers sent with every request. The headers the
+	// client sets itself, such as X-Server-API-Key, take precedence.
+	Header http.Header
+
+	// Logger receives a debug record of every call the client makes, and
+	// of every attempt of calls that were retried. If nil, nothing is
+	// logged.
+	Logger *slog.Logger
+
+	// LogOptions controls how addresses, subjects, and bodies appear in
+	// the Logger's records. The zero value leaves them out.
+	LogOptions LogOptions
+
+	// ValidateRequests makes SendMessage and SendRaw check requests with
+	// their Validate method before sending them. Invalid requests fail
+	// with an *InvalidRequestError without a round trip. The check runs
+	// inside the Middleware, so they see such failures.
+	ValidateRequests bool
+
+	// GeneratePlainText makes SendMessage fill in the plain text body of
+	// messages that only have an HTML body, using htmltext.Convert. The
+	// caller's request is not modified. The text is added inside the
+	// Middleware, whose Call.Request holds it once the next handler
+	// returns.
+	GeneratePlainText bool
+
+	// Middleware wraps every API operation, in order: the first middleware
+	// is the outermost. See Middleware.
+	Middleware []Middleware
 }
 
 // NewClient creates a new Postal API client with the given API key.
@@ -73,6 +122,9 @@ func NewClient(apiKey string) *Client {
 // NewClientWithOptions creates a new Postal API client with the given options.
 // This allows for customization of the base URL and timeout.
 //
+// Deprecated: Use New with WithBaseURL and WithTimeout, which also
+// validates the base URL.
+//
 // Example:
 //
 //	client := postalclient.NewClientWithOptions(
@@ -109,6 +161,10 @@ type Response struct {
 
 // Error represents an error response from the Postal API.
 // It implements the error interface for easy error handling.
+//
+// Errors with a known Postal error code unwrap to a typed error
+// (*SendError, *AuthError, *NotFoundError, or *ParameterError) and match
+// the corresponding sentinel error with errors.Is. See errors.go.
 type Error struct {
 	// Status indicates the result of the request, typically "error" or "parameter-error".
 	Status string `json:"status"`
@@ -123,10 +179,22 @@ type Error struct {
 	Data json.RawMessage `json:"data"`
 
 	// ErrorCode is a machine-readable code identifying the error.
+	// If the response has no top-level error code, it is taken from
+	// the "code" field of Data.
 	ErrorCode string `json:"error_code,omitempty"`
 
 	// Message is a human-readable description of the error.
+	// If the response has no top-level message, it is taken from
+	// the "message" field of Data.
 	Message string `json:"message,omitempty"`
+
+	// Details holds the structured contents of Data.
+	// It is not part of the JSON payload.
+	Details ErrorDetails `json:"-"`
+
+	// StatusCode is the HTTP status code of the response that carried
+	// the error. It is not part of the JSON payload.
+	StatusCode int `json:"-"`
 }
 
 // Error returns a string representation of the error.
@@ -136,28 +204,142 @@ func (e *Error) Error() string {
 }
 
 // do performs an HTTP request and returns the response.
-// It handles the details of creating the request, setting headers,
-// performing the request, and parsing the response.
+// It is equivalent to doContext with context.Background().
 //
 // This is an internal method used by other client methods.
 func (c *Client) do(method, path string, body interface{}) (*Response, error) {
+	return c.doContext(context.Background(), method, path, body)
+}
+
+// doContext performs an HTTP request bound to ctx and returns the response.
+// It handles the details of creating the request, setting headers,
+// performing the request, and parsing the response. If ctx is cancelled or
+// its deadline expires while the request is in flight, the request is aborted
+// and the returned error wraps ctx.Err().
+//
+// Failed attempts are retried according to the client's RetryPolicy. Every
+// attempt first waits for the client's RateLimiter, if any.
+//
+// This is an internal method used by other client methods.
+func (c *Client) doContext(ctx context.Context, method, path string, body interface{}) (*Response, error) {
+	return c.doCall(ctx, &Call{Method: method, Path: path, Request: body})
+}
+
+// doCall performs the HTTP requests for call, as described for doContext,
+// and records their outcome in call's StatusCode, Envelope, Attempts, and
+// RateLimitWait fields. Headers in call.Header are added to every attempt.
+//
+// This is an internal method used by doContext and the innermost Handler.
+func (c *Client) doCall(ctx context.Context, call *Call) (*Response, error) {
+	method, path := call.Method, call.Path
+
 	// Create the request URL by combining the base URL and path
 	url := fmt.Sprintf("%s%s", c.BaseURL, path)
 
-	// Marshal the body to JSON if it's not nil
-	var bodyReader io.Reader
-	if body != nil {
-		bodyBytes, err := json.Marshal(body)
+	// Marshal the body to JSON if it's not nil. The bytes are kept so the
+	// body can be replayed on every attempt.
+	var bodyBytes []byte
+	if call.Request != nil {
+		var err error
+		bodyBytes, err = json.Marshal(call.Request)
 		if err != nil {
 			return nil, fmt.Errorf("error marshaling request body: %w", err)
 		}
-		bodyReader = bytes.NewReader(bodyBytes)
 	}
 
-	// Create the HTTP request with the specified method, URL, and body
-	req, err := http.NewRequest(method, url, bodyReader)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+	policy := c.RetryPolicy
+	maxAttempts := policy.maxAttempts()
+	class := endpointClass(path)
+	for attempt := 1; ; attempt++ {
+		// Wait for the rate limiter before each attempt
+		waited, err := c.RateLimiter.Wait(ctx, class)
+		call.RateLimitWait += waited
+		if err != nil {
+			return nil, fmt.Errorf("error waiting for rate limiter: %w", err)
+		}
+
+		// Create the HTTP request with the specified method, URL, and body
+		var bodyReader io.Reader
+		if bodyBytes != nil {
+			bodyReader = bytes.NewReader(bodyBytes)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+		for name, values := range call.Header {
+			req.Header[name] = append([]string(nil), values...)
+		}
+
+		start := time.Now()
+		result := c.roundTrip(req)
+		c.RateLimiter.observe(class, result.statusCode, result.retryAfter, result.err)
+		c.dumpAttempt(ctx, call, attempt, req, bodyBytes, result)
+		call.Attempts = attempt
+		call.StatusCode = result.statusCode
+		call.Envelope = result.resp
+
+		info := RetryAttempt{
+			Attempt:    attempt,
+			Method:     method,
+			Path:       path,
+			StatusCode: result.statusCode,
+			Err:        result.err,
+			Duration:   time.Since(start),
+		}
+
+		// Decide whether another attempt should be made
+		if result.err != nil && attempt < maxAttempts && policy.retryable(ctx, info) {
+			info.Retry = true
+			info.Delay = policy.backoff(attempt, result.retryAfter)
+		}
+		if policy != nil && policy.OnAttempt != nil {
+			policy.OnAttempt(info)
+		}
+		c.logAttempt(ctx, info)
+		if !info.Retry {
+			return result.resp, result.err
+		}
+
+		// Wait before retrying, giving up early if the context ends
+		if err := sleepContext(ctx, info.Delay); err != nil {
+			return nil, fmt.Errorf("error performing request: %w (last attempt: %w)", err, result.err)
+		}
+	}
+}
+
+// attemptResult holds the outcome of a single HTTP round trip.
+type attemptResult struct {
+	// resp is the parsed response, or nil if the attempt failed.
+	resp *Response
+
+	// statusCode is the HTTP status code, or 0 if no response was received.
+	statusCode int
+
+	// body is the response body, or nil if it could not be read.
+	body []byte
+
+	// retryAfter is the delay requested by the server's Retry-After header.
+	retryAfter time.Duration
+
+	// err is the error returned by the attempt, or nil if it succeeded.
+	err error
+}
+
+// roundTrip sets the required headers on req, performs it, and parses the
+// response body into either a Response or an Error.
+//
+// This is an internal method used by doContext.
+func (c *Client) roundTrip(req *http.Request) attemptResult {
+	// Set the default headers first so the required ones win. Headers
+	// already set for the call take precedence over the defaults.
+	for name, values := range c.Header {
+		if _, ok := req.Header[name]; !ok {
+			req.Header[name] = append([]string(nil), values...)
+		}
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
 	}
 
 	// Set required headers for the Postal API
@@ -168,41 +350,54 @@ func (c *Client) do(method, path string, body interface{}) (*Response, error) {
 	// Perform the request using the client's HTTP client
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error performing request: %w", err)
+		return attemptResult{err: &TransportError{Method: req.Method, URL: req.URL.String(), Err: err}}
 	}
 	defer resp.Body.Close()
 
+	result := attemptResult{
+		statusCode: resp.StatusCode,
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+
 	// Read the entire response body
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		result.err = &TransportError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode, Err: err}
+		return result
 	}
+	result.body = respBody
 
 	// Check if the HTTP status code indicates an error
 	if resp.StatusCode != http.StatusOK {
-		var apiError Error
-		if err := json.Unmarshal(respBody, &apiError); err != nil {
-			return nil, fmt.Errorf("error unmarshaling error response: %w", err)
-		}
-		return nil, &apiError
+		result.err = decodeAPIError(respBody, resp.StatusCode)
+		return result
 	}
 
 	// Unmarshal the response body into a Response struct
 	var apiResp Response
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %w", err)
+		result.err = &DecodeError{What: "response", StatusCode: resp.StatusCode, Body: respBody, Err: err}
+		return result
 	}
 
 	// Check if the API response status indicates an error
 	if apiResp.Status != "success" {
-		var apiError Error
-		if err := json.Unmarshal(respBody, &apiError); err != nil {
-			return nil, fmt.Errorf("error unmarshaling error response: %w", err)
-		}
-		return nil, &apiError
+		result.err = decodeAPIError(respBody, resp.StatusCode)
+		return result
 	}
 
-	return &apiResp, nil
+	result.resp = &apiResp
+	return result
+}
+
+// decodeAPIError decodes an error response body into an *Error, or returns
+// a *DecodeError if the body is not a valid error response.
+func decodeAPIError(body []byte, statusCode int) error {
+	apiError, err := newAPIError(body, statusCode)
+	if err != nil {
+		return &DecodeError{What: "error response", StatusCode: statusCode, Body: body, Err: err}
+	}
+	return apiError
 }
 
 // post performs a POST request to the given path with the given body.
@@ -210,5 +405,13 @@ func (c *Client) do(method, path string, body interface{}) (*Response, error) {
 //
 // This is an internal method used by other client methods.
 func (c *Client) post(path string, body interface{}) (*Response, error) {
-	return c.do(http.MethodPost, path, body)
+	return c.postContext(context.Background(), path, body)
+}
+
+// postContext performs a POST request bound to ctx to the given path with
+// the given body. It's a convenience wrapper around the doContext method.
+//
+// This is an internal method used by other client methods.
+func (c *Client) postContext(ctx context.Context, path string, body interface{}) (*Response, error) {
+	return c.doContext(ctx, http.MethodPost, path, body)
 }
@@ -1,7 +1,9 @@
 package postalclient
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -389,3 +391,61 @@ func TestClientDoHTTPErrorUnmarshalError(t *testing.T) {
 		t.Errorf("Expected error message to contain 'error unmarshaling error response', got '%s'", err.Error())
 	}
 }
+
+func TestClientDoContextCancelled(t *testing.T) {
+	// Create a test server that blocks until the client goes away
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// Create client
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+
+	// Cancel the context shortly after the request is sent
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Make request
+	start := time.Now()
+	_, err := client.postContext(ctx, "/test", nil)
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	// Check that the context error is preserved
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error to wrap context.DeadlineExceeded, got '%v'", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected request to be aborted promptly, took %s", elapsed)
+	}
+}
+
+func TestClientDoContextAlreadyCancelled(t *testing.T) {
+	// Create a test server that must never be reached
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to reach the server")
+	}))
+	defer server.Close()
+
+	// Create client
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+
+	// Make request with a cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.postContext(ctx, "/test", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error to wrap context.Canceled, got '%v'", err)
+	}
+}
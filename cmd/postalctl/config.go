@@ -0,0 +1,193 @@
+// This file contains the global flags and the loading of settings from
+// flags, environment variables, and the profile file.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables read by postalctl.
+const (
+	envURL     = "POSTAL_URL"
+	envAPIKey  = "POSTAL_API_KEY"
+	envProfile = "POSTAL_PROFILE"
+	envConfig  = "POSTALCTL_CONFIG"
+)
+
+// defaultProfile is the profile used when none is selected.
+const defaultProfile = "default"
+
+// globalFlags are the flags accepted by every command.
+type globalFlags struct {
+	config  string
+	profile string
+	url     string
+	output  string
+	timeout time.Duration
+}
+
+// register adds the global flags to fs. The current values are used as
+// defaults, so flags parsed before the command name are kept.
+func (g *globalFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.config, "config", g.config, "path to the config file (default $"+envConfig+" or ~/.config/postalctl/config.yaml)")
+	fs.StringVar(&g.profile, "profile", g.profile, "profile to use from the config file (default $"+envProfile+")")
+	fs.StringVar(&g.url, "url", g.url, "Postal API base URL, e.g. https://postal.example.com/api/v1 (default $"+envURL+")")
+	fs.StringVar(&g.output, "o", g.output, "output format: table or json")
+	fs.DurationVar(&g.timeout, "timeout", g.timeout, "timeout for each API request (default 30s)")
+}
+
+// configFile is the format of the profile file.
+type configFile struct {
+	DefaultProfile string              `yaml:"default_profile"`
+	Profiles       map[string]*profile `yaml:"profiles"`
+}
+
+// profile holds the settings for one Postal server.
+type profile struct {
+	// URL is the API base URL.
+	URL string `yaml:"url"`
+
+	// APIKey is the server API key. Prefer APIKeyEnv, which keeps the key
+	// out of the file.
+	APIKey string `yaml:"api_key"`
+
+	// APIKeyEnv names an environment variable holding the API key.
+	APIKeyEnv string `yaml:"api_key_env"`
+
+	// Output is the default output format.
+	Output string `yaml:"output"`
+
+	// Timeout is the timeout for each API request.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// settings are the resolved settings for a command.
+type settings struct {
+	url     string
+	apiKey  string
+	output  string
+	timeout time.Duration
+}
+
+// settings resolves the settings for a command. Flags take precedence over
+// environment variables, which take precedence over the profile.
+func (a *app) settings() (*settings, error) {
+	p, err := a.loadProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &settings{
+		url:     postalclient.DefaultBaseURL,
+		output:  "table",
+		timeout: postalclient.DefaultTimeout,
+	}
+	if p != nil {
+		s.url = firstNonEmpty(p.URL, s.url)
+		s.apiKey = p.APIKey
+		if p.APIKeyEnv != "" {
+			s.apiKey = firstNonEmpty(a.getenv(p.APIKeyEnv), s.apiKey)
+		}
+		s.output = firstNonEmpty(p.Output, s.output)
+		if p.Timeout > 0 {
+			s.timeout = p.Timeout
+		}
+	}
+
+	s.url = firstNonEmpty(a.global.url, a.getenv(envURL), s.url)
+	s.apiKey = firstNonEmpty(a.getenv(envAPIKey), s.apiKey)
+	s.output = firstNonEmpty(a.global.output, s.output)
+	if a.global.timeout > 0 {
+		s.timeout = a.global.timeout
+	}
+
+	if s.output != "table" && s.output != "json" {
+		return nil, usageError("unknown output format %q (want table or json)", s.output)
+	}
+	return s, nil
+}
+
+// client returns a client and a printer for the resolved settings.
+func (a *app) client() (*postalclient.Client, *printer, error) {
+	s, err := a.settings()
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.apiKey == "" {
+		return nil, nil, fmt.Errorf("no API key: set $%s or api_key in a profile", envAPIKey)
+	}
+	client, err := postalclient.New(s.apiKey,
+		postalclient.WithBaseURL(s.url),
+		postalclient.WithTimeout(s.timeout),
+		postalclient.WithUserAgent("postalctl"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, &printer{w: a.stdout, format: s.output}, nil
+}
+
+// loadProfile reads the selected profile from the config file. It returns
+// nil if there is no config file and no profile was asked for explicitly.
+func (a *app) loadProfile() (*profile, error) {
+	path, explicitPath := a.global.config, a.global.config != ""
+	if !explicitPath {
+		path, explicitPath = a.getenv(envConfig), a.getenv(envConfig) != ""
+	}
+	if !explicitPath {
+		path = a.defaultConfigPath()
+	}
+	name := firstNonEmpty(a.global.profile, a.getenv(envProfile))
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicitPath && name == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var cfg configFile
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	explicitProfile := name != ""
+	name = firstNonEmpty(name, cfg.DefaultProfile, defaultProfile)
+	p, ok := cfg.Profiles[name]
+	if !ok || p == nil {
+		if explicitProfile || cfg.DefaultProfile != "" {
+			return nil, fmt.Errorf("profile %q not found in %s", name, path)
+		}
+		return nil, nil
+	}
+	return p, nil
+}
+
+// defaultConfigPath returns the default location of the config file.
+func (a *app) defaultConfigPath() string {
+	dir := a.getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(a.getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "postalctl", "config.yaml")
+}
+
+// firstNonEmpty returns the first of values that isn't empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
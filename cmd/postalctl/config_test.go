@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go"
+)
+
+// writeConfig writes a config file under a temporary XDG_CONFIG_HOME and
+// returns the directory.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "postalctl", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+const testConfig = `
+default_profile: production
+profiles:
+  production:
+    url: https://postal.example.com/api/v1
+    api_key_env: PROD_KEY
+    timeout: 10s
+  staging:
+    url: https://staging.example.com/api/v1
+    api_key: staging-key
+    output: json
+`
+
+func TestSettingsFromProfile(t *testing.T) {
+	dir := writeConfig(t, testConfig)
+	env := map[string]string{"XDG_CONFIG_HOME": dir, "PROD_KEY": "prod-key"}
+
+	// The default profile
+	a := &app{getenv: func(k string) string { return env[k] }}
+	s, err := a.settings()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if s.url != "https://postal.example.com/api/v1" || s.apiKey != "prod-key" || s.timeout != 10*time.Second || s.output != "table" {
+		t.Errorf("Expected the production profile, got %+v", s)
+	}
+
+	// A profile selected with the environment
+	env[envProfile] = "staging"
+	s, err = a.settings()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if s.apiKey != "staging-key" || s.output != "json" {
+		t.Errorf("Expected the staging profile, got %+v", s)
+	}
+
+	// Flags and environment variables take precedence
+	env[envAPIKey] = "env-key"
+	a.global = globalFlags{profile: "production", url: "https://flag.example.com/api/v1", output: "json"}
+	s, err = a.settings()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if s.url != "https://flag.example.com/api/v1" || s.apiKey != "env-key" || s.output != "json" {
+		t.Errorf("Expected flags and environment to win, got %+v", s)
+	}
+}
+
+func TestSettingsWithoutConfig(t *testing.T) {
+	env := map[string]string{"XDG_CONFIG_HOME": t.TempDir(), envAPIKey: "key"}
+	a := &app{getenv: func(k string) string { return env[k] }}
+
+	s, err := a.settings()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if s.url != postalclient.DefaultBaseURL || s.apiKey != "key" || s.timeout != postalclient.DefaultTimeout {
+		t.Errorf("Expected defaults, got %+v", s)
+	}
+
+	// An explicit profile requires the config file
+	a.global.profile = "missing"
+	if _, err := a.settings(); err == nil {
+		t.Error("Expected an error for a profile without a config file, got nil")
+	}
+}
+
+func TestSettingsErrors(t *testing.T) {
+	dir := writeConfig(t, testConfig)
+	env := map[string]string{"XDG_CONFIG_HOME": dir}
+	a := &app{getenv: func(k string) string { return env[k] }}
+
+	a.global.profile = "nope"
+	if _, err := a.settings(); err == nil || !strings.Contains(err.Error(), `profile "nope" not found`) {
+		t.Errorf("Expected a missing profile error, got %v", err)
+	}
+
+	a.global = globalFlags{output: "xml"}
+	if _, err := a.settings(); err == nil || !strings.Contains(err.Error(), "unknown output format") {
+		t.Errorf("Expected an output format error, got %v", err)
+	}
+
+	a.global = globalFlags{config: filepath.Join(dir, "missing.yaml")}
+	if _, err := a.settings(); err == nil {
+		t.Error("Expected an error for a missing explicit config file, got nil")
+	}
+
+	bad := writeConfig(t, "profiles: [")
+	env["XDG_CONFIG_HOME"] = bad
+	a.global = globalFlags{}
+	if _, err := a.settings(); err == nil || !strings.Contains(err.Error(), "error parsing config file") {
+		t.Errorf("Expected a parse error, got %v", err)
+	}
+
+	// Without an API key, no client is created
+	env["XDG_CONFIG_HOME"] = t.TempDir()
+	if _, _, err := a.client(); err == nil || !strings.Contains(err.Error(), "no API key") {
+		t.Errorf("Expected a missing API key error, got %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/Suhaibinator/postalclient-go/cmd/postalctl
+
+go 1.24.1
+
+require (
+	github.com/Suhaibinator/postalclient-go v0.0.0-20261016083332-ae824f9132dc
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,205 @@
+// Command postalctl sends and inspects messages through the Postal API.
+//
+// Usage:
+//
+//	postalctl [flags] <command> [arguments]
+//
+// Commands:
+//
+//	send          send a message built from flags or a JSON/YAML file
+//	send-raw      send an RFC 2822 message (.eml) from a file or stdin
+//	message get   show a message, with optional expansions
+//	deliveries    list the delivery attempts for a message
+//	watch         follow a message until it is delivered or fails
+//
+// The server URL and API key are read from the POSTAL_URL and
+// POSTAL_API_KEY environment variables, or from a profile in the config
+// file (by default $XDG_CONFIG_HOME/postalctl/config.yaml):
+//
+//	default_profile: production
+//	profiles:
+//	  production:
+//	    url: https://postal.example.com/api/v1
+//	    api_key_env: POSTAL_PRODUCTION_KEY
+//	  staging:
+//	    url: https://postal.staging.example.com/api/v1
+//	    api_key: abc123
+//	    output: json
+//
+// Output is a table by default; pass -o json for JSON.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// Exit codes.
+const (
+	exitOK      = 0
+	exitError   = 1
+	exitUsage   = 2
+	exitFailure = 3 // watch: the message failed to deliver
+)
+
+var (
+	// errUsage marks errors caused by invalid command-line arguments.
+	errUsage = errors.New("usage error")
+
+	// errUsageShown is returned when the flag package has already
+	// reported a usage error.
+	errUsageShown = errors.New("usage error shown")
+)
+
+// usageError returns an error that makes postalctl exit with exitUsage.
+func usageError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errUsage, fmt.Sprintf(format, args...))
+}
+
+// app holds the environment a command runs in, so tests can replace it.
+type app struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+
+	global globalFlags
+}
+
+// command is a postalctl subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, a *app, args []string) error
+}
+
+// commands lists the subcommands in the order they appear in the usage.
+var commands = []command{
+	{"send", "send a message built from flags or a JSON/YAML file", runSend},
+	{"send-raw", "send an RFC 2822 message (.eml) from a file or stdin", runSendRaw},
+	{"message", "show a message: message get <id>", runMessage},
+	{"deliveries", "list the delivery attempts for a message", runDeliveries},
+	{"watch", "follow a message until it is delivered or fails", runWatch},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	a := &app{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv}
+	os.Exit(a.run(ctx, os.Args[1:]))
+}
+
+// run runs postalctl with the given arguments and returns the exit code.
+func (a *app) run(ctx context.Context, args []string) int {
+	fs := a.newFlagSet("postalctl")
+	fs.Usage = func() { a.usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		a.usage(fs)
+		return exitUsage
+	}
+
+	name := fs.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return a.exitCode(cmd.run(ctx, a, fs.Args()[1:]))
+		}
+	}
+	fmt.Fprintf(a.stderr, "postalctl: unknown command %q\n\n", name)
+	a.usage(fs)
+	return exitUsage
+}
+
+// exitCode reports err, if any, and returns the matching exit code.
+func (a *app) exitCode(err error) int {
+	var failed *deliveryFailedError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errUsageShown):
+		return exitUsage
+	case errors.As(err, &failed):
+		fmt.Fprintf(a.stderr, "postalctl: %v\n", err)
+		return exitFailure
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(a.stderr, "postalctl: %s\n", strings.TrimPrefix(err.Error(), errUsage.Error()+": "))
+		return exitUsage
+	default:
+		fmt.Fprintf(a.stderr, "postalctl: %v\n", err)
+		return exitError
+	}
+}
+
+// usage prints the top-level usage message.
+func (a *app) usage(fs *flag.FlagSet) {
+	fmt.Fprintf(a.stderr, "Usage: postalctl [flags] <command> [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(a.stderr, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(a.stderr, "\nFlags:\n")
+	fs.SetOutput(a.stderr)
+	fs.PrintDefaults()
+	fmt.Fprintf(a.stderr, "\nRun 'postalctl <command> -h' for help with a command.\n")
+}
+
+// newFlagSet returns a flag set with the global flags registered, so they
+// can be given before or after the command name.
+func (a *app) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	a.global.register(fs)
+	return fs
+}
+
+// parseArgs parses flags that may be mixed with positional arguments, as
+// in "message get 123 -o json", and returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errUsageShown
+		}
+
+		// Everything after "--" is positional
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// listFlag is a flag that can be given more than once.
+type listFlag []string
+
+// String implements the flag.Value interface.
+func (l *listFlag) String() string {
+	return strings.Join(*l, ", ")
+}
+
+// Set implements the flag.Value interface.
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+	"github.com/Suhaibinator/postalclient-go/postaltest"
+)
+
+// result is the outcome of a postalctl run.
+type result struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+// runCLI runs postalctl with the given environment, stdin, and arguments.
+func runCLI(t *testing.T, env map[string]string, stdin string, args ...string) result {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	a := &app{
+		stdin:  strings.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+		getenv: func(key string) string { return env[key] },
+	}
+	code := a.run(context.Background(), args)
+	return result{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+// serverEnv returns an environment pointing postalctl at server, with no
+// config file.
+func serverEnv(t *testing.T, server *postaltest.Server) map[string]string {
+	return map[string]string{
+		envURL:            server.URL,
+		envAPIKey:         server.APIKey(),
+		"XDG_CONFIG_HOME": t.TempDir(),
+	}
+}
+
+func TestSendFromFlags(t *testing.T) {
+	server := postaltest.NewServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	attachment := filepath.Join(dir, "report.csv")
+	if err := os.WriteFile(attachment, []byte("a,b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	res := runCLI(t, serverEnv(t, server), "", "send",
+		"-from", "ops@example.com",
+		"-to", "a@example.org", "-to", "b@example.org",
+		"-subject", "Test",
+		"-text", "Hello",
+		"-header", "X-Source: postalctl",
+		"-attach", attachment,
+		"-o", "json",
+	)
+	if res.code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d: %s", res.code, res.stderr)
+	}
+
+	var resp models.SendMessageResponse
+	if err := json.Unmarshal([]byte(res.stdout), &resp); err != nil || resp.MessageID != 1 {
+		t.Errorf("Expected a JSON response for message 1, got %q (%v)", res.stdout, err)
+	}
+
+	msg := server.LastMessage()
+	if msg == nil {
+		t.Fatal("Expected a stored message, got nil")
+	}
+
+	if len(msg.Request.To) != 2 || msg.Request.Subject != "Test" || msg.Request.Headers["X-Source"] != "postalctl" {
+		t.Errorf("Expected the message to match the flags, got %+v", msg.Request)
+	}
+
+	if len(msg.Request.Attachments) != 1 || msg.Request.Attachments[0].Name != "report.csv" || !strings.HasPrefix(msg.Request.Attachments[0].ContentType, "text/csv") {
+		t.Errorf("Expected report.csv to be attached, got %+v", msg.Request.Attachments)
+	}
+}
+
+func TestSendFromYAMLFile(t *testing.T) {
+	server := postaltest.NewServer()
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "message.yaml")
+	yaml := "from: ops@example.com\nto:\n  - a@example.org\nsubject: From YAML\nplain_body: Hello\ntag: test\n"
+	if err := os.WriteFile(file, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flags override the file
+	res := runCLI(t, serverEnv(t, server), "", "send", "-f", file, "-subject", "Overridden")
+	if res.code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d: %s", res.code, res.stderr)
+	}
+
+	if !strings.Contains(res.stdout, "MESSAGE ID") {
+		t.Errorf("Expected table output, got %q", res.stdout)
+	}
+
+	msg := server.LastMessage()
+	if msg.Request.Subject != "Overridden" || msg.Request.PlainBody != "Hello" || msg.Request.Tag != "test" {
+		t.Errorf("Expected the message from the file with the subject overridden, got %+v", msg.Request)
+	}
+
+	// JSON files work too, read from stdin
+	res = runCLI(t, serverEnv(t, server), `{"from": "ops@example.com", "to": ["a@example.org"], "plain_body": "Hi"}`, "send", "-f", "-")
+	if res.code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d: %s", res.code, res.stderr)
+	}
+}
+
+func TestSendUsageErrors(t *testing.T) {
+	server := postaltest.NewServer()
+	defer server.Close()
+
+	res := runCLI(t, serverEnv(t, server), "", "send", "-to", "a@example.org", "-text", "Hi")
+	if res.code != exitUsage || !strings.Contains(res.stderr, "From address is required") {
+		t.Errorf("Expected a usage error for a missing From address, got %d: %s", res.code, res.stderr)
+	}
+
+	res = runCLI(t, serverEnv(t, server), "", "send", "-nope")
+	if res.code != exitUsage {
+		t.Errorf("Expected a usage error for an unknown flag, got %d", res.code)
+	}
+
+	// API errors exit with status 1
+	res = runCLI(t, serverEnv(t, server), "", "send", "-from", "ops@example.com", "-to", "a@example.org")
+	if res.code != exitError || !strings.Contains(res.stderr, "no content") {
+		t.Errorf("Expected the API error, got %d: %s", res.code, res.stderr)
+	}
+
+	if len(server.Messages()) != 0 {
+		t.Errorf("Expected no messages, got %d", len(server.Messages()))
+	}
+}
+
+func TestSendRaw(t *testing.T) {
+	server := postaltest.NewServer()
+	defer server.Close()
+
+	eml := "From: Ops <ops@example.com>\r\nTo: a@example.org\r\nCc: b@example.org\r\nSubject: Raw\r\n\r\nHello\r\n"
+	res := runCLI(t, serverEnv(t, server), eml, "send-raw")
+	if res.code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d: %s", res.code, res.stderr)
+	}
+
+	msg := server.LastMessage()
+	if msg.Raw == nil || msg.Raw.MailFrom != "ops@example.com" {
+		t.Fatalf("Expected a raw message from ops@example.com, got %v", msg)
+	}
+
+	if strings.Join(msg.Raw.RcptTo, ",") != "a@example.org,b@example.org" {
+		t.Errorf("Expected recipients from the headers, got %v", msg.Raw.RcptTo)
+	}
+
+	// Envelope flags take precedence over the headers
+	file := filepath.Join(t.TempDir(), "message.eml")
+	if err := os.WriteFile(file, []byte(eml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	res = runCLI(t, serverEnv(t, server), "", "send-raw", file, "-mail-from", "bounce@example.com", "-rcpt-to", "c@example.org")
+	if res.code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d: %s", res.code, res.stderr)
+	}
+
+	msg = server.LastMessage()
+	if msg.Raw.MailFrom != "bounce@example.com" || strings.Join(msg.Raw.RcptTo, ",") != "c@example.org" {
+		t.Errorf("Expected the envelope from the flags, got %s", msg)
+	}
+}
+
+func TestMessageGetAndDeliveries(t *testing.T) {
+	server := postaltest.NewServer()
+	defer server.Close()
+	env := serverEnv(t, server)
+
+	if res := runCLI(t, env, "", "send", "-from", "ops@example.com", "-to", "a@example.org", "-subject", "Hi", "-text", "Hello"); res.code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d: %s", res.code, res.stderr)
+	}
+	server.SimulateDelivery(1, models.Delivery{Status: "SoftFail", Details: "Connection refused"})
+
+	// Table output shows the default expansions
+	res := runCLI(t, env, "", "message", "get", "1")
+	if res.code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d: %s", res.code, res.stderr)
+	}
+
+	for _, want := range []string{"SoftFail", "Subject:", "Hi", "outgoing"} {
+		if !strings.Contains(res.stdout, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, res.stdout)
+		}
+	}
+
+	// Flags after the ID, and JSON output
+	res = runCLI(t, env, "", "message", "get", "1", "-expand", "plain_body", "-o", "json")
+	var message models.Message
+	if err := json.Unmarshal([]byte(res.stdout), &message); err != nil {
+		t.Fatalf("Expected JSON output, got %q (%v)", res.stdout, err)
+	}
+
+	if message.PlainBody != "Hello" || message.Status != nil {
+		t.Errorf("Expected only the plain body expansion, got %+v", message)
+	}
+
+	// Deliveries
+	res = runCLI(t, env, "", "deliveries", "1")
+	if res.code != exitOK || !strings.Contains(res.stdout, "Connection refused") {
+		t.Errorf("Expected the delivery in the output, got %d:\n%s", res.code, res.stdout)
+	}
+
+	// Unknown messages exit with status 1
+	res = runCLI(t, env, "", "deliveries", "99")
+	if res.code != exitError || !strings.Contains(res.stderr, "No message found") {
+		t.Errorf("Expected a message not found error, got %d: %s", res.code, res.stderr)
+	}
+
+	res = runCLI(t, env, "", "message", "show", "1")
+	if res.code != exitUsage {
+		t.Errorf("Expected a usage error for an unknown subcommand, got %d", res.code)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	server := postaltest.NewServer()
+	defer server.Close()
+	env := serverEnv(t, server)
+
+	if res := runCLI(t, env, "", "send", "-from", "ops@example.com", "-to", "a@example.org", "-text", "Hello"); res.code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d: %s", res.code, res.stderr)
+	}
+	server.SimulateDelivery(1, models.Delivery{Status: "SoftFail"})
+	server.SimulateDelivery(1, models.Delivery{Status: "Sent"})
+
+	// A temporary error doesn't end the watch
+	server.Script(postaltest.EndpointMessageDeliveries).Next(1, postaltest.Status(http.StatusServiceUnavailable))
+
+	res := runCLI(t, env, "", "watch", "1", "-interval", "10ms")
+	if res.code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d: %s", res.code, res.stderr)
+	}
+
+	if !strings.Contains(res.stdout, "SoftFail") || !strings.Contains(res.stdout, "Sent") {
+		t.Errorf("Expected both deliveries in the output, got:\n%s", res.stdout)
+	}
+
+	// A hard failure exits with exitFailure
+	server.SimulateDelivery(1, models.Delivery{Status: "HardFail"})
+	res = runCLI(t, env, "", "watch", "-interval", "10ms", "-o", "json", "1")
+	if res.code != exitFailure {
+		t.Errorf("Expected exit code %d, got %d: %s", exitFailure, res.code, res.stderr)
+	}
+
+	if lines := strings.Count(res.stdout, "\n"); lines != 3 {
+		t.Errorf("Expected 3 JSON lines, got %d:\n%s", lines, res.stdout)
+	}
+
+	// Pending messages are watched until -wait runs out
+	if res := runCLI(t, env, "", "send", "-from", "ops@example.com", "-to", "a@example.org", "-text", "Hello"); res.code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d: %s", res.code, res.stderr)
+	}
+	res = runCLI(t, env, "", "watch", "-interval", "10ms", "-wait", "50ms", "2")
+	if res.code != exitError || !strings.Contains(res.stderr, "stopped watching") {
+		t.Errorf("Expected watch to give up, got %d: %s", res.code, res.stderr)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	res := runCLI(t, nil, "")
+	if res.code != exitUsage || !strings.Contains(res.stderr, "Commands:") {
+		t.Errorf("Expected usage, got %d: %s", res.code, res.stderr)
+	}
+
+	res = runCLI(t, nil, "", "frobnicate")
+	if res.code != exitUsage || !strings.Contains(res.stderr, `unknown command "frobnicate"`) {
+		t.Errorf("Expected an unknown command error, got %d: %s", res.code, res.stderr)
+	}
+
+	res = runCLI(t, nil, "", "send", "-h")
+	if res.code != exitOK || !strings.Contains(res.stderr, "Usage: postalctl send") {
+		t.Errorf("Expected send usage, got %d: %s", res.code, res.stderr)
+	}
+}
@@ -0,0 +1,183 @@
+// This file contains the message, deliveries, and watch commands.
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// runMessage implements the message command, which has a single "get"
+// subcommand.
+func runMessage(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("message get")
+	var (
+		expand string
+		all    bool
+	)
+	fs.StringVar(&expand, "expand", "status,details", "comma-separated expansions: status, details, inspection, plain_body, html_body, attachments, headers, raw_message")
+	fs.BoolVar(&all, "all", false, "request every expansion")
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: postalctl message get [flags] <id>\n\nShow a message.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 || positional[0] != "get" {
+		fs.Usage()
+		return errUsageShown
+	}
+	id, err := messageID(positional[1:])
+	if err != nil {
+		return err
+	}
+
+	var opts []postalclient.GetMessageOption
+	if all {
+		opts = append(opts, postalclient.WithAllExpansions())
+	} else {
+		for _, name := range strings.Split(expand, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				opts = append(opts, postalclient.WithExpansions(postalclient.Expansion(name)))
+			}
+		}
+	}
+
+	client, out, err := a.client()
+	if err != nil {
+		return err
+	}
+	message, err := client.GetMessageContext(ctx, id, opts...)
+	if err != nil {
+		return err
+	}
+	return out.printMessage(message)
+}
+
+// runDeliveries implements the deliveries command.
+func runDeliveries(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("deliveries")
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: postalctl deliveries [flags] <id>\n\nList the delivery attempts for a message.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	id, err := messageID(positional)
+	if err != nil {
+		return err
+	}
+
+	client, out, err := a.client()
+	if err != nil {
+		return err
+	}
+	deliveries, err := client.GetMessageDeliveriesContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	return out.printDeliveries(deliveries)
+}
+
+// deliveryFailedError is returned by watch when a message fails to
+// deliver.
+type deliveryFailedError struct {
+	id     int
+	status models.DeliveryStatus
+}
+
+// Error implements the error interface.
+func (e *deliveryFailedError) Error() string {
+	return fmt.Sprintf("message %d was not delivered: %s", e.id, e.status)
+}
+
+// runWatch implements the watch command. It prints deliveries as
+// client.WatchDeliveries reports them, so it agrees with the library on
+// when a message is done.
+func runWatch(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("watch")
+	var interval, maxInterval, wait time.Duration
+	fs.DurationVar(&interval, "interval", 5*time.Second, "delay between polls after a new delivery")
+	fs.DurationVar(&maxInterval, "max-interval", postalclient.DefaultWatchMaxInterval, "longest delay between polls while nothing changes")
+	fs.DurationVar(&wait, "wait", 0, "give up after this long (default: wait until interrupted)")
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: postalctl watch [flags] <id>\n\n")
+		fmt.Fprintf(a.stderr, "Print delivery attempts for a message as they happen, until it is sent,\n")
+		fmt.Fprintf(a.stderr, "fails, or bounces. Exits with status %d if the message was not delivered.\n\nFlags:\n", exitFailure)
+		fs.PrintDefaults()
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	id, err := messageID(positional)
+	if err != nil {
+		return err
+	}
+	if interval <= 0 || maxInterval <= 0 {
+		return usageError("-interval and -max-interval must be positive")
+	}
+
+	client, out, err := a.client()
+	if err != nil {
+		return err
+	}
+
+	// Cancelling stops the watch if printing fails
+	var cancel context.CancelFunc
+	if wait > 0 {
+		ctx, cancel = context.WithTimeout(ctx, wait)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	if !out.isJSON() {
+		if err := out.table(deliveryHeader, nil); err != nil {
+			return err
+		}
+	}
+
+	var (
+		status   models.DeliveryStatus
+		terminal bool
+	)
+	for event := range client.WatchDeliveries(ctx, []int{id}, postalclient.WithWatchInterval(interval, maxInterval)) {
+		if event.Err != nil {
+			return event.Err
+		}
+		if err := out.printDelivery(event.Delivery); err != nil {
+			return err
+		}
+		status, terminal = event.Delivery.Status, event.Terminal
+	}
+
+	if !terminal {
+		return fmt.Errorf("stopped watching message %d: %w", id, ctx.Err())
+	}
+	if !status.IsSuccess() {
+		return &deliveryFailedError{id: id, status: status}
+	}
+	return nil
+}
+
+// messageID parses the single message ID argument of a command.
+func messageID(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, usageError("expected a message ID")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil || id <= 0 {
+		return 0, usageError("invalid message ID %q", args[0])
+	}
+	return id, nil
+}
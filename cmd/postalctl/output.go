@@ -0,0 +1,184 @@
+// This file contains the table and JSON output formats.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// printer writes command output as a table or as JSON.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+// isJSON reports whether output should be JSON.
+func (p *printer) isJSON() bool {
+	return p.format == "json"
+}
+
+// json writes v as indented JSON.
+func (p *printer) json(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// table writes rows under a header row, with aligned columns.
+func (p *printer) table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// fields writes name/value pairs, one per line, skipping empty values.
+func (p *printer) fields(pairs [][2]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	for _, pair := range pairs {
+		if pair[1] != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", pair[0], pair[1])
+		}
+	}
+	return tw.Flush()
+}
+
+// printSendResponse prints the result of a send.
+func (p *printer) printSendResponse(resp *models.SendMessageResponse) error {
+	if p.isJSON() {
+		return p.json(resp)
+	}
+	return p.table([]string{"MESSAGE ID", "TOKEN"}, [][]string{{fmt.Sprint(resp.MessageID), resp.Token}})
+}
+
+// printMessage prints a message and whichever expansions it has.
+func (p *printer) printMessage(m *models.Message) error {
+	if p.isJSON() {
+		return p.json(m)
+	}
+
+	pairs := [][2]string{
+		{"ID", fmt.Sprint(m.ID)},
+		{"Token", m.Token},
+	}
+	if s := m.Status; s != nil {
+		pairs = append(pairs,
+			[2]string{"Status", s.Status.String()},
+			[2]string{"Held", fmt.Sprint(s.Held)},
+			[2]string{"Hold expiry", formatTime(s.HoldExpiry.Time)},
+			[2]string{"Last attempt", formatTime(s.LastDeliveryAttempt.Time)},
+		)
+	}
+	if d := m.Details; d != nil {
+		pairs = append(pairs,
+			[2]string{"Direction", d.Direction},
+			[2]string{"Received", formatTime(d.Timestamp.Time)},
+			[2]string{"Mail from", d.MailFrom},
+			[2]string{"Rcpt to", d.RcptTo},
+			[2]string{"Subject", d.Subject},
+			[2]string{"Message-ID", d.MessageID},
+			[2]string{"Tag", d.Tag},
+			[2]string{"Size", fmt.Sprintf("%d bytes", d.Size)},
+			[2]string{"Bounce", fmt.Sprint(d.Bounce)},
+		)
+		if d.BounceForID != 0 {
+			pairs = append(pairs, [2]string{"Bounce for", fmt.Sprint(d.BounceForID)})
+		}
+	}
+	if i := m.Inspection; i != nil {
+		pairs = append(pairs,
+			[2]string{"Inspected", fmt.Sprint(i.Inspected)},
+			[2]string{"Spam", fmt.Sprintf("%t (score %.1f)", i.Spam, i.SpamScore)},
+			[2]string{"Threat", fmt.Sprint(i.Threat)},
+			[2]string{"Threat details", i.ThreatDetails},
+		)
+	}
+	for _, a := range m.Attachments {
+		pairs = append(pairs, [2]string{"Attachment", fmt.Sprintf("%s (%s, %d bytes)", a.Name, a.ContentType, a.Size)})
+	}
+	if err := p.fields(pairs); err != nil {
+		return err
+	}
+
+	if len(m.Headers) > 0 {
+		fmt.Fprintln(p.w, "\nHeaders:")
+		rows := make([][]string, 0, len(m.Headers))
+		for name, values := range m.Headers {
+			for _, v := range values {
+				rows = append(rows, []string{"  " + name + ":", v})
+			}
+		}
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+		tw := tabwriter.NewWriter(p.w, 0, 0, 1, ' ', 0)
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if m.PlainBody != "" {
+		fmt.Fprintf(p.w, "\nPlain body:\n%s\n", m.PlainBody)
+	}
+	if m.HTMLBody != "" {
+		fmt.Fprintf(p.w, "\nHTML body:\n%s\n", m.HTMLBody)
+	}
+	if raw, err := m.DecodeRawMessage(); err == nil && raw != nil {
+		fmt.Fprintf(p.w, "\nRaw message:\n%s\n", raw)
+	}
+	return nil
+}
+
+// deliveryRow returns a delivery as a table row.
+func deliveryRow(d models.Delivery) []string {
+	details := d.Details
+	if d.Output != "" {
+		details += " (" + d.Output + ")"
+	}
+	return []string{fmt.Sprint(d.ID), d.Status.String(), formatTime(d.Timestamp), details}
+}
+
+// printDelivery prints a single delivery as a table row without a header,
+// or as a line of JSON.
+func (p *printer) printDelivery(d models.Delivery) error {
+	if p.isJSON() {
+		return json.NewEncoder(p.w).Encode(d)
+	}
+	fmt.Fprintln(p.w, strings.Join(deliveryRow(d), "  "))
+	return nil
+}
+
+// deliveryHeader is the header row of delivery tables.
+var deliveryHeader = []string{"ID", "STATUS", "TIME", "DETAILS"}
+
+// printDeliveries prints a list of deliveries.
+func (p *printer) printDeliveries(deliveries []models.Delivery) error {
+	if p.isJSON() {
+		if deliveries == nil {
+			deliveries = []models.Delivery{}
+		}
+		return p.json(deliveries)
+	}
+	rows := make([][]string, len(deliveries))
+	for i, d := range deliveries {
+		rows[i] = deliveryRow(d)
+	}
+	return p.table(deliveryHeader, rows)
+}
+
+// formatTime formats t for tables, or returns "" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,274 @@
+// This file contains the send and send-raw commands.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+	"gopkg.in/yaml.v3"
+)
+
+// runSend implements the send command.
+func runSend(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("send")
+	var (
+		file, from, sender, replyTo, subject, tag string
+		text, html, textFile, htmlFile            string
+		bounce                                    bool
+		to, cc, bcc, headers, attachments         listFlag
+	)
+	fs.StringVar(&file, "f", "", "read the message from a JSON or YAML file (- for stdin); other flags override its fields")
+	fs.StringVar(&from, "from", "", "From address")
+	fs.StringVar(&sender, "sender", "", "Sender address")
+	fs.StringVar(&replyTo, "reply-to", "", "Reply-To address")
+	fs.Var(&to, "to", "To address (repeatable)")
+	fs.Var(&cc, "cc", "CC address (repeatable)")
+	fs.Var(&bcc, "bcc", "BCC address (repeatable)")
+	fs.StringVar(&subject, "subject", "", "subject")
+	fs.StringVar(&tag, "tag", "", "tag")
+	fs.StringVar(&text, "text", "", "plain text body")
+	fs.StringVar(&html, "html", "", "HTML body")
+	fs.StringVar(&textFile, "text-file", "", "read the plain text body from a file")
+	fs.StringVar(&htmlFile, "html-file", "", "read the HTML body from a file")
+	fs.Var(&headers, "header", `custom header as "Name: value" (repeatable)`)
+	fs.Var(&attachments, "attach", "attach a file (repeatable)")
+	fs.BoolVar(&bounce, "bounce", false, "mark the message as a bounce")
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: postalctl send [flags]\n\nSend a message. Examples:\n\n")
+		fmt.Fprintf(a.stderr, "  postalctl send -from ops@example.com -to me@example.com -subject Test -text Hello\n")
+		fmt.Fprintf(a.stderr, "  postalctl send -f message.yaml -attach report.pdf\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError("send takes no arguments, got %q", positional[0])
+	}
+
+	req := &models.SendMessageRequest{}
+	if file != "" {
+		if req, err = a.readMessageFile(file); err != nil {
+			return err
+		}
+	}
+
+	// Flags override the file
+	setString(&req.From, from)
+	setString(&req.Sender, sender)
+	setString(&req.ReplyTo, replyTo)
+	setString(&req.Subject, subject)
+	setString(&req.Tag, tag)
+	setString(&req.PlainBody, text)
+	setString(&req.HTMLBody, html)
+	req.To = append(req.To, to...)
+	req.CC = append(req.CC, cc...)
+	req.BCC = append(req.BCC, bcc...)
+	if bounce {
+		req.Bounce = true
+	}
+	if textFile != "" {
+		data, err := os.ReadFile(textFile)
+		if err != nil {
+			return fmt.Errorf("error reading text body: %w", err)
+		}
+		req.PlainBody = string(data)
+	}
+	if htmlFile != "" {
+		data, err := os.ReadFile(htmlFile)
+		if err != nil {
+			return fmt.Errorf("error reading HTML body: %w", err)
+		}
+		req.HTMLBody = string(data)
+	}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return usageError("invalid header %q, want \"Name: value\"", h)
+		}
+		if req.Headers == nil {
+			req.Headers = make(map[string]string)
+		}
+		req.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	for _, path := range attachments {
+		attachment, err := readAttachment(path)
+		if err != nil {
+			return err
+		}
+		req.Attachments = append(req.Attachments, attachment)
+	}
+
+	if req.From == "" {
+		return usageError("a From address is required (-from)")
+	}
+	if len(req.To) == 0 && len(req.CC) == 0 && len(req.BCC) == 0 {
+		return usageError("at least one recipient is required (-to, -cc, or -bcc)")
+	}
+
+	client, out, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.SendMessageContext(ctx, req)
+	if err != nil {
+		return err
+	}
+	return out.printSendResponse(resp)
+}
+
+// runSendRaw implements the send-raw command.
+func runSendRaw(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("send-raw")
+	var (
+		mailFrom string
+		rcptTo   listFlag
+		bounce   bool
+	)
+	fs.StringVar(&mailFrom, "mail-from", "", "envelope sender (default: the message's From address)")
+	fs.Var(&rcptTo, "rcpt-to", "envelope recipient (repeatable; default: the message's To, Cc, and Bcc addresses)")
+	fs.BoolVar(&bounce, "bounce", false, "mark the message as a bounce")
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: postalctl send-raw [flags] [file.eml]\n\n")
+		fmt.Fprintf(a.stderr, "Send an RFC 2822 message read from a file, or from stdin if no file or - is given.\n")
+		fmt.Fprintf(a.stderr, "The message is sent as is; Bcc headers are not removed.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return usageError("send-raw takes at most one file, got %d", len(positional))
+	}
+
+	path := "-"
+	if len(positional) == 1 {
+		path = positional[0]
+	}
+	data, err := a.readInput(path)
+	if err != nil {
+		return err
+	}
+
+	// Take the envelope from the message's headers where not given
+	if mailFrom == "" || len(rcptTo) == 0 {
+		msg, err := mail.ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("error parsing message: %w", err)
+		}
+		if mailFrom == "" {
+			from, err := msg.Header.AddressList("From")
+			if err != nil || len(from) == 0 {
+				return usageError("the message has no valid From header; use -mail-from")
+			}
+			mailFrom = from[0].Address
+		}
+		if len(rcptTo) == 0 {
+			for _, field := range []string{"To", "Cc", "Bcc"} {
+				addrs, err := msg.Header.AddressList(field)
+				if err != nil && !errors.Is(err, mail.ErrHeaderNotPresent) {
+					return fmt.Errorf("error parsing %s header: %w", field, err)
+				}
+				for _, addr := range addrs {
+					rcptTo = append(rcptTo, addr.Address)
+				}
+			}
+			if len(rcptTo) == 0 {
+				return usageError("the message has no recipients; use -rcpt-to")
+			}
+		}
+	}
+
+	client, out, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.SendRawContext(ctx, &models.SendRawRequest{
+		MailFrom: mailFrom,
+		RcptTo:   rcptTo,
+		Data:     base64.StdEncoding.EncodeToString(data),
+		Bounce:   bounce,
+	})
+	if err != nil {
+		return err
+	}
+	return out.printSendResponse(resp)
+}
+
+// readMessageFile reads a SendMessageRequest from a JSON or YAML file. The
+// fields use the same names as the API, e.g. plain_body and reply_to.
+func (a *app) readMessageFile(path string) (*models.SendMessageRequest, error) {
+	data, err := a.readInput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// YAML is a superset of JSON, so one decoder handles both. Converting
+	// through JSON applies the request's JSON field names.
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	var req models.SendMessageRequest
+	if err := json.Unmarshal(encoded, &req); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return &req, nil
+}
+
+// readInput reads a file, or stdin if path is "-".
+func (a *app) readInput(path string) ([]byte, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(a.stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// readAttachment reads a file as an attachment, guessing its content type
+// from its extension.
+func readAttachment(path string) (models.Attachment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return models.Attachment{}, fmt.Errorf("error reading attachment: %w", err)
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return models.Attachment{
+		Name:        filepath.Base(path),
+		ContentType: contentType,
+		Data:        base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+// setString sets *dst to value if value isn't empty.
+func setString(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
@@ -0,0 +1,400 @@
+// This file contains the error types returned by the client. Failures are
+// split into three families so callers can handle them separately:
+//
+//   - *TransportError: the request never produced a complete response
+//     (connection refused, reset, timeout, body cut short).
+//   - *DecodeError: a response was received but could not be decoded.
+//   - *Error: Postal returned a well-formed error response. Known Postal
+//     error codes are further classified into *SendError, *AuthError,
+//     *NotFoundError, and *ParameterError, and match the sentinel errors
+//     below with errors.Is.
+//
+// Requests rejected by the client's own validation, when enabled with
+// WithValidation, are reported as *InvalidRequestError without being sent.
+package postalclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// Error codes returned by Postal in the "code" field of an error response.
+const (
+	// CodeValidationError is returned when a message fails validation.
+	CodeValidationError = "ValidationError"
+
+	// CodeNoRecipients is returned when a message has no To, CC, or BCC
+	// recipients.
+	CodeNoRecipients = models.CodeNoRecipients
+
+	// CodeNoContent is returned when a message has neither a plain text
+	// nor an HTML body.
+	CodeNoContent = models.CodeNoContent
+
+	// CodeTooManyToAddresses is returned when a message has more than 50
+	// To recipients.
+	CodeTooManyToAddresses = models.CodeTooManyToAddresses
+
+	// CodeTooManyCCAddresses is returned when a message has more than 50
+	// CC recipients.
+	CodeTooManyCCAddresses = models.CodeTooManyCCAddresses
+
+	// CodeTooManyBCCAddresses is returned when a message has more than 50
+	// BCC recipients.
+	CodeTooManyBCCAddresses = models.CodeTooManyBCCAddresses
+
+	// CodeFromAddressMissing is returned when a message has no From address.
+	CodeFromAddressMissing = models.CodeFromAddressMissing
+
+	// CodeUnauthenticatedFromAddress is returned when the From (or
+	// MailFrom) address does not belong to a domain the server may send as.
+	CodeUnauthenticatedFromAddress = "UnauthenticatedFromAddress"
+
+	// CodeAttachmentMissingName is returned when an attachment has no name.
+	CodeAttachmentMissingName = models.CodeAttachmentMissingName
+
+	// CodeAttachmentMissingData is returned when an attachment has no data.
+	CodeAttachmentMissingData = models.CodeAttachmentMissingData
+
+	// CodeMessageNotFound is returned when no message matches the given ID.
+	CodeMessageNotFound = "MessageNotFound"
+
+	// CodeAccessDenied is returned when no API key was provided.
+	CodeAccessDenied = "AccessDenied"
+
+	// CodeInvalidServerAPIKey is returned when the API key is not valid.
+	CodeInvalidServerAPIKey = "InvalidServerAPIKey"
+
+	// CodeServerSuspended is returned when the mail server is suspended.
+	CodeServerSuspended = "ServerSuspended"
+)
+
+// Sentinel errors for Postal's documented error codes. An *Error returned
+// by the client matches the sentinel for its code with errors.Is:
+//
+//	if errors.Is(err, postalclient.ErrNoRecipients) {
+//	    // Handle the missing recipients
+//	}
+var (
+	ErrValidation                 = errors.New("postal: validation error")
+	ErrNoRecipients               = errors.New("postal: no recipients")
+	ErrNoContent                  = errors.New("postal: no content")
+	ErrTooManyToAddresses         = errors.New("postal: too many To addresses")
+	ErrTooManyCCAddresses         = errors.New("postal: too many CC addresses")
+	ErrTooManyBCCAddresses        = errors.New("postal: too many BCC addresses")
+	ErrFromAddressMissing         = errors.New("postal: from address missing")
+	ErrUnauthenticatedFromAddress = errors.New("postal: unauthenticated from address")
+	ErrAttachmentMissingName      = errors.New("postal: attachment missing name")
+	ErrAttachmentMissingData      = errors.New("postal: attachment missing data")
+	ErrMessageNotFound            = errors.New("postal: message not found")
+	ErrAccessDenied               = errors.New("postal: access denied")
+	ErrInvalidServerAPIKey        = errors.New("postal: invalid server API key")
+	ErrServerSuspended            = errors.New("postal: server suspended")
+
+	// ErrParameter is matched by errors with the "parameter-error" status,
+	// which Postal returns when a required parameter is missing or invalid.
+	ErrParameter = errors.New("postal: parameter error")
+)
+
+// codeSentinels maps Postal error codes to their sentinel errors.
+var codeSentinels = map[string]error{
+	CodeValidationError:            ErrValidation,
+	CodeNoRecipients:               ErrNoRecipients,
+	CodeNoContent:                  ErrNoContent,
+	CodeTooManyToAddresses:         ErrTooManyToAddresses,
+	CodeTooManyCCAddresses:         ErrTooManyCCAddresses,
+	CodeTooManyBCCAddresses:        ErrTooManyBCCAddresses,
+	CodeFromAddressMissing:         ErrFromAddressMissing,
+	CodeUnauthenticatedFromAddress: ErrUnauthenticatedFromAddress,
+	CodeAttachmentMissingName:      ErrAttachmentMissingName,
+	CodeAttachmentMissingData:      ErrAttachmentMissingData,
+	CodeMessageNotFound:            ErrMessageNotFound,
+	CodeAccessDenied:               ErrAccessDenied,
+	CodeInvalidServerAPIKey:        ErrInvalidServerAPIKey,
+	CodeServerSuspended:            ErrServerSuspended,
+}
+
+// ErrorDetails holds the structured contents of an error response's Data
+// payload. Fields that Postal did not provide are left empty.
+type ErrorDetails struct {
+	// Code is the machine-readable error code, e.g. "NoRecipients".
+	Code string `json:"code"`
+
+	// Message is a human-readable description of the error.
+	Message string `json:"message"`
+
+	// ID is the message ID included with MessageNotFound errors.
+	// It is zero if the server did not include a numeric ID.
+	ID int `json:"-"`
+
+	// Token is the rejected API key included with InvalidServerAPIKey errors.
+	Token string `json:"token"`
+
+	// Errors maps request fields to the problems found with them, as
+	// returned with some parameter errors.
+	Errors map[string][]string `json:"errors"`
+}
+
+// parseErrorDetails decodes an error response's Data payload. It is lenient:
+// payloads that are not JSON objects, or fields with unexpected types, are
+// ignored rather than reported.
+func parseErrorDetails(data json.RawMessage) ErrorDetails {
+	var details ErrorDetails
+	if len(data) == 0 {
+		return details
+	}
+
+	// Decode each field on its own so one malformed field doesn't hide
+	// the others
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return details
+	}
+	_ = json.Unmarshal(fields["code"], &details.Code)
+	_ = json.Unmarshal(fields["message"], &details.Message)
+	_ = json.Unmarshal(fields["token"], &details.Token)
+	_ = json.Unmarshal(fields["errors"], &details.Errors)
+
+	// The ID is echoed back from the request and may be a number or a
+	// numeric string
+	var id json.Number
+	if err := json.Unmarshal(fields["id"], &id); err == nil {
+		details.ID, _ = strconv.Atoi(id.String())
+	}
+
+	return details
+}
+
+// newAPIError decodes an error response body into an *Error. The Data
+// payload is parsed into Details, and ErrorCode and Message are filled in
+// from it when the top-level fields are empty, as they are in responses
+// from Postal itself.
+func newAPIError(body []byte, statusCode int) (*Error, error) {
+	var apiError Error
+	if err := json.Unmarshal(body, &apiError); err != nil {
+		return nil, err
+	}
+	apiError.StatusCode = statusCode
+	apiError.Details = parseErrorDetails(apiError.Data)
+	if apiError.ErrorCode == "" {
+		apiError.ErrorCode = apiError.Details.Code
+	}
+	if apiError.Message == "" {
+		apiError.Message = apiError.Details.Message
+	}
+	return &apiError, nil
+}
+
+// Unwrap returns the typed error for e's error code or status, so that
+// errors.As can extract a *SendError, *AuthError, *NotFoundError, or
+// *ParameterError, and errors.Is can match the code's sentinel error.
+// It returns nil for unknown error codes.
+func (e *Error) Unwrap() error {
+	switch e.ErrorCode {
+	case CodeValidationError, CodeNoRecipients, CodeNoContent,
+		CodeTooManyToAddresses, CodeTooManyCCAddresses, CodeTooManyBCCAddresses,
+		CodeFromAddressMissing, CodeUnauthenticatedFromAddress,
+		CodeAttachmentMissingName, CodeAttachmentMissingData:
+		return &SendError{Code: e.ErrorCode, Err: e}
+	case CodeAccessDenied, CodeInvalidServerAPIKey, CodeServerSuspended:
+		return &AuthError{Code: e.ErrorCode, Token: e.Details.Token, Err: e}
+	case CodeMessageNotFound:
+		return &NotFoundError{ID: e.Details.ID, Err: e}
+	}
+	if e.Status == "parameter-error" {
+		return &ParameterError{Fields: e.Details.Errors, Err: e}
+	}
+	return nil
+}
+
+// SendError is returned when Postal rejects a message because it is
+// invalid, e.g. it has no recipients, too many recipients, no content, or
+// a From address the server is not allowed to send as.
+type SendError struct {
+	// Code is the Postal error code, e.g. CodeNoRecipients.
+	Code string
+
+	// Err is the underlying API error.
+	Err *Error
+}
+
+// Error returns a string representation of the error.
+func (e *SendError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the sentinel error for e's code.
+func (e *SendError) Unwrap() error { return codeSentinels[e.Code] }
+
+// AuthError is returned when Postal rejects the request's credentials:
+// the API key is missing or invalid, or the server is suspended.
+type AuthError struct {
+	// Code is the Postal error code, e.g. CodeInvalidServerAPIKey.
+	Code string
+
+	// Token is the rejected API key, if Postal included it.
+	Token string
+
+	// Err is the underlying API error.
+	Err *Error
+}
+
+// Error returns a string representation of the error.
+func (e *AuthError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the sentinel error for e's code.
+func (e *AuthError) Unwrap() error { return codeSentinels[e.Code] }
+
+// NotFoundError is returned when the requested message does not exist.
+type NotFoundError struct {
+	// ID is the message ID that was not found, if Postal included it.
+	ID int
+
+	// Err is the underlying API error.
+	Err *Error
+}
+
+// Error returns a string representation of the error.
+func (e *NotFoundError) Error() string { return e.Err.Error() }
+
+// Unwrap returns ErrMessageNotFound.
+func (e *NotFoundError) Unwrap() error { return ErrMessageNotFound }
+
+// ParameterError is returned when Postal responds with the
+// "parameter-error" status because a parameter is missing or invalid.
+type ParameterError struct {
+	// Fields maps request fields to the problems found with them,
+	// if Postal included them.
+	Fields map[string][]string
+
+	// Err is the underlying API error.
+	Err *Error
+}
+
+// Error returns a string representation of the error.
+func (e *ParameterError) Error() string { return e.Err.Error() }
+
+// Unwrap returns ErrParameter.
+func (e *ParameterError) Unwrap() error { return ErrParameter }
+
+// TransportError is returned when a request did not produce a complete
+// response, for example because the connection was refused, reset, or
+// timed out, or the response body was cut short. The underlying error,
+// including any context error, is available through errors.Is/As.
+type TransportError struct {
+	// Method is the HTTP method of the request.
+	Method string
+
+	// URL is the URL of the request.
+	URL string
+
+	// StatusCode is the HTTP status code if the failure happened while
+	// reading the response body, or 0 if no response was received.
+	StatusCode int
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns a string representation of the error.
+func (e *TransportError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("error reading response body: %v", e.Err)
+	}
+	return fmt.Sprintf("error performing request: %v", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// DecodeError is returned when a response was received but its body could
+// not be decoded.
+type DecodeError struct {
+	// What describes the value being decoded: "response",
+	// "error response", or "send response".
+	What string
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Body is the raw data that could not be decoded.
+	Body []byte
+
+	// Err is the underlying decoding error.
+	Err error
+}
+
+// Error returns a string representation of the error.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("error unmarshaling %s: %v", e.What, e.Err)
+}
+
+// Unwrap returns the underlying decoding error.
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// InvalidRequestError is returned when WithValidation is enabled and a
+// request fails its Validate method. The request is not sent. It matches
+// ErrValidation with errors.Is, along with the sentinel of every Postal
+// error code among its field errors, e.g. ErrNoRecipients.
+type InvalidRequestError struct {
+	// Errors lists the problems found, with the path of each field.
+	Errors models.ValidationErrors
+}
+
+// Error returns a string representation of the error.
+func (e *InvalidRequestError) Error() string {
+	return "invalid request: " + e.Errors.Error()
+}
+
+// Unwrap returns ErrValidation, the sentinel errors for the field errors'
+// codes, and the field errors themselves.
+func (e *InvalidRequestError) Unwrap() []error {
+	errs := []error{ErrValidation}
+	seen := make(map[string]bool)
+	for _, fe := range e.Errors {
+		if sentinel, ok := codeSentinels[fe.Code]; ok && !seen[fe.Code] {
+			seen[fe.Code] = true
+			errs = append(errs, sentinel)
+		}
+	}
+	return append(errs, e.Errors)
+}
+
+// ErrorType classifies err into a short, low-cardinality name suitable for
+// metric labels and trace attributes: the Postal error code for API errors
+// (or their status if they have no code), "canceled" or
+// "deadline_exceeded" for calls ended by their context, "transport" for
+// network failures, "decode" for unreadable responses, "invalid_request"
+// for requests that failed WithValidation's checks, and "other" otherwise.
+// It returns "" for a nil error.
+func ErrorType(err error) string {
+	var (
+		apiErr       *Error
+		transportErr *TransportError
+		decodeErr    *DecodeError
+		invalidErr   *InvalidRequestError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &apiErr):
+		if apiErr.ErrorCode != "" {
+			return apiErr.ErrorCode
+		}
+		return apiErr.Status
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.As(err, &transportErr):
+		return "transport"
+	case errors.As(err, &decodeErr):
+		return "decode"
+	case errors.As(err, &invalidErr):
+		return "invalid_request"
+	}
+	return "other"
+}
@@ -0,0 +1,302 @@
+package postalclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newErrorServer returns a test server that always responds with the given
+// status code and body.
+func newErrorServer(statusCode int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestErrorCodesMatchSentinels(t *testing.T) {
+	tests := []struct {
+		code     string
+		sentinel error
+	}{
+		{CodeValidationError, ErrValidation},
+		{CodeNoRecipients, ErrNoRecipients},
+		{CodeNoContent, ErrNoContent},
+		{CodeTooManyToAddresses, ErrTooManyToAddresses},
+		{CodeTooManyCCAddresses, ErrTooManyCCAddresses},
+		{CodeTooManyBCCAddresses, ErrTooManyBCCAddresses},
+		{CodeFromAddressMissing, ErrFromAddressMissing},
+		{CodeUnauthenticatedFromAddress, ErrUnauthenticatedFromAddress},
+		{CodeAttachmentMissingName, ErrAttachmentMissingName},
+		{CodeAttachmentMissingData, ErrAttachmentMissingData},
+		{CodeMessageNotFound, ErrMessageNotFound},
+		{CodeAccessDenied, ErrAccessDenied},
+		{CodeInvalidServerAPIKey, ErrInvalidServerAPIKey},
+		{CodeServerSuspended, ErrServerSuspended},
+	}
+
+	for _, tt := range tests {
+		// Postal puts the code and message in the data payload
+		server := newErrorServer(http.StatusOK, `{
+			"status": "error",
+			"time": 0.01,
+			"flags": {},
+			"data": {"code": "`+tt.code+`", "message": "Something went wrong"}
+		}`)
+
+		client := NewClient("test-api-key")
+		client.BaseURL = server.URL
+		_, err := client.post("/test", nil)
+		server.Close()
+
+		if !errors.Is(err, tt.sentinel) {
+			t.Errorf("Expected error for code %s to match %v, got '%v'", tt.code, tt.sentinel, err)
+		}
+
+		// The error is still an *Error at the top level
+		apiErr, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("Expected error to be of type *Error, got %T", err)
+		}
+
+		if apiErr.ErrorCode != tt.code {
+			t.Errorf("Expected ErrorCode to be %s, got %s", tt.code, apiErr.ErrorCode)
+		}
+
+		if apiErr.Message != "Something went wrong" {
+			t.Errorf("Expected Message to be taken from data, got %s", apiErr.Message)
+		}
+
+		// Other sentinels don't match
+		if tt.sentinel != ErrValidation && errors.Is(err, ErrValidation) {
+			t.Errorf("Expected error for code %s not to match ErrValidation", tt.code)
+		}
+	}
+}
+
+func TestSendErrorAs(t *testing.T) {
+	server := newErrorServer(http.StatusOK, `{
+		"status": "error",
+		"data": {"code": "TooManyToAddresses", "message": "The maximum number of To addresses has been reached (maximum 50)"}
+	}`)
+	defer server.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	_, err := client.post("/send/message", nil)
+
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) {
+		t.Fatalf("Expected error to be a *SendError, got %T", err)
+	}
+
+	if sendErr.Code != CodeTooManyToAddresses {
+		t.Errorf("Expected code to be %s, got %s", CodeTooManyToAddresses, sendErr.Code)
+	}
+
+	if sendErr.Error() != "postal API error: error - The maximum number of To addresses has been reached (maximum 50)" {
+		t.Errorf("Unexpected error string: %s", sendErr.Error())
+	}
+}
+
+func TestAuthErrorAs(t *testing.T) {
+	server := newErrorServer(http.StatusOK, `{
+		"status": "error",
+		"data": {"code": "InvalidServerAPIKey", "message": "The API token provided in X-Server-API-Key was not valid.", "token": "bad-key"}
+	}`)
+	defer server.Close()
+
+	client := NewClient("bad-key")
+	client.BaseURL = server.URL
+	_, err := client.post("/send/message", nil)
+
+	var authErr *AuthError
+	if !errors.As(err, &authErr) {
+		t.Fatalf("Expected error to be an *AuthError, got %T", err)
+	}
+
+	if authErr.Token != "bad-key" {
+		t.Errorf("Expected token to be bad-key, got %s", authErr.Token)
+	}
+
+	if !errors.Is(err, ErrInvalidServerAPIKey) {
+		t.Errorf("Expected error to match ErrInvalidServerAPIKey")
+	}
+}
+
+func TestNotFoundErrorAs(t *testing.T) {
+	server := newErrorServer(http.StatusOK, `{
+		"status": "error",
+		"data": {"code": "MessageNotFound", "message": "No message found matching provided ID", "id": 123}
+	}`)
+	defer server.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	_, err := client.GetMessage(123)
+
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Expected error to be a *NotFoundError, got %T", err)
+	}
+
+	if notFound.ID != 123 {
+		t.Errorf("Expected ID to be 123, got %d", notFound.ID)
+	}
+}
+
+func TestParameterErrorAs(t *testing.T) {
+	server := newErrorServer(http.StatusOK, `{
+		"status": "parameter-error",
+		"data": {"message": "The provided data was not sufficient", "errors": {"from": ["is required"]}}
+	}`)
+	defer server.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	_, err := client.post("/send/message", nil)
+
+	if !errors.Is(err, ErrParameter) {
+		t.Errorf("Expected error to match ErrParameter, got '%v'", err)
+	}
+
+	var paramErr *ParameterError
+	if !errors.As(err, &paramErr) {
+		t.Fatalf("Expected error to be a *ParameterError, got %T", err)
+	}
+
+	if got := paramErr.Fields["from"]; len(got) != 1 || got[0] != "is required" {
+		t.Errorf("Expected field errors for from, got %v", paramErr.Fields)
+	}
+}
+
+func TestUnknownErrorCodeUnwrapsToNil(t *testing.T) {
+	apiErr := &Error{Status: "error", ErrorCode: "SomethingNew"}
+
+	if apiErr.Unwrap() != nil {
+		t.Errorf("Expected unknown code to unwrap to nil, got %v", apiErr.Unwrap())
+	}
+
+	if errors.Is(apiErr, ErrValidation) {
+		t.Errorf("Expected unknown code not to match ErrValidation")
+	}
+}
+
+func TestTopLevelErrorFieldsTakePrecedence(t *testing.T) {
+	apiErr, err := newAPIError([]byte(`{
+		"status": "error",
+		"error_code": "NoContent",
+		"message": "top level",
+		"data": {"code": "NoRecipients", "message": "from data"}
+	}`), http.StatusOK)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if apiErr.ErrorCode != "NoContent" || apiErr.Message != "top level" {
+		t.Errorf("Expected top-level fields to be kept, got %s - %s", apiErr.ErrorCode, apiErr.Message)
+	}
+
+	if apiErr.Details.Code != "NoRecipients" || apiErr.Details.Message != "from data" {
+		t.Errorf("Expected details to be decoded from data, got %+v", apiErr.Details)
+	}
+}
+
+func TestParseErrorDetailsLenient(t *testing.T) {
+	// Not an object
+	if details := parseErrorDetails([]byte(`"oops"`)); details.Code != "" {
+		t.Errorf("Expected empty details, got %+v", details)
+	}
+
+	// Malformed fields are skipped without hiding the others
+	details := parseErrorDetails([]byte(`{"code": "MessageNotFound", "errors": "not a map", "id": "42"}`))
+	if details.Code != "MessageNotFound" {
+		t.Errorf("Expected code to be MessageNotFound, got %s", details.Code)
+	}
+
+	if details.ID != 42 {
+		t.Errorf("Expected ID to be 42, got %d", details.ID)
+	}
+
+	if details.Errors != nil {
+		t.Errorf("Expected errors to be nil, got %v", details.Errors)
+	}
+}
+
+func TestTransportErrorAs(t *testing.T) {
+	client := NewClient("test-api-key")
+	client.HTTPClient = &http.Client{Transport: &mockTransport{}}
+
+	_, err := client.post("/test", nil)
+
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) {
+		t.Fatalf("Expected error to be a *TransportError, got %T", err)
+	}
+
+	if transportErr.Method != http.MethodPost {
+		t.Errorf("Expected method to be POST, got %s", transportErr.Method)
+	}
+
+	if transportErr.URL != DefaultBaseURL+"/test" {
+		t.Errorf("Expected URL to be %s/test, got %s", DefaultBaseURL, transportErr.URL)
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		t.Errorf("Expected transport error not to be an *Error")
+	}
+}
+
+func TestDecodeErrorAs(t *testing.T) {
+	server := newErrorServer(http.StatusBadGateway, `<html>Bad Gateway</html>`)
+	defer server.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	_, err := client.post("/test", nil)
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("Expected error to be a *DecodeError, got %T", err)
+	}
+
+	if decodeErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected status code to be 502, got %d", decodeErr.StatusCode)
+	}
+
+	if string(decodeErr.Body) != `<html>Bad Gateway</html>` {
+		t.Errorf("Expected body to be kept, got %s", decodeErr.Body)
+	}
+
+	if decodeErr.What != "error response" {
+		t.Errorf("Expected What to be 'error response', got %s", decodeErr.What)
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{&Error{Status: "error", ErrorCode: CodeNoRecipients}, CodeNoRecipients},
+		{&Error{Status: "parameter-error"}, "parameter-error"},
+		{&TransportError{Err: errors.New("connection reset")}, "transport"},
+		{&TransportError{Err: context.DeadlineExceeded}, "deadline_exceeded"},
+		{fmt.Errorf("error waiting for rate limiter: %w", context.Canceled), "canceled"},
+		{&DecodeError{Err: errors.New("bad json")}, "decode"},
+		{&InvalidRequestError{}, "invalid_request"},
+		{errors.New("boom"), "other"},
+	}
+	for _, test := range tests {
+		if got := ErrorType(test.err); got != test.want {
+			t.Errorf("Expected ErrorType(%v) to be %q, got %q", test.err, test.want, got)
+		}
+	}
+}
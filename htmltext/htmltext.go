@@ -0,0 +1,417 @@
+// Package htmltext converts HTML email bodies into readable plain text, for
+// use as the text alternative of a message.
+//
+// Headings are marked with "#", lists are bulleted or numbered and
+// indented, links become numbered footnotes listed at the end, and tables
+// are laid out in aligned columns or, for layout tables, as paragraphs.
+// Scripts, styles, and the document head are left out.
+//
+// Basic usage:
+//
+//	req.PlainBody = htmltext.Convert(req.HTMLBody)
+package htmltext
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// headingMarkers maps heading elements to the marker put before them.
+var headingMarkers = map[string]string{
+	"h1": "#", "h2": "##", "h3": "###", "h4": "####", "h5": "#####", "h6": "######",
+}
+
+// paragraphTags are the elements set apart by blank lines.
+var paragraphTags = map[string]bool{
+	"p": true, "blockquote": true, "pre": true, "dl": true, "figure": true,
+	"address": true, "form": true, "fieldset": true,
+}
+
+// lineTags are the elements that start and end on a line of their own.
+var lineTags = map[string]bool{
+	"div": true, "section": true, "article": true, "header": true, "footer": true,
+	"main": true, "nav": true, "aside": true, "center": true, "dt": true, "dd": true,
+	"figcaption": true, "body": true, "html": true, "caption": true,
+}
+
+// skippedTags are the elements whose content is left out.
+var skippedTags = map[string]bool{
+	"head": true, "title": true, "template": true,
+}
+
+// Convert returns a plain text rendering of src, an HTML document or
+// fragment. It never fails: malformed markup is rendered as well as it
+// can be.
+func Convert(src string) string {
+	c := &converter{w: &writer{}, footnotes: make(map[string]int)}
+	tokenize(src, c.handle)
+
+	// Close tables and links left open
+	for len(c.tables) > 0 {
+		c.endTable()
+	}
+	c.endLink()
+
+	text := c.w.String()
+	if len(c.links) > 0 {
+		var b strings.Builder
+		b.WriteString(text)
+		b.WriteString("\n")
+		for i, link := range c.links {
+			fmt.Fprintf(&b, "\n[%d] %s", i+1, link)
+		}
+		text = strings.TrimLeft(b.String(), "\n")
+	}
+	return text
+}
+
+// converter turns a stream of tokens into text.
+type converter struct {
+	// w receives the text, either the document's or a table cell's.
+	w *writer
+
+	// skip is the depth of skipped elements the tokens are in.
+	skip int
+
+	// link is the open link, if any.
+	link *link
+
+	// links holds the footnoted URLs, and footnotes their numbers.
+	links     []string
+	footnotes map[string]int
+
+	// tables is the stack of open tables.
+	tables []*table
+}
+
+// link is an open <a> element.
+type link struct {
+	href string
+	text strings.Builder
+}
+
+// handle processes a token.
+func (c *converter) handle(t token) {
+	if c.skip > 0 {
+		if t.kind != textToken && skippedTags[t.name] {
+			if t.kind == startToken {
+				c.skip++
+			} else if t.kind == endToken {
+				c.skip--
+			}
+		}
+		return
+	}
+
+	switch t.kind {
+	case textToken:
+		c.text(t.text)
+	case startToken:
+		c.start(t)
+	case endToken:
+		c.end(t.name)
+	}
+}
+
+// text writes text from the document.
+func (c *converter) text(s string) {
+	if c.link != nil {
+		c.link.text.WriteString(s)
+	}
+	c.w.text(s)
+}
+
+// start processes a start tag.
+func (c *converter) start(t token) {
+	w := c.w
+	switch name := t.name; {
+	case skippedTags[name]:
+		if !t.selfClosing {
+			c.skip++
+		}
+	case paragraphTags[name]:
+		w.lineBreak(2)
+		if name == "blockquote" {
+			w.quote++
+		} else if name == "pre" {
+			w.pre++
+			w.preStart = true
+		}
+	case lineTags[name]:
+		w.lineBreak(1)
+	case headingMarkers[name] != "":
+		w.lineBreak(2)
+		w.word(headingMarkers[name])
+		w.space = true
+	case name == "br":
+		w.lineBreak(w.breaks + 1)
+	case name == "hr":
+		w.lineBreak(2)
+		w.word(strings.Repeat("-", 40))
+		w.lineBreak(2)
+	case name == "ul" || name == "ol":
+		w.startList(name == "ol", t.attr("start"))
+	case name == "li":
+		w.startItem()
+	case name == "img":
+		if alt := strings.TrimSpace(t.attr("alt")); alt != "" {
+			c.text(" " + alt + " ")
+		}
+	case name == "a":
+		c.endLink()
+		if href, ok := t.attrs["href"]; ok {
+			c.link = &link{href: strings.TrimSpace(href)}
+		}
+	case name == "table":
+		// Footnote a link that wraps the table before it, not after
+		c.endLink()
+		w.lineBreak(2)
+		c.tables = append(c.tables, &table{outer: w})
+	case name == "tr":
+		if tbl := c.table(); tbl != nil {
+			c.endCell(tbl)
+			tbl.rows = append(tbl.rows, nil)
+		}
+	case name == "td" || name == "th":
+		if tbl := c.table(); tbl != nil {
+			c.endCell(tbl)
+			if len(tbl.rows) == 0 {
+				tbl.rows = append(tbl.rows, nil)
+			}
+			tbl.cell = &writer{}
+			tbl.header = name == "th"
+			c.w = tbl.cell
+		}
+	}
+}
+
+// end processes an end tag.
+func (c *converter) end(name string) {
+	w := c.w
+	switch {
+	case paragraphTags[name]:
+		w.lineBreak(2)
+		if name == "blockquote" && w.quote > 0 {
+			w.quote--
+		} else if name == "pre" && w.pre > 0 {
+			w.pre--
+		}
+	case lineTags[name]:
+		w.lineBreak(1)
+	case headingMarkers[name] != "":
+		w.lineBreak(2)
+	case name == "ul" || name == "ol":
+		w.endList()
+	case name == "a":
+		c.endLink()
+	case name == "td" || name == "th" || name == "tr":
+		if tbl := c.table(); tbl != nil {
+			c.endCell(tbl)
+		}
+	case name == "table":
+		if len(c.tables) > 0 {
+			c.endTable()
+		}
+	}
+}
+
+// endLink closes the open link, adding a footnote for its URL unless the
+// URL is already in its text or only points within the document.
+func (c *converter) endLink() {
+	l := c.link
+	if l == nil {
+		return
+	}
+	c.link = nil
+
+	href := l.href
+	lower := strings.ToLower(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(lower, "javascript:") {
+		return
+	}
+	text := strings.Join(strings.Fields(l.text.String()), " ")
+	if text == href || "mailto:"+text == href || "tel:"+text == href {
+		return
+	}
+	if text == "" {
+		c.w.word(href)
+		return
+	}
+
+	n, ok := c.footnotes[href]
+	if !ok {
+		c.links = append(c.links, href)
+		n = len(c.links)
+		c.footnotes[href] = n
+	}
+	c.w.space = false
+	c.w.word("[" + strconv.Itoa(n) + "]")
+}
+
+// token kinds.
+const (
+	textToken = iota
+	startToken
+	endToken
+)
+
+// token is a piece of an HTML document.
+type token struct {
+	kind int
+
+	// text is the unescaped text of a textToken.
+	text string
+
+	// name is the lower-case element name of a tag.
+	name string
+
+	// attrs holds the unescaped attributes of a start tag.
+	attrs map[string]string
+
+	// selfClosing is true for start tags ending in "/>".
+	selfClosing bool
+}
+
+// attr returns the value of the attribute name, or "".
+func (t token) attr(name string) string {
+	return t.attrs[name]
+}
+
+// tokenize splits src into tokens and passes them to fn. Comments,
+// doctypes, and processing instructions are dropped, as is the content of
+// script and style elements.
+func tokenize(src string, fn func(token)) {
+	for len(src) > 0 {
+		i := strings.IndexByte(src, '<')
+		if i < 0 {
+			fn(token{kind: textToken, text: html.UnescapeString(src)})
+			return
+		}
+		if i > 0 {
+			fn(token{kind: textToken, text: html.UnescapeString(src[:i])})
+			src = src[i:]
+		}
+
+		switch {
+		case strings.HasPrefix(src, "<!--"):
+			src = skipPast(src[4:], "-->")
+		case strings.HasPrefix(src, "<!") || strings.HasPrefix(src, "<?"):
+			src = skipPast(src[2:], ">")
+		case len(src) > 1 && (isLetter(src[1]) || src[1] == '/' && len(src) > 2 && isLetter(src[2])):
+			var t token
+			t, src = parseTag(src)
+			fn(t)
+			if t.kind == startToken && (t.name == "script" || t.name == "style") && !t.selfClosing {
+				src = skipRawText(src, t.name)
+			}
+		default:
+			fn(token{kind: textToken, text: "<"})
+			src = src[1:]
+		}
+	}
+}
+
+// skipPast returns what follows the first end in s, or "" if there is
+// none.
+func skipPast(s, end string) string {
+	if i := strings.Index(s, end); i >= 0 {
+		return s[i+len(end):]
+	}
+	return ""
+}
+
+// skipRawText returns what follows the end tag of the raw text element
+// name in s.
+func skipRawText(s, name string) string {
+	lower := strings.ToLower(s)
+	i := strings.Index(lower, "</"+name)
+	if i < 0 {
+		return ""
+	}
+	return skipPast(s[i:], ">")
+}
+
+// parseTag parses the tag at the start of s, which begins with "<" and a
+// letter or "/", and returns it with the rest of s.
+func parseTag(s string) (token, string) {
+	t := token{kind: startToken}
+	i := 1
+	if s[i] == '/' {
+		t.kind = endToken
+		i++
+	}
+	start := i
+	for i < len(s) && !isSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	t.name = strings.ToLower(s[start:i])
+
+	for i < len(s) {
+		for i < len(s) && (isSpace(s[i]) || s[i] == '/') {
+			if s[i] == '/' && i+1 < len(s) && s[i+1] == '>' {
+				t.selfClosing = true
+			}
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			i++
+			break
+		}
+
+		// An attribute name, optionally followed by a value
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '>' && s[i] != '=' && s[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(s[start:i])
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					end = len(s) - i - 1
+				}
+				value = s[i+1 : i+1+end]
+				i = min(i+2+end, len(s))
+			} else {
+				start := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+		if t.kind == startToken && name != "" {
+			if t.attrs == nil {
+				t.attrs = make(map[string]string)
+			}
+			if _, ok := t.attrs[name]; !ok {
+				t.attrs[name] = html.UnescapeString(value)
+			}
+		}
+	}
+	return t, s[i:]
+}
+
+// isLetter reports whether b is an ASCII letter.
+func isLetter(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// isSpace reports whether b is HTML white space.
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
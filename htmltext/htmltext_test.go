@@ -0,0 +1,91 @@
+package htmltext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"empty", "", ""},
+		{"plain text", "Hello, world", "Hello, world"},
+		{"white space", "<p>  Hello,\n\t world  </p>", "Hello, world"},
+		{"entities", "<p>Caf&eacute; &lt;b&gt; &amp; &#8364;5&nbsp;off</p>", "Café <b> & €5 off"},
+		{"inline elements", "<p>Hello <b>bold</b><i>italic</i> text</p>", "Hello bolditalic text"},
+		{"paragraphs", "<p>One</p><p>Two</p><div>Three</div><div>Four</div>", "One\n\nTwo\n\nThree\nFour"},
+		{"line breaks", "One<br>Two<br/><br />Three", "One\nTwo\n\nThree"},
+		{"headings", "<h1>Title</h1><p>Intro</p><h3>Section</h3>Text", "# Title\n\nIntro\n\n### Section\n\nText"},
+		{"rule", "<p>Above</p><hr><p>Below</p>", "Above\n\n" + strings.Repeat("-", 40) + "\n\nBelow"},
+		{"quote", "<p>Said:</p><blockquote><p>One</p><p>Two<br>Three</p></blockquote>", "Said:\n\n> One\n\n> Two\n> Three"},
+		{"pre", "<pre>\n  a  b\n\n    c\n</pre><p>After</p>", "  a  b\n\n    c\n\nAfter"},
+		{"image", `<p>Logo: <img src="logo.png" alt="Acme"><img src="spacer.gif"></p>`, "Logo: Acme"},
+		{"comments and doctype", "<!DOCTYPE html><!-- <p>hidden</p> --><p>Shown</p>", "Shown"},
+		{"stray angle bracket", "<p>1 < 2 and 3 <4</p>", "1 < 2 and 3 <4"},
+		{"upper case tags", "<P>One<BR>Two</P><UL><LI>Three</LI></UL>", "One\nTwo\n\n* Three"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Convert(tt.html); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertStripsHiddenContent(t *testing.T) {
+	html := `<html><head><title>Title</title><style>p { color: red; }</style></head>
+<body><script type="text/javascript">if (a < b) { document.write("<p>x</p>"); }</script>
+<p>Visible</p><STYLE>.a{}</STYLE><template><p>Template</p></template></body></html>`
+
+	if got := Convert(html); got != "Visible" {
+		t.Errorf("Expected only the visible text, got %q", got)
+	}
+}
+
+func TestConvertLinks(t *testing.T) {
+	html := `<p><a href="https://example.com/a?x=1&amp;y=2">First</a>, <a href='https://example.com/b'>second</a>,
+<a href="https://example.com/a?x=1&amp;y=2">first again</a>, <a href="https://example.com">https://example.com</a>,
+<a href="mailto:ada@example.com">ada@example.com</a>, <a href="#top">top</a>, <a href="javascript:void(0)">script</a>,
+<a href="https://example.com/c"></a> and <a name="anchor">anchor</a>.</p>`
+
+	want := "First[1], second[2], first again[1], https://example.com, ada@example.com, top, script, https://example.com/c and anchor.\n\n" +
+		"[1] https://example.com/a?x=1&y=2\n[2] https://example.com/b"
+	if got := Convert(html); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestConvertUnclosed(t *testing.T) {
+	html := `<p>Start <a href="https://example.com">link<table><tr><td>a<td>b`
+
+	want := "Start link[1]\n\na | b\n\n[1] https://example.com"
+	if got := Convert(html); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	tag, rest := parseTag(`<A HREF="x y" data-x='1' title=a&amp;b checked/>rest`)
+	if tag.kind != startToken || tag.name != "a" || !tag.selfClosing {
+		t.Errorf("Expected a self-closing a tag, got %+v", tag)
+	}
+	if tag.attr("href") != "x y" || tag.attr("data-x") != "1" || tag.attr("title") != "a&b" {
+		t.Errorf("Expected the attributes to be parsed, got %v", tag.attrs)
+	}
+	if _, ok := tag.attrs["checked"]; !ok {
+		t.Errorf("Expected the checked attribute, got %v", tag.attrs)
+	}
+	if rest != "rest" {
+		t.Errorf("Expected rest, got %q", rest)
+	}
+
+	tag, rest = parseTag(`</div >`)
+	if tag.kind != endToken || tag.name != "div" || rest != "" {
+		t.Errorf("Expected a div end tag, got %+v and %q", tag, rest)
+	}
+}
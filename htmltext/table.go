@@ -0,0 +1,123 @@
+// This file contains the rendering of tables.
+package htmltext
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// table is an open table element. The text of each cell is written to a
+// writer of its own, and the table is laid out when it is closed.
+type table struct {
+	// outer is the writer the table is written to.
+	outer *writer
+
+	rows [][]cell
+
+	// cell is the writer of the open cell, if any, and header is true if
+	// it is a th element.
+	cell   *writer
+	header bool
+}
+
+// cell is the text of a table cell.
+type cell struct {
+	text   string
+	header bool
+}
+
+// table returns the innermost open table, or nil.
+func (c *converter) table() *table {
+	if n := len(c.tables); n > 0 {
+		return c.tables[n-1]
+	}
+	return nil
+}
+
+// endCell closes the open cell of t, if any.
+func (c *converter) endCell(t *table) {
+	if t.cell == nil {
+		return
+	}
+	row := &t.rows[len(t.rows)-1]
+	*row = append(*row, cell{text: t.cell.String(), header: t.header})
+	t.cell = nil
+	c.w = t.outer
+}
+
+// endTable closes the innermost table and writes it out.
+func (c *converter) endTable() {
+	t := c.table()
+	c.endCell(t)
+	c.tables = c.tables[:len(c.tables)-1]
+	c.w = t.outer
+	t.render(c.w)
+}
+
+// render writes t to w. Tables with one column or with cells spanning
+// several lines, as used to lay out HTML email, are written as a
+// paragraph per cell. Other tables are written as aligned columns
+// separated by " | ", with a line under a header row.
+func (t *table) render(w *writer) {
+	var rows [][]cell
+	var cols int
+	var multiline bool
+	for _, row := range t.rows {
+		empty := true
+		for _, cell := range row {
+			if cell.text != "" {
+				empty = false
+			}
+			if strings.Contains(cell.text, "\n") {
+				multiline = true
+			}
+		}
+		if !empty {
+			rows = append(rows, row)
+			cols = max(cols, len(row))
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	w.lineBreak(2)
+	if cols == 1 || multiline {
+		for _, row := range rows {
+			for _, cell := range row {
+				if cell.text != "" {
+					w.block(cell.text)
+				}
+			}
+		}
+		return
+	}
+
+	widths := make([]int, cols)
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell.text))
+		}
+	}
+
+	for _, row := range rows {
+		header := true
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = cell.text + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell.text))
+			header = header && cell.header
+		}
+		w.lineBreak(1)
+		w.lines(strings.TrimRight(strings.Join(cells, " | "), " "))
+
+		if header {
+			rule := make([]string, cols)
+			for i, width := range widths {
+				rule[i] = strings.Repeat("-", width)
+			}
+			w.lineBreak(1)
+			w.lines(strings.Join(rule, "-|-"))
+		}
+	}
+	w.lineBreak(2)
+}
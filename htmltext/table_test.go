@@ -0,0 +1,56 @@
+package htmltext
+
+import "testing"
+
+func TestConvertTables(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			"data table",
+			"<table><thead><tr><th>Item</th><th>Qty</th><th>Price</th></tr></thead>" +
+				"<tbody><tr><td>Apples</td><td>3</td><td>€1.50</td></tr><tr><td>Kiwi</td><td>12</td><td>€6.00</td></tr></tbody></table>",
+			"Item   | Qty | Price\n-------|-----|------\nApples | 3   | €1.50\nKiwi   | 12  | €6.00",
+		},
+		{
+			"ragged rows",
+			"<table><tr><td>a</td><td>b</td><td>c</td></tr><tr><td>long</td></tr><tr><td></td><td></td></tr></table>",
+			"a    | b | c\nlong",
+		},
+		{
+			"layout table",
+			"<p>Before</p><table width=\"100%\"><tr><td><h1>News</h1></td></tr><tr><td><p>One</p><p>Two</p></td></tr></table><p>After</p>",
+			"Before\n\n# News\n\nOne\n\nTwo\n\nAfter",
+		},
+		{
+			"multi-line cells",
+			"<table><tr><td>Logo</td><td>Line one<br>Line two</td></tr></table>",
+			"Logo\n\nLine one\nLine two",
+		},
+		{
+			"nested tables",
+			"<table><tr><td><table><tr><td>Name</td><td>Ada</td></tr><tr><td>Role</td><td>Admin</td></tr></table></td></tr></table>",
+			"Name | Ada\nRole | Admin",
+		},
+		{
+			"links in cells",
+			`<table><tr><td><a href="https://example.com/1">One</a></td><td>x</td></tr></table>`,
+			"One[1] | x\n\n[1] https://example.com/1",
+		},
+		{
+			"caption",
+			"<table><caption>Totals</caption><tr><td>a</td><td>1</td></tr></table>",
+			"Totals\n\na | 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Convert(tt.html); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+// This file contains writer, which lays out text in lines, paragraphs,
+// lists, and quotes.
+package htmltext
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// writer accumulates text, collapsing white space outside pre elements.
+// Line breaks are requested rather than written, so that adjacent blocks
+// are separated by a single blank line however deeply they are nested.
+type writer struct {
+	b strings.Builder
+
+	// breaks is the number of line breaks to write before the next text.
+	breaks int
+
+	// started is true once text has been written.
+	started bool
+
+	// midLine is true when the current line has text on it.
+	midLine bool
+
+	// space is true when a space is due before the next word.
+	space bool
+
+	// quote is the depth of blockquote elements.
+	quote int
+
+	// pre is the depth of pre elements, and preStart is true until the
+	// first text in one.
+	pre      int
+	preStart bool
+
+	// lists is the stack of open lists.
+	lists []*list
+
+	// marker is the list marker to put at the start of the next line.
+	marker string
+}
+
+// list is an open ul or ol element.
+type list struct {
+	ordered bool
+
+	// n is the number of the current item, and items the number of items
+	// so far.
+	n     int
+	items int
+
+	// base is the indent of the list's markers, and indent that of the
+	// lines following a marker.
+	base   string
+	indent string
+}
+
+// String returns the text written so far, without trailing white space on
+// its lines or leading and trailing blank lines.
+func (w *writer) String() string {
+	lines := strings.Split(w.b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// lineBreak requests at least n line breaks before the next text: 1 to
+// start a new line and 2 to leave a blank one.
+func (w *writer) lineBreak(n int) {
+	w.breaks = max(w.breaks, min(n, 3))
+	w.space = false
+}
+
+// text writes s, collapsing its white space to single spaces unless it is
+// in a pre element.
+func (w *writer) text(s string) {
+	if w.pre > 0 {
+		if w.preStart {
+			// Like browsers, ignore a line break straight after <pre>
+			s = strings.TrimPrefix(strings.TrimPrefix(s, "\r"), "\n")
+			w.preStart = s == ""
+		}
+		w.lines(strings.ReplaceAll(s, "\r\n", "\n"))
+		return
+	}
+
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		w.space = w.space || s != ""
+		return
+	}
+	if strings.TrimLeftFunc(s, unicode.IsSpace) != s {
+		w.space = true
+	}
+	for i, word := range words {
+		if i > 0 {
+			w.space = true
+		}
+		w.word(word)
+	}
+	if strings.TrimRightFunc(s, unicode.IsSpace) != s {
+		w.space = true
+	}
+}
+
+// word writes s, preceded by a space if one is due.
+func (w *writer) word(s string) {
+	w.prepare()
+	w.b.WriteString(s)
+}
+
+// lines writes each line of s as is, on lines of its own.
+func (w *writer) lines(s string) {
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			w.breaks++
+		}
+		if line != "" {
+			w.space = false
+			w.word(line)
+		}
+	}
+}
+
+// block writes s as a paragraph of its own.
+func (w *writer) block(s string) {
+	w.lineBreak(2)
+	w.lines(s)
+	w.lineBreak(2)
+}
+
+// prepare writes the requested line breaks and, at the start of a line,
+// the quote and list prefix, or else a due space.
+func (w *writer) prepare() {
+	if w.breaks > 0 && w.started {
+		w.b.WriteString(strings.Repeat("\n", w.breaks))
+		w.midLine = false
+	}
+	w.breaks = 0
+	w.started = true
+
+	if !w.midLine {
+		w.b.WriteString(w.prefix())
+		w.midLine = true
+	} else if w.space {
+		w.b.WriteByte(' ')
+	}
+	w.space = false
+}
+
+// prefix returns the start of a new line: a "> " for each quote, then the
+// pending list marker or the indent of the current list item.
+func (w *writer) prefix() string {
+	p := strings.Repeat("> ", w.quote)
+	if w.marker != "" {
+		p += w.marker
+		w.marker = ""
+	} else if n := len(w.lists); n > 0 {
+		p += w.lists[n-1].indent
+	}
+	return p
+}
+
+// startList opens a list, numbered from start if it is ordered and start
+// is a number.
+func (w *writer) startList(ordered bool, start string) {
+	if len(w.lists) == 0 {
+		w.lineBreak(2)
+	} else {
+		w.lineBreak(1)
+	}
+
+	var base string
+	if n := len(w.lists); n > 0 {
+		base = w.lists[n-1].indent
+	}
+	l := &list{ordered: ordered, base: base, indent: base}
+	if n, err := strconv.Atoi(strings.TrimSpace(start)); err == nil && ordered {
+		l.n = n - 1
+	}
+	w.lists = append(w.lists, l)
+}
+
+// endList closes the innermost list.
+func (w *writer) endList() {
+	if len(w.lists) == 0 {
+		return
+	}
+	w.lists = w.lists[:len(w.lists)-1]
+	w.marker = ""
+	if len(w.lists) == 0 {
+		w.lineBreak(2)
+	} else {
+		w.lineBreak(1)
+	}
+}
+
+// startItem starts a list item on a new line, with a "* " marker or its
+// number. Items outside a list are treated as being in an unordered one.
+func (w *writer) startItem() {
+	if len(w.lists) == 0 {
+		w.startList(false, "")
+	}
+	l := w.lists[len(w.lists)-1]
+	if l.items > 0 {
+		// Keep items together even when the last one ended with a paragraph
+		w.breaks = 0
+	}
+	w.lineBreak(1)
+
+	l.n++
+	l.items++
+	marker := "* "
+	if l.ordered {
+		marker = strconv.Itoa(l.n) + ". "
+	}
+	w.marker = l.base + marker
+	l.indent = l.base + strings.Repeat(" ", len(marker))
+}
@@ -0,0 +1,47 @@
+package htmltext
+
+import "testing"
+
+func TestConvertLists(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"unordered", "<p>Items:</p><ul><li>One</li><li>Two</li></ul><p>After</p>", "Items:\n\n* One\n* Two\n\nAfter"},
+		{"ordered", "<ol><li>One<li>Two</ol>", "1. One\n2. Two"},
+		{"start", `<p>Intro</p><ol start="9"><li>Nine</li><li>Ten</li></ol>`, "Intro\n\n9. Nine\n10. Ten"},
+		{"nested", "<ul><li>One<ol><li>A</li><li>B<ul><li>x</li></ul></li></ol></li><li>Two</li></ul>", "* One\n  1. A\n  2. B\n     * x\n* Two"},
+		{"multi-line items", "<ol><li><p>First para</p><p>Second para</p></li><li>Next<br>line</li></ol>", "1. First para\n\n   Second para\n2. Next\n   line"},
+		{"quoted list", "<blockquote><ul><li>One</li><li>Two</li></ul></blockquote>", "> * One\n> * Two"},
+		{"item outside a list", "<li>Loose</li>", "* Loose"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Convert(tt.html); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWriterBreaks(t *testing.T) {
+	w := &writer{}
+
+	// Breaks before the first text are dropped
+	w.lineBreak(2)
+	w.text("  one ")
+	w.text("two")
+	w.lineBreak(1)
+	w.lineBreak(2)
+	w.lineBreak(1)
+	w.text("three")
+	w.lineBreak(9)
+	w.text("four")
+	w.lineBreak(2)
+
+	if got := w.String(); got != "one two\n\nthree\n\n\nfour" {
+		t.Errorf("Expected the requested breaks, got %q", got)
+	}
+}
@@ -0,0 +1,346 @@
+// This file contains client-side idempotency for sends: keys carried by
+// the context, the store that remembers the outcome of each key, and the
+// middleware that answers repeated sends from it.
+package postalclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// IdempotencyKeyHeader is the header that carries a send's idempotency key,
+// both on the API request and in the message sent with SendMessage.
+const IdempotencyKeyHeader = "X-Idempotency-Key"
+
+// ErrAmbiguousOutcome is returned for a send whose idempotency key was used
+// by an earlier send that may or may not have reached Postal, when no
+// IdempotencyConfig.Resolver could settle the question.
+var ErrAmbiguousOutcome = errors.New("postal: outcome of an earlier send with the same idempotency key is unknown")
+
+// idempotencyKey is the context key for idempotency keys.
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a copy of ctx that carries key. Sends made
+// with the returned context by a client using IdempotencyMiddleware are
+// performed at most once per key.
+//
+// Example:
+//
+//	ctx := postalclient.WithIdempotencyKey(ctx, "order-1234-confirmation")
+//	resp, err := client.SendMessageContext(ctx, req)
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the idempotency key carried by ctx, or
+// "" if there is none.
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// IdempotencyState is the state of an idempotency key in an
+// IdempotencyStore.
+type IdempotencyState string
+
+const (
+	// IdempotencyPending means a send with the key was started but its
+	// outcome is not known: it is in progress, or it failed in a way that
+	// leaves open whether Postal accepted the message.
+	IdempotencyPending IdempotencyState = "pending"
+
+	// IdempotencyCompleted means Postal accepted the message sent with the
+	// key. The record holds its response.
+	IdempotencyCompleted IdempotencyState = "completed"
+)
+
+// IdempotencyRecord is what an IdempotencyStore remembers about a key.
+type IdempotencyRecord struct {
+	// State is the state of the key.
+	State IdempotencyState `json:"state"`
+
+	// Response is Postal's response once the state is
+	// IdempotencyCompleted. It is nil if an inner middleware replaced the
+	// response with another type; repeated sends then get an empty
+	// response.
+	Response *models.SendMessageResponse `json:"response,omitempty"`
+
+	// UpdatedAt is when the record was last saved.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IdempotencyStore remembers the outcome of sends by idempotency key.
+// Implementations must be safe for concurrent use. To protect sends made
+// by several processes, use a store they share, such as a database table.
+type IdempotencyStore interface {
+	// Get returns the record for key, or nil if there is none.
+	Get(ctx context.Context, key string) (*IdempotencyRecord, error)
+
+	// Put saves the record for key, replacing any previous one.
+	Put(ctx context.Context, key string, record *IdempotencyRecord) error
+
+	// Delete removes the record for key, if any.
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore that keeps records in
+// memory for a limited time.
+type MemoryIdempotencyStore struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	records map[string]*IdempotencyRecord
+
+	// now returns the current time. Tests replace it.
+	now func() time.Time
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore whose
+// records expire ttl after they were last saved. If ttl is zero, records
+// never expire.
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		ttl:     ttl,
+		records: make(map[string]*IdempotencyRecord),
+		now:     time.Now,
+	}
+}
+
+// Get implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	if !ok {
+		return nil, nil
+	}
+	if s.ttl > 0 && s.now().Sub(record.UpdatedAt) > s.ttl {
+		delete(s.records, key)
+		return nil, nil
+	}
+	r := *record
+	return &r, nil
+}
+
+// Put implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Put(ctx context.Context, key string, record *IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := *record
+	s.records[key] = &r
+
+	// Drop expired records now and then so the map doesn't grow forever
+	if s.ttl > 0 && len(s.records)%100 == 0 {
+		now := s.now()
+		for k, v := range s.records {
+			if now.Sub(v.UpdatedAt) > s.ttl {
+				delete(s.records, k)
+			}
+		}
+	}
+	return nil
+}
+
+// Delete implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// IdempotencyConfig configures IdempotencyMiddleware.
+type IdempotencyConfig struct {
+	// Store remembers the outcome of each key. If nil, a
+	// MemoryIdempotencyStore whose records expire after a day is used.
+	Store IdempotencyStore
+
+	// Resolver, if set, is called when an earlier send with the same key
+	// has an unknown outcome, to look up the message it may have sent,
+	// e.g. by its IdempotencyKeyHeader in messages recorded from webhooks.
+	// It returns the original response, or nil if the message was not
+	// sent, in which case the send is made again.
+	//
+	// Without a resolver nothing is looked up: such sends fail with
+	// ErrAmbiguousOutcome, and it is up to the caller to find out whether
+	// the message went out, and to delete the key from the Store to send
+	// it again. The middleware cannot look the message up itself, since a
+	// send with an unknown outcome got no response carrying a message ID
+	// or token, and Postal's API cannot find messages by header.
+	Resolver func(ctx context.Context, key string, call *Call) (*models.SendMessageResponse, error)
+}
+
+// IdempotencyMiddleware returns a middleware that performs SendMessage and
+// SendRaw calls at most once per idempotency key. Calls without a key
+// (see WithIdempotencyKey) and other operations pass through unchanged.
+//
+// The key is sent in the IdempotencyKeyHeader header of the API request,
+// and also added to the headers of messages sent with SendMessage. A
+// repeated call with a key whose send succeeded returns the original
+// response without sending again. A key whose earlier send failed in a way
+// that leaves open whether Postal accepted it, such as a timeout, is
+// resolved with config.Resolver before sending again, or fails with
+// ErrAmbiguousOutcome if there is no resolver.
+//
+// Postal itself ignores the key, so install this middleware first (the
+// outermost) and keep the client's own retries off for sends that must not
+// be duplicated: a retry made inside a call can still deliver twice.
+//
+// Example:
+//
+//	client, err := postalclient.New("your-api-key",
+//	    postalclient.WithMiddleware(postalclient.IdempotencyMiddleware(postalclient.IdempotencyConfig{})),
+//	)
+func IdempotencyMiddleware(config IdempotencyConfig) Middleware {
+	store := config.Store
+	if store == nil {
+		store = NewMemoryIdempotencyStore(24 * time.Hour)
+	}
+	locks := &keyLocks{held: make(map[string]chan struct{})}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			key := IdempotencyKeyFromContext(ctx)
+			if key == "" || (call.Name != OperationSendMessage && call.Name != OperationSendRaw) {
+				return next(ctx, call)
+			}
+
+			// Sends with the same key run one at a time in this process
+			if err := locks.lock(ctx, key); err != nil {
+				return err
+			}
+			defer locks.unlock(key)
+
+			record, err := store.Get(ctx, key)
+			if err != nil {
+				return fmt.Errorf("error reading idempotency record: %w", err)
+			}
+			if record != nil {
+				resp, err := resolve(ctx, config, key, call, record)
+				if err != nil {
+					return err
+				}
+				if resp != nil {
+					if record.State != IdempotencyCompleted {
+						if err := store.Put(ctx, key, &IdempotencyRecord{State: IdempotencyCompleted, Response: resp, UpdatedAt: time.Now()}); err != nil {
+							return fmt.Errorf("error saving idempotency record: %w", err)
+						}
+					}
+					r := *resp
+					call.Response = &r
+					return nil
+				}
+			}
+
+			// Record the attempt before sending, so a crash or timeout
+			// leaves the key pending
+			if err := store.Put(ctx, key, &IdempotencyRecord{State: IdempotencyPending, UpdatedAt: time.Now()}); err != nil {
+				return fmt.Errorf("error saving idempotency record: %w", err)
+			}
+			stampIdempotencyKey(call, key)
+
+			err = next(ctx, call)
+			if err != nil {
+				if !maybeSent(call, err) {
+					if delErr := store.Delete(ctx, key); delErr != nil {
+						return errors.Join(err, fmt.Errorf("error deleting idempotency record: %w", delErr))
+					}
+				}
+				return err
+			}
+
+			resp, _ := call.Response.(*models.SendMessageResponse)
+			if err := store.Put(ctx, key, &IdempotencyRecord{State: IdempotencyCompleted, Response: resp, UpdatedAt: time.Now()}); err != nil {
+				return fmt.Errorf("error saving idempotency record: %w", err)
+			}
+			return nil
+		}
+	}
+}
+
+// resolve returns the original response for a key that has a record, or nil
+// if the message must be sent again.
+func resolve(ctx context.Context, config IdempotencyConfig, key string, call *Call, record *IdempotencyRecord) (*models.SendMessageResponse, error) {
+	if record.State == IdempotencyCompleted {
+		if record.Response == nil {
+			// The message was sent, but an inner middleware replaced the
+			// response with one that could not be stored
+			return &models.SendMessageResponse{}, nil
+		}
+		return record.Response, nil
+	}
+	if config.Resolver == nil {
+		return nil, fmt.Errorf("%w (key %q)", ErrAmbiguousOutcome, key)
+	}
+	resp, err := config.Resolver(ctx, key, call)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving idempotency key %q: %w", key, err)
+	}
+	return resp, nil
+}
+
+// stampIdempotencyKey adds key to the call's request headers and, for
+// SendMessage, to a copy of the message's headers.
+func stampIdempotencyKey(call *Call, key string) {
+	call.SetHeader(IdempotencyKeyHeader, key)
+	if req, ok := call.Request.(*models.SendMessageRequest); ok && req != nil {
+		stamped := *req
+		stamped.Headers = maps.Clone(req.Headers)
+		if stamped.Headers == nil {
+			stamped.Headers = make(map[string]string, 1)
+		}
+		stamped.Headers[IdempotencyKeyHeader] = key
+		call.Request = &stamped
+	}
+}
+
+// maybeSent reports whether a failed call may have been accepted by Postal.
+// Only a call whose single attempt was answered with a Postal error, or
+// that made no attempt at all, is known not to have sent the message.
+func maybeSent(call *Call, err error) bool {
+	if call.Attempts == 0 {
+		return false
+	}
+	var apiErr *Error
+	return call.Attempts > 1 || !errors.As(err, &apiErr)
+}
+
+// keyLocks serializes work per key.
+type keyLocks struct {
+	mu   sync.Mutex
+	held map[string]chan struct{}
+}
+
+// lock waits until key is free, or ctx ends, and then holds it.
+func (l *keyLocks) lock(ctx context.Context, key string) error {
+	for {
+		l.mu.Lock()
+		ch, ok := l.held[key]
+		if !ok {
+			l.held[key] = make(chan struct{})
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// unlock releases key.
+func (l *keyLocks) unlock(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	close(l.held[key])
+	delete(l.held, key)
+}
@@ -0,0 +1,304 @@
+package postalclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// idempotencyTestServer is a test server that assigns increasing message
+// IDs and can be told to fail or stall.
+type idempotencyTestServer struct {
+	*httptest.Server
+	sends  atomic.Int32
+	mode   atomic.Value // "", "error", or "stall"
+	header atomic.Value // last IdempotencyKeyHeader received
+	body   atomic.Value // last request body
+}
+
+// newIdempotencyTestServer starts an idempotencyTestServer.
+func newIdempotencyTestServer(t *testing.T) *idempotencyTestServer {
+	t.Helper()
+	s := &idempotencyTestServer{}
+	s.mode.Store("")
+	// Create a test server
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := s.sends.Add(1)
+		s.header.Store(r.Header.Get(IdempotencyKeyHeader))
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		s.body.Store(body)
+
+		switch s.mode.Load() {
+		case "error":
+			_, _ = w.Write([]byte(`{"status":"error","time":0.1,"flags":{},"data":{"code":"NoRecipients","message":"no recipients"}}`))
+			return
+		case "stall":
+			time.Sleep(200 * time.Millisecond)
+		}
+		fmt.Fprintf(w, `{"status":"success","time":0.1,"flags":{},"data":{"message_id":%d,"token":"tok%d"}}`, n, n)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// newIdempotencyTestClient returns a client for server with the given
+// idempotency config and a short timeout.
+func newIdempotencyTestClient(t *testing.T, server *idempotencyTestServer, config IdempotencyConfig) *Client {
+	t.Helper()
+	client, err := New("test-api-key",
+		WithBaseURL(server.URL+"/api/v1"),
+		WithTimeout(50*time.Millisecond),
+		WithMiddleware(IdempotencyMiddleware(config)),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return client
+}
+
+// idempotencyTestMessage returns a valid message.
+func idempotencyTestMessage() *models.SendMessageRequest {
+	return &models.SendMessageRequest{From: "a@example.com", To: []string{"b@example.org"}, PlainBody: "Hi"}
+}
+
+func TestIdempotencyRepeatedSend(t *testing.T) {
+	server := newIdempotencyTestServer(t)
+	client := newIdempotencyTestClient(t, server, IdempotencyConfig{})
+	ctx := WithIdempotencyKey(context.Background(), "order-1")
+
+	req := idempotencyTestMessage()
+	first, err := client.SendMessageContext(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, err := client.SendMessageContext(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if server.sends.Load() != 1 {
+		t.Errorf("Expected 1 send, got %d", server.sends.Load())
+	}
+
+	if second.MessageID != first.MessageID || second.Token != first.Token {
+		t.Errorf("Expected the original response, got %+v and %+v", first, second)
+	}
+
+	// The key is sent in the request and message headers
+	if got := server.header.Load(); got != "order-1" {
+		t.Errorf("Expected the request header to be order-1, got %v", got)
+	}
+
+	headers, _ := server.body.Load().(map[string]any)["headers"].(map[string]any)
+	if headers[IdempotencyKeyHeader] != "order-1" {
+		t.Errorf("Expected the message header to be order-1, got %v", headers)
+	}
+
+	if req.Headers != nil {
+		t.Errorf("Expected the caller's request to be unchanged, got %v", req.Headers)
+	}
+
+	// Other keys and calls without a key are sent
+	if _, err := client.SendMessageContext(WithIdempotencyKey(context.Background(), "order-2"), req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := client.SendMessage(req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if server.sends.Load() != 3 {
+		t.Errorf("Expected 3 sends, got %d", server.sends.Load())
+	}
+}
+
+func TestIdempotencyCompletedWithoutResponse(t *testing.T) {
+	server := newIdempotencyTestServer(t)
+	store := NewMemoryIdempotencyStore(0)
+	ctx := WithIdempotencyKey(context.Background(), "order-1")
+	if err := store.Put(ctx, "order-1", &IdempotencyRecord{State: IdempotencyCompleted, UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var resolved int
+	client := newIdempotencyTestClient(t, server, IdempotencyConfig{
+		Store: store,
+		Resolver: func(ctx context.Context, key string, call *Call) (*models.SendMessageResponse, error) {
+			resolved++
+			return nil, nil
+		},
+	})
+
+	// The message was sent, so it is neither resolved nor sent again
+	resp, err := client.SendMessageContext(ctx, idempotencyTestMessage())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp == nil || resolved != 0 || server.sends.Load() != 0 {
+		t.Errorf("Expected an empty response without a resolve or send, got %+v, %d resolves, and %d sends", resp, resolved, server.sends.Load())
+	}
+}
+
+func TestIdempotencyRejectedSendCanBeRetried(t *testing.T) {
+	server := newIdempotencyTestServer(t)
+	client := newIdempotencyTestClient(t, server, IdempotencyConfig{})
+	ctx := WithIdempotencyKey(context.Background(), "order-1")
+
+	server.mode.Store("error")
+	if _, err := client.SendMessageContext(ctx, idempotencyTestMessage()); !errors.Is(err, ErrNoRecipients) {
+		t.Fatalf("Expected ErrNoRecipients, got %v", err)
+	}
+
+	// Postal rejected the message, so sending again is safe
+	server.mode.Store("")
+	if _, err := client.SendMessageContext(ctx, idempotencyTestMessage()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if server.sends.Load() != 2 {
+		t.Errorf("Expected 2 sends, got %d", server.sends.Load())
+	}
+}
+
+func TestIdempotencyAmbiguousOutcome(t *testing.T) {
+	server := newIdempotencyTestServer(t)
+	store := NewMemoryIdempotencyStore(0)
+	ctx := WithIdempotencyKey(context.Background(), "order-1")
+
+	// The first send times out, so it may have been accepted
+	server.mode.Store("stall")
+	client := newIdempotencyTestClient(t, server, IdempotencyConfig{Store: store})
+	if _, err := client.SendMessageContext(ctx, idempotencyTestMessage()); err == nil {
+		t.Fatal("Expected a timeout, got nil")
+	}
+	server.mode.Store("")
+
+	// Without a resolver, the send is refused
+	if _, err := client.SendMessageContext(ctx, idempotencyTestMessage()); !errors.Is(err, ErrAmbiguousOutcome) {
+		t.Errorf("Expected ErrAmbiguousOutcome, got %v", err)
+	}
+	if server.sends.Load() != 1 {
+		t.Errorf("Expected 1 send, got %d", server.sends.Load())
+	}
+
+	// A resolver that finds the original message answers with it
+	var resolved []string
+	client = newIdempotencyTestClient(t, server, IdempotencyConfig{
+		Store: store,
+		Resolver: func(ctx context.Context, key string, call *Call) (*models.SendMessageResponse, error) {
+			resolved = append(resolved, key)
+			return &models.SendMessageResponse{MessageID: 1, Token: "tok1"}, nil
+		},
+	})
+	resp, err := client.SendMessageContext(ctx, idempotencyTestMessage())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.MessageID != 1 || len(resolved) != 1 || server.sends.Load() != 1 {
+		t.Errorf("Expected the resolved message 1 without a send, got %+v %v %d", resp, resolved, server.sends.Load())
+	}
+
+	// The resolved outcome is remembered
+	if _, err := client.SendMessageContext(ctx, idempotencyTestMessage()); err != nil || len(resolved) != 1 {
+		t.Errorf("Expected the stored response to be used, got %v %v", err, resolved)
+	}
+
+	record, _ := store.Get(context.Background(), "order-1")
+	if record == nil || record.State != IdempotencyCompleted {
+		t.Errorf("Expected a completed record, got %+v", record)
+	}
+}
+
+func TestIdempotencyResolverNotFoundResends(t *testing.T) {
+	server := newIdempotencyTestServer(t)
+	store := NewMemoryIdempotencyStore(0)
+	ctx := WithIdempotencyKey(context.Background(), "order-1")
+	if err := store.Put(ctx, "order-1", &IdempotencyRecord{State: IdempotencyPending, UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	client := newIdempotencyTestClient(t, server, IdempotencyConfig{
+		Store: store,
+		Resolver: func(ctx context.Context, key string, call *Call) (*models.SendMessageResponse, error) {
+			return nil, nil
+		},
+	})
+	if _, err := client.SendMessageContext(ctx, idempotencyTestMessage()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if server.sends.Load() != 1 {
+		t.Errorf("Expected 1 send, got %d", server.sends.Load())
+	}
+
+	// Resolver errors fail the call
+	failing := newIdempotencyTestClient(t, server, IdempotencyConfig{
+		Resolver: func(ctx context.Context, key string, call *Call) (*models.SendMessageResponse, error) {
+			return nil, errors.New("lookup failed")
+		},
+	})
+	server.mode.Store("stall")
+	_, _ = failing.SendMessageContext(ctx, idempotencyTestMessage())
+	if _, err := failing.SendMessageContext(ctx, idempotencyTestMessage()); err == nil || errors.Is(err, ErrAmbiguousOutcome) {
+		t.Errorf("Expected the resolver's error, got %v", err)
+	}
+}
+
+func TestIdempotencyConcurrentSends(t *testing.T) {
+	server := newIdempotencyTestServer(t)
+	client := newIdempotencyTestClient(t, server, IdempotencyConfig{})
+	ctx := WithIdempotencyKey(context.Background(), "order-1")
+
+	var wg sync.WaitGroup
+	ids := make([]int, 10)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.SendRawContext(ctx, &models.SendRawRequest{MailFrom: "a@example.com", RcptTo: []string{"b@example.org"}, Data: "SGk="})
+			if err == nil {
+				ids[i] = resp.MessageID
+			}
+		}()
+	}
+	wg.Wait()
+
+	if server.sends.Load() != 1 {
+		t.Errorf("Expected 1 send, got %d", server.sends.Load())
+	}
+	for _, id := range ids {
+		if id != 1 {
+			t.Errorf("Expected every call to return message 1, got %v", ids)
+			break
+		}
+	}
+}
+
+func TestMemoryIdempotencyStoreExpiry(t *testing.T) {
+	store := NewMemoryIdempotencyStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "k", &IdempotencyRecord{State: IdempotencyCompleted, UpdatedAt: now}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if record, _ := store.Get(ctx, "k"); record == nil {
+		t.Fatal("Expected a record, got nil")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if record, _ := store.Get(ctx, "k"); record != nil {
+		t.Errorf("Expected the record to expire, got %+v", record)
+	}
+
+	if record, _ := store.Get(ctx, "missing"); record != nil {
+		t.Errorf("Expected no record, got %+v", record)
+	}
+}
@@ -0,0 +1,309 @@
+// This file contains the client's structured logging: the per-call and
+// per-attempt records written to Client.Logger, the redaction rules applied
+// to addresses, subjects, and bodies, and the debug body dumps.
+package postalclient
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// redactedValue replaces the API key in logged headers and bodies.
+const redactedValue = "[REDACTED]"
+
+// credentialHeaders are the request headers scrubbed from body dumps: the
+// API key and credentials that may be added with WithHeader or
+// HeaderMiddleware, e.g. for a proxy in front of Postal.
+var credentialHeaders = []string{"X-Server-API-Key", "Authorization", "Proxy-Authorization", "Cookie"}
+
+// Redaction says how a sensitive value is written to the client's logs.
+type Redaction int
+
+const (
+	// RedactDrop leaves the value out of the logs. It is the default.
+	RedactDrop Redaction = iota
+
+	// RedactHash logs a short hash of the value, so records about the same
+	// recipient or message can be correlated without revealing it.
+	RedactHash
+
+	// RedactMaskDomain logs only the domain of an address, e.g.
+	// "***@example.com". Values that are not addresses are dropped.
+	RedactMaskDomain
+
+	// RedactNone logs the value as is.
+	RedactNone
+)
+
+// String returns the name of the redaction.
+func (r Redaction) String() string {
+	switch r {
+	case RedactDrop:
+		return "drop"
+	case RedactHash:
+		return "hash"
+	case RedactMaskDomain:
+		return "mask-domain"
+	case RedactNone:
+		return "none"
+	}
+	return "unknown"
+}
+
+// LogOptions controls what the client includes in the records it writes to
+// its Logger. The zero value logs no addresses, subjects, or bodies.
+type LogOptions struct {
+	// Addresses is applied to sender and recipient addresses, including
+	// addresses that appear in error messages.
+	Addresses Redaction
+
+	// Subjects is applied to message subjects.
+	Subjects Redaction
+
+	// Bodies is applied to plain-text and HTML bodies and to raw messages.
+	Bodies Redaction
+
+	// HashKey, if set, keys the hashes written for RedactHash with
+	// HMAC-SHA256, so they cannot be matched against hashes of guessed
+	// addresses. Without it, plain SHA-256 is used.
+	HashKey []byte
+
+	// DumpBodies logs the full request and response of every attempt at
+	// debug level, for local troubleshooting. Dumps are not redacted,
+	// except for the API key and the Authorization, Proxy-Authorization,
+	// and Cookie headers, which are always scrubbed. Do not enable it in
+	// production.
+	DumpBodies bool
+}
+
+// addressPattern matches email addresses in free text, such as Postal's
+// error messages.
+var addressPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(\.[A-Za-z0-9\-]+)+`)
+
+// redact returns value as it should be logged under r, and false if it
+// should be left out.
+func (o *LogOptions) redact(r Redaction, value string) (string, bool) {
+	switch r {
+	case RedactNone:
+		return value, true
+	case RedactHash:
+		var sum []byte
+		if len(o.HashKey) > 0 {
+			mac := hmac.New(sha256.New, o.HashKey)
+			mac.Write([]byte(value))
+			sum = mac.Sum(nil)
+		} else {
+			s := sha256.Sum256([]byte(value))
+			sum = s[:]
+		}
+		return "sha256:" + hex.EncodeToString(sum[:8]), true
+	case RedactMaskDomain:
+		// Strip a display name, as in "Name <user@example.com>"
+		address := strings.TrimSuffix(strings.TrimSpace(value), ">")
+		at := strings.LastIndex(address, "@")
+		if at < 0 || at == len(address)-1 {
+			return "", false
+		}
+		return "***@" + address[at+1:], true
+	}
+	return "", false
+}
+
+// addressAttr returns an attribute for a single address, if it is logged.
+func (o *LogOptions) addressAttr(key, address string) (slog.Attr, bool) {
+	if address == "" {
+		return slog.Attr{}, false
+	}
+	v, ok := o.redact(o.Addresses, address)
+	return slog.String(key, v), ok
+}
+
+// addressesAttr returns an attribute for a list of addresses, if they are
+// logged.
+func (o *LogOptions) addressesAttr(key string, addresses []string) (slog.Attr, bool) {
+	if len(addresses) == 0 || o.Addresses == RedactDrop {
+		return slog.Attr{}, false
+	}
+	values := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if v, ok := o.redact(o.Addresses, address); ok {
+			values = append(values, v)
+		}
+	}
+	return slog.Any(key, values), len(values) > 0
+}
+
+// textAttr returns an attribute for a subject or body, if it is logged.
+func (o *LogOptions) textAttr(r Redaction, key, value string) (slog.Attr, bool) {
+	if value == "" {
+		return slog.Attr{}, false
+	}
+	v, ok := o.redact(r, value)
+	return slog.String(key, v), ok
+}
+
+// redactText applies the address rule to every address found in s.
+func (o *LogOptions) redactText(s string) string {
+	if o.Addresses == RedactNone {
+		return s
+	}
+	return addressPattern.ReplaceAllStringFunc(s, func(address string) string {
+		if v, ok := o.redact(o.Addresses, address); ok {
+			return v
+		}
+		return "[address]"
+	})
+}
+
+// requestAttrs returns the redacted attributes describing a call's request.
+func (o *LogOptions) requestAttrs(request any) []slog.Attr {
+	var attrs []slog.Attr
+	add := func(attr slog.Attr, ok bool) {
+		if ok {
+			attrs = append(attrs, attr)
+		}
+	}
+
+	switch req := request.(type) {
+	case *models.SendMessageRequest:
+		attrs = append(attrs, slog.Int("recipients", len(req.To)+len(req.CC)+len(req.BCC)))
+		add(o.addressAttr("from", req.From))
+		add(o.addressAttr("sender", req.Sender))
+		add(o.addressAttr("reply_to", req.ReplyTo))
+		add(o.addressesAttr("to", req.To))
+		add(o.addressesAttr("cc", req.CC))
+		add(o.addressesAttr("bcc", req.BCC))
+		add(o.textAttr(o.Subjects, "subject", req.Subject))
+		add(o.textAttr(o.Bodies, "plain_body", req.PlainBody))
+		add(o.textAttr(o.Bodies, "html_body", req.HTMLBody))
+		if len(req.Attachments) > 0 {
+			attrs = append(attrs, slog.Int("attachments", len(req.Attachments)))
+		}
+	case *models.SendRawRequest:
+		attrs = append(attrs, slog.Int("recipients", len(req.RcptTo)))
+		add(o.addressAttr("mail_from", req.MailFrom))
+		add(o.addressesAttr("rcpt_to", req.RcptTo))
+		add(o.textAttr(o.Bodies, "data", req.Data))
+	case *MessageRequest:
+		attrs = append(attrs, slog.Int("message_id", req.ID))
+	case *DeliveriesRequest:
+		attrs = append(attrs, slog.Int("message_id", req.ID))
+	}
+	return attrs
+}
+
+// logCall logs a completed API call to the client's Logger at debug level,
+// with its request redacted according to the client's LogOptions.
+func (c *Client) logCall(ctx context.Context, call *Call, duration time.Duration, err error) {
+	if c.Logger == nil || !c.Logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("operation", call.Name),
+		slog.String("method", call.Method),
+		slog.String("path", call.Path),
+		slog.Duration("duration", duration),
+		slog.Int("status_code", call.StatusCode),
+		slog.Int("attempts", call.Attempts),
+	}
+
+	var apiErr *Error
+	switch {
+	case errors.As(err, &apiErr):
+		attrs = append(attrs, slog.String("status", apiErr.Status))
+		if apiErr.ErrorCode != "" {
+			attrs = append(attrs, slog.String("error_code", apiErr.ErrorCode))
+		}
+	case err == nil && call.Envelope != nil:
+		attrs = append(attrs, slog.String("status", call.Envelope.Status))
+	}
+
+	if request := c.LogOptions.requestAttrs(call.Request); len(request) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "request", Value: slog.GroupValue(request...)})
+	}
+	if resp, ok := call.Response.(*models.SendMessageResponse); ok && err == nil {
+		attrs = append(attrs, slog.Int("message_id", resp.MessageID))
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.String("error", c.LogOptions.redactText(err.Error())))
+		c.Logger.LogAttrs(ctx, slog.LevelDebug, "postal call failed", attrs...)
+		return
+	}
+	c.Logger.LogAttrs(ctx, slog.LevelDebug, "postal call completed", attrs...)
+}
+
+// logAttempt logs an attempt of a retried call to the client's Logger at
+// debug level, with addresses in error messages redacted. A call made in a
+// single attempt is described by its logCall record alone.
+func (c *Client) logAttempt(ctx context.Context, a RetryAttempt) {
+	if c.Logger == nil || a.Attempt == 1 && !a.Retry || !c.Logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", a.Method),
+		slog.String("path", a.Path),
+		slog.Int("attempt", a.Attempt),
+		slog.Int("status_code", a.StatusCode),
+		slog.Duration("duration", a.Duration),
+	}
+	if a.Err != nil {
+		var apiErr *Error
+		if errors.As(a.Err, &apiErr) && apiErr.ErrorCode != "" {
+			attrs = append(attrs, slog.String("error_code", apiErr.ErrorCode))
+		}
+		attrs = append(attrs, slog.String("error", c.LogOptions.redactText(a.Err.Error())))
+	}
+	switch {
+	case a.Err == nil:
+		c.Logger.LogAttrs(ctx, slog.LevelDebug, "postal request succeeded", attrs...)
+	case a.Retry:
+		attrs = append(attrs, slog.Duration("delay", a.Delay))
+		c.Logger.LogAttrs(ctx, slog.LevelDebug, "postal request failed, retrying", attrs...)
+	default:
+		c.Logger.LogAttrs(ctx, slog.LevelDebug, "postal request failed", attrs...)
+	}
+}
+
+// dumpAttempt logs the full request and response of an attempt when
+// LogOptions.DumpBodies is set. The API key is scrubbed from the headers
+// and bodies, and credential headers are scrubbed too.
+func (c *Client) dumpAttempt(ctx context.Context, call *Call, attempt int, req *http.Request, reqBody []byte, result attemptResult) {
+	if c.Logger == nil || !c.LogOptions.DumpBodies || !c.Logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	header := req.Header.Clone()
+	for _, name := range credentialHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, redactedValue)
+		}
+	}
+	c.Logger.LogAttrs(ctx, slog.LevelDebug, "postal request dump",
+		slog.String("method", call.Method),
+		slog.String("path", call.Path),
+		slog.Int("attempt", attempt),
+		slog.Any("request_header", header),
+		slog.String("request_body", c.scrubAPIKey(reqBody)),
+		slog.Int("status_code", result.statusCode),
+		slog.String("response_body", c.scrubAPIKey(result.body)),
+	)
+}
+
+// scrubAPIKey returns body as a string with any occurrence of the API key
+// replaced.
+func (c *Client) scrubAPIKey(body []byte) string {
+	if c.APIKey == "" {
+		return string(body)
+	}
+	return strings.ReplaceAll(string(body), c.APIKey, redactedValue)
+}
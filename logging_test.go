@@ -0,0 +1,245 @@
+package postalclient
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// newLoggingTestClient returns a client for a test server that answers every
+// request with body and statusCode, logging at debug level to buf.
+func newLoggingTestClient(t *testing.T, statusCode int, body string, opts LogOptions, buf *bytes.Buffer) *Client {
+	t.Helper()
+	// Create a test server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New("secret-api-key",
+		WithBaseURL(server.URL+"/api/v1"),
+		WithLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		WithLogOptions(opts),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return client
+}
+
+// testLogMessage returns a message whose addresses, subject, and body can be
+// looked for in the logs.
+func testLogMessage() *models.SendMessageRequest {
+	return &models.SendMessageRequest{
+		From:      "alice@sender.example",
+		To:        []string{"bob@example.org"},
+		BCC:       []string{"carol@example.net"},
+		Subject:   "Quarterly results",
+		PlainBody: "The numbers are in",
+	}
+}
+
+func TestLogCallRedaction(t *testing.T) {
+	const success = `{"status":"success","time":0.1,"flags":{},"data":{"message_id":7,"token":"abc"}}`
+	bobHash, _ := (&LogOptions{}).redact(RedactHash, "bob@example.org")
+
+	tests := []struct {
+		name    string
+		opts    LogOptions
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "drop by default",
+			opts:    LogOptions{},
+			want:    []string{"request.recipients=2", "message_id=7"},
+			notWant: []string{"example", "Quarterly", "numbers", "request.to", "request.subject"},
+		},
+		{
+			name:    "hash",
+			opts:    LogOptions{Addresses: RedactHash, Subjects: RedactHash},
+			want:    []string{"request.to=[" + bobHash + "]", "request.subject=sha256:"},
+			notWant: []string{"example", "Quarterly", "numbers"},
+		},
+		{
+			name:    "mask domain",
+			opts:    LogOptions{Addresses: RedactMaskDomain, Subjects: RedactMaskDomain},
+			want:    []string{"request.from=***@sender.example", "request.to=[***@example.org]", "request.bcc=[***@example.net]"},
+			notWant: []string{"alice", "bob", "carol", "Quarterly", "numbers"},
+		},
+		{
+			name: "none",
+			opts: LogOptions{Addresses: RedactNone, Subjects: RedactNone, Bodies: RedactNone},
+			want: []string{"request.from=alice@sender.example", `request.subject="Quarterly results"`, `request.plain_body="The numbers are in"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			client := newLoggingTestClient(t, http.StatusOK, success, tt.opts, &buf)
+			if _, err := client.SendMessage(testLogMessage()); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			logs := buf.String()
+			if !strings.Contains(logs, `level=DEBUG msg="postal call completed" operation=send.message method=POST path=/send/message`) {
+				t.Errorf("Expected a call record, got:\n%s", logs)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(logs, want) {
+					t.Errorf("Expected logs to contain %q, got:\n%s", want, logs)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(logs, notWant) {
+					t.Errorf("Expected logs not to contain %q, got:\n%s", notWant, logs)
+				}
+			}
+		})
+	}
+}
+
+func TestLogCallErrorRedactsAddresses(t *testing.T) {
+	const failure = `{"status":"error","time":0.2,"flags":{},"data":{"code":"UnauthenticatedFromAddress","message":"The From address alice@sender.example is not authorised"}}`
+
+	var buf bytes.Buffer
+	client := newLoggingTestClient(t, http.StatusOK, failure, LogOptions{}, &buf)
+	if _, err := client.SendMessage(testLogMessage()); !errors.Is(err, ErrUnauthenticatedFromAddress) {
+		t.Fatalf("Expected ErrUnauthenticatedFromAddress, got %v", err)
+	}
+
+	logs := buf.String()
+	for _, want := range []string{
+		`msg="postal call failed"`,
+		"status=error error_code=UnauthenticatedFromAddress",
+		"The From address [address] is not authorised",
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("Expected logs to contain %q, got:\n%s", want, logs)
+		}
+	}
+
+	// A call made in one attempt is logged once
+	if strings.Contains(logs, "postal request") {
+		t.Errorf("Expected no attempt records, got:\n%s", logs)
+	}
+
+	if strings.Contains(logs, "alice") {
+		t.Errorf("Expected the address to be redacted, got:\n%s", logs)
+	}
+}
+
+func TestLogAttempts(t *testing.T) {
+	var buf bytes.Buffer
+	client := newLoggingTestClient(t, http.StatusServiceUnavailable, "Service Unavailable", LogOptions{}, &buf)
+	client.RetryPolicy = fastRetryPolicy(2)
+	if _, err := client.SendMessage(testLogMessage()); err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+
+	logs := buf.String()
+	for _, want := range []string{
+		`level=DEBUG msg="postal request failed, retrying" method=POST path=/send/message attempt=1 status_code=503`,
+		`level=DEBUG msg="postal request failed" method=POST path=/send/message attempt=2 status_code=503`,
+		`level=DEBUG msg="postal call failed"`,
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("Expected logs to contain %q, got:\n%s", want, logs)
+		}
+	}
+
+	if strings.Contains(logs, "level=WARN") || strings.Contains(logs, "level=ERROR") {
+		t.Errorf("Expected every record at debug level, got:\n%s", logs)
+	}
+
+	// Without a debug logger no attempts are logged
+	buf.Reset()
+	client.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+	if _, err := client.SendMessage(testLogMessage()); err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no logs at info level, got:\n%s", buf.String())
+	}
+}
+
+func TestLogDumpBodies(t *testing.T) {
+	// The response echoes the API key to check it is scrubbed everywhere
+	const success = `{"status":"success","time":0.1,"flags":{},"data":{"message_id":7,"token":"secret-api-key"}}`
+
+	var buf bytes.Buffer
+	client := newLoggingTestClient(t, http.StatusOK, success, LogOptions{DumpBodies: true}, &buf)
+	client.Header = http.Header{"Authorization": {"Bearer proxy-token"}, "X-Tenant": {"acme"}}
+	if _, err := client.SendMessage(testLogMessage()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	logs := buf.String()
+	for _, want := range []string{
+		`msg="postal request dump" method=POST path=/send/message attempt=1`,
+		"X-Server-Api-Key:[[REDACTED]]",
+		"Authorization:[[REDACTED]]",
+		"X-Tenant:[acme]",
+		"Quarterly results",
+		`\"token\":\"[REDACTED]\"`,
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("Expected logs to contain %q, got:\n%s", want, logs)
+		}
+	}
+
+	if strings.Contains(logs, "secret-api-key") || strings.Contains(logs, "proxy-token") {
+		t.Errorf("Expected the credentials to be scrubbed, got:\n%s", logs)
+	}
+
+	// Without a debug logger nothing is dumped
+	buf.Reset()
+	client.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+	if _, err := client.SendMessage(testLogMessage()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no logs at info level, got:\n%s", buf.String())
+	}
+}
+
+func TestRedactHashKey(t *testing.T) {
+	plain := &LogOptions{}
+	keyed := &LogOptions{HashKey: []byte("k1")}
+	other := &LogOptions{HashKey: []byte("k2")}
+
+	a, _ := plain.redact(RedactHash, "bob@example.org")
+	b, _ := keyed.redact(RedactHash, "bob@example.org")
+	c, _ := other.redact(RedactHash, "bob@example.org")
+	if a == b || b == c {
+		t.Errorf("Expected keyed hashes to differ, got %s %s %s", a, b, c)
+	}
+
+	again, _ := keyed.redact(RedactHash, "bob@example.org")
+	if again != b {
+		t.Errorf("Expected hashes to be stable, got %s and %s", b, again)
+	}
+}
+
+func TestRedactMaskDomain(t *testing.T) {
+	opts := &LogOptions{}
+	tests := map[string]string{
+		"bob@example.org":       "***@example.org",
+		"Bob <bob@example.org>": "***@example.org",
+		"not an address":        "",
+	}
+	for in, want := range tests {
+		got, ok := opts.redact(RedactMaskDomain, in)
+		if got != want || ok != (want != "") {
+			t.Errorf("Expected %q to be masked as %q, got %q (%v)", in, want, got, ok)
+		}
+	}
+}
@@ -4,43 +4,143 @@
 package postalclient
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 
+	"github.com/Suhaibinator/postalclient-go/htmltext"
 	"github.com/Suhaibinator/postalclient-go/models"
 )
 
+// Expansion names an optional section of a message that GetMessage can
+// ask Postal to include in its response.
+type Expansion string
+
+// Expansions supported by the /messages/message endpoint.
+const (
+	// ExpansionStatus populates Message.Status.
+	ExpansionStatus Expansion = "status"
+
+	// ExpansionDetails populates Message.Details.
+	ExpansionDetails Expansion = "details"
+
+	// ExpansionInspection populates Message.Inspection.
+	ExpansionInspection Expansion = "inspection"
+
+	// ExpansionPlainBody populates Message.PlainBody.
+	ExpansionPlainBody Expansion = "plain_body"
+
+	// ExpansionHTMLBody populates Message.HTMLBody.
+	ExpansionHTMLBody Expansion = "html_body"
+
+	// ExpansionAttachments populates Message.Attachments.
+	ExpansionAttachments Expansion = "attachments"
+
+	// ExpansionHeaders populates Message.Headers.
+	ExpansionHeaders Expansion = "headers"
+
+	// ExpansionRawMessage populates Message.RawMessage.
+	ExpansionRawMessage Expansion = "raw_message"
+)
+
+// GetMessageOption configures a GetMessage request.
+type GetMessageOption func(*getMessageOptions)
+
+// getMessageOptions holds the options for a GetMessage request.
+type getMessageOptions struct {
+	expansions []Expansion
+	all        bool
+}
+
+// WithExpansions asks Postal to include the given sections of the message
+// in the response. It can be passed more than once.
+func WithExpansions(expansions ...Expansion) GetMessageOption {
+	return func(o *getMessageOptions) {
+		o.expansions = append(o.expansions, expansions...)
+	}
+}
+
+// WithAllExpansions asks Postal to include every section of the message in
+// the response. This includes the raw message and attachment data, which
+// can make the response large.
+func WithAllExpansions() GetMessageOption {
+	return func(o *getMessageOptions) {
+		o.all = true
+	}
+}
+
+// MessageRequest is the request body of the /messages/message endpoint.
+// Middleware sees it as Call.Request for GetMessage calls.
+type MessageRequest struct {
+	// ID is the ID of the message.
+	ID int
+
+	// Expansions lists the sections of the message to include.
+	Expansions []Expansion
+
+	// AllExpansions asks for every section of the message, overriding
+	// Expansions.
+	AllExpansions bool
+}
+
+// MarshalJSON encodes the request in the format Postal expects, where
+// _expansions is either a list of names or true.
+func (r MessageRequest) MarshalJSON() ([]byte, error) {
+	body := map[string]interface{}{
+		"id": r.ID,
+	}
+	if r.AllExpansions {
+		body["_expansions"] = true
+	} else if len(r.Expansions) > 0 {
+		body["_expansions"] = r.Expansions
+	}
+	return json.Marshal(body)
+}
+
+// DeliveriesRequest is the request body of the /messages/deliveries
+// endpoint. Middleware sees it as Call.Request for GetMessageDeliveries
+// calls.
+type DeliveriesRequest struct {
+	// ID is the ID of the message.
+	ID int `json:"id"`
+}
+
 // GetMessage retrieves details about a message with the given ID.
 //
 // This method calls the /messages/message endpoint to retrieve information
-// about a specific message, including its status, content, and metadata.
+// about a specific message. By default only the message's ID and token are
+// returned; pass WithExpansions or WithAllExpansions to also retrieve its
+// status, details, content, and metadata.
 //
 // Example:
 //
-//	message, err := client.GetMessage(123)
+//	message, err := client.GetMessage(123,
+//	    postalclient.WithExpansions(postalclient.ExpansionStatus, postalclient.ExpansionDetails))
 //	if err != nil {
 //	    log.Fatalf("Error getting message: %v", err)
 //	}
-//	fmt.Printf("Message details - ID: %d, Token: %s\n", message.ID, message.Token)
-func (c *Client) GetMessage(id int) (*models.Message, error) {
-	// Create the request body with the message ID
-	body := map[string]interface{}{
-		"id": id,
-	}
+//	fmt.Printf("Message %d is %s (held: %t)\n", message.ID, message.Status.Status, message.Status.Held)
+func (c *Client) GetMessage(id int, opts ...GetMessageOption) (*models.Message, error) {
+	return c.GetMessageContext(context.Background(), id, opts...)
+}
 
-	// Make the request to the API
-	resp, err := c.post("/messages/message", body)
-	if err != nil {
-		return nil, err
+// GetMessageContext is like GetMessage but binds the request to ctx.
+// The request is aborted if ctx is cancelled or its deadline expires.
+func (c *Client) GetMessageContext(ctx context.Context, id int, opts ...GetMessageOption) (*models.Message, error) {
+	var options getMessageOptions
+	for _, opt := range opts {
+		opt(&options)
 	}
 
-	// Unmarshal the response data into a Message struct
-	var message models.Message
-	if err := json.Unmarshal(resp.Data, &message); err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %w", err)
+	// Create the request with the message ID and any expansions
+	req := &MessageRequest{
+		ID:            id,
+		Expansions:    options.expansions,
+		AllExpansions: options.all,
 	}
 
-	return &message, nil
+	// Make the request to the API
+	return callAPI[*models.Message](ctx, c, OperationMessage, "/messages/message", req, "response")
 }
 
 // GetMessageDeliveries retrieves delivery attempts for a message with the given ID.
@@ -60,24 +160,15 @@ func (c *Client) GetMessage(id int) (*models.Message, error) {
 //	    fmt.Printf("Delivery %d - Status: %s\n", i+1, delivery.Status)
 //	}
 func (c *Client) GetMessageDeliveries(id int) ([]models.Delivery, error) {
-	// Create the request body with the message ID
-	body := map[string]interface{}{
-		"id": id,
-	}
+	return c.GetMessageDeliveriesContext(context.Background(), id)
+}
 
+// GetMessageDeliveriesContext is like GetMessageDeliveries but binds the
+// request to ctx. The request is aborted if ctx is cancelled or its deadline
+// expires.
+func (c *Client) GetMessageDeliveriesContext(ctx context.Context, id int) ([]models.Delivery, error) {
 	// Make the request to the API
-	resp, err := c.post("/messages/deliveries", body)
-	if err != nil {
-		return nil, err
-	}
-
-	// Unmarshal the response data into a slice of Delivery structs
-	var deliveries []models.Delivery
-	if err := json.Unmarshal(resp.Data, &deliveries); err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %w", err)
-	}
-
-	return deliveries, nil
+	return callAPI[[]models.Delivery](ctx, c, OperationDeliveries, "/messages/deliveries", &DeliveriesRequest{ID: id}, "response")
 }
 
 // SendMessage sends an email message using the Postal API.
@@ -101,19 +192,21 @@ func (c *Client) GetMessageDeliveries(id int) ([]models.Delivery, error) {
 //	}
 //	fmt.Printf("Message sent! ID: %d, Token: %s\n", resp.MessageID, resp.Token)
 func (c *Client) SendMessage(req *models.SendMessageRequest) (*models.SendMessageResponse, error) {
-	// Make the request to the API
-	resp, err := c.post("/send/message", req)
-	if err != nil {
-		return nil, err
-	}
-
-	// Unmarshal the response data into a SendMessageResponse struct
-	var sendResp models.SendMessageResponse
-	if err := json.Unmarshal(resp.Data, &sendResp); err != nil {
-		return nil, fmt.Errorf("error unmarshaling send response: %w", err)
-	}
+	return c.SendMessageContext(context.Background(), req)
+}
 
-	return &sendResp, nil
+// SendMessageContext is like SendMessage but binds the request to ctx.
+// The request is aborted if ctx is cancelled or its deadline expires, which
+// makes it possible to tie a send to the lifetime of an inbound request.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+//	defer cancel()
+//	resp, err := client.SendMessageContext(ctx, req)
+func (c *Client) SendMessageContext(ctx context.Context, req *models.SendMessageRequest) (*models.SendMessageResponse, error) {
+	// Make the request to the API
+	return callAPI[*models.SendMessageResponse](ctx, c, OperationSendMessage, "/send/message", req, "send response")
 }
 
 // SendRaw sends a raw RFC2822 message using the Postal API.
@@ -147,17 +240,48 @@ func (c *Client) SendMessage(req *models.SendMessageRequest) (*models.SendMessag
 //	}
 //	fmt.Printf("Raw message sent! ID: %d, Token: %s\n", resp.MessageID, resp.Token)
 func (c *Client) SendRaw(req *models.SendRawRequest) (*models.SendMessageResponse, error) {
+	return c.SendRawContext(context.Background(), req)
+}
+
+// SendRawContext is like SendRaw but binds the request to ctx.
+// The request is aborted if ctx is cancelled or its deadline expires.
+func (c *Client) SendRawContext(ctx context.Context, req *models.SendRawRequest) (*models.SendMessageResponse, error) {
 	// Make the request to the API
-	resp, err := c.post("/send/raw", req)
-	if err != nil {
-		return nil, err
-	}
+	return callAPI[*models.SendMessageResponse](ctx, c, OperationSendRaw, "/send/raw", req, "send response")
+}
 
-	// Unmarshal the response data into a SendMessageResponse struct
-	var sendResp models.SendMessageResponse
-	if err := json.Unmarshal(resp.Data, &sendResp); err != nil {
-		return nil, fmt.Errorf("error unmarshaling send response: %w", err)
+// prepareRequest applies the client's GeneratePlainText and
+// ValidateRequests settings to the request of a send call. It runs in the
+// innermost handler, so middleware sees the message as it was sent, and
+// middleware and the client's Logger see invalid requests fail like any
+// other call, with no attempt made.
+func (c *Client) prepareRequest(call *Call) error {
+	switch req := call.Request.(type) {
+	case *models.SendMessageRequest:
+		if req != nil && c.GeneratePlainText && req.PlainBody == "" && req.HTMLBody != "" {
+			// Work on a copy so the caller's request is left as it was
+			withText := *req
+			withText.PlainBody = htmltext.Convert(req.HTMLBody)
+			req = &withText
+			call.Request = req
+		}
+		if req != nil && c.ValidateRequests {
+			return validateRequest(req.Validate())
+		}
+	case *models.SendRawRequest:
+		if req != nil && c.ValidateRequests {
+			return validateRequest(req.Validate())
+		}
 	}
+	return nil
+}
 
-	return &sendResp, nil
+// validateRequest wraps the error returned by a request's Validate method
+// in an *InvalidRequestError.
+func validateRequest(err error) error {
+	var errs models.ValidationErrors
+	if errors.As(err, &errs) {
+		return &InvalidRequestError{Errors: errs}
+	}
+	return err
 }
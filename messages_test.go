@@ -1,7 +1,9 @@
 package postalclient
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -321,6 +323,49 @@ func TestSendMessage(t *testing.T) {
 	}
 }
 
+func TestSendMessageRecipientMessages(t *testing.T) {
+	// Create a test server that returns a message for each recipient
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"status": "success",
+			"time": 0.123,
+			"flags": {},
+			"data": {
+				"message_id": 7,
+				"token": "test-token",
+				"messages": {
+					"b@example.com": {"id": 9, "token": "tok-b"},
+					"a@example.com": {"id": 8, "token": "tok-a"}
+				}
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+
+	resp, err := client.SendMessage(&models.SendMessageRequest{To: []string{"a@example.com", "b@example.com"}, PlainBody: "Hi"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if m := resp.Messages["a@example.com"]; m.ID != 8 || m.Token != "tok-a" {
+		t.Errorf("Expected message 8 for a@example.com, got %+v", m)
+	}
+	if ids := resp.MessageIDs(); len(ids) != 2 || ids[0] != 8 || ids[1] != 9 {
+		t.Errorf("Expected message IDs [8 9], got %v", ids)
+	}
+	if tokens := resp.Tokens(); len(tokens) != 2 || tokens[0] != "tok-a" || tokens[1] != "tok-b" {
+		t.Errorf("Expected tokens [tok-a tok-b], got %v", tokens)
+	}
+
+	// Without recipient messages, the message ID is used
+	if ids := (&models.SendMessageResponse{MessageID: 7}).MessageIDs(); len(ids) != 1 || ids[0] != 7 {
+		t.Errorf("Expected message IDs [7], got %v", ids)
+	}
+}
+
 func TestSendMessageError(t *testing.T) {
 	// Create a test server that returns an error
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -545,3 +590,331 @@ func TestSendRawUnmarshalError(t *testing.T) {
 		t.Errorf("Expected error message to contain 'error unmarshaling response', got '%s'", err.Error())
 	}
 }
+
+func TestContextMethodsCancelled(t *testing.T) {
+	// Create a test server that must never be reached
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected no request to reach the server, got %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	// Create client
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+
+	// Create a cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Every context-aware method should honor the cancellation
+	calls := map[string]func() error{
+		"GetMessageContext": func() error {
+			_, err := client.GetMessageContext(ctx, 123)
+			return err
+		},
+		"GetMessageDeliveriesContext": func() error {
+			_, err := client.GetMessageDeliveriesContext(ctx, 123)
+			return err
+		},
+		"SendMessageContext": func() error {
+			_, err := client.SendMessageContext(ctx, &models.SendMessageRequest{To: []string{"recipient@example.com"}})
+			return err
+		},
+		"SendRawContext": func() error {
+			_, err := client.SendRawContext(ctx, &models.SendRawRequest{RcptTo: []string{"recipient@example.com"}})
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %s error to wrap context.Canceled, got '%v'", name, err)
+		}
+	}
+}
+
+func TestGetMessageExpansions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []GetMessageOption
+		want string
+	}{
+		{"none", nil, `null`},
+		{"some", []GetMessageOption{WithExpansions(ExpansionStatus, ExpansionDetails), WithExpansions(ExpansionHeaders)}, `["status","details","headers"]`},
+		{"all", []GetMessageOption{WithExpansions(ExpansionStatus), WithAllExpansions()}, `true`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create a test server that records the expansions
+			var got json.RawMessage
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					ID         int             `json:"id"`
+					Expansions json.RawMessage `json:"_expansions"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("Expected a JSON body, got %v", err)
+				}
+				got = body.Expansions
+				if got == nil {
+					got = json.RawMessage(`null`)
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"status": "success", "time": 0.1, "flags": {}, "data": {"id": 123, "token": "abc"}}`))
+			}))
+			defer server.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = server.URL
+
+			if _, err := client.GetMessage(123, tt.opts...); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("Expected _expansions to be %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetMessageExpandedResponse(t *testing.T) {
+	// Create a test server that returns every expansion, as Postal does
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"status": "success",
+			"time": 0.05,
+			"flags": {},
+			"data": {
+				"id": 123,
+				"token": "abc",
+				"status": {
+					"status": "Held",
+					"last_delivery_attempt": 1700000000.5,
+					"held": true,
+					"hold_expiry": 1700086400
+				},
+				"details": {
+					"rcpt_to": "recipient@example.com",
+					"mail_from": "sender@example.com",
+					"subject": "Hello",
+					"message_id": "abc@example.com",
+					"timestamp": 1699999999.25,
+					"direction": "outgoing",
+					"size": "2048",
+					"bounce": false,
+					"bounce_for_id": null,
+					"tag": "welcome",
+					"received_with_ssl": true
+				},
+				"inspection": {
+					"inspected": true,
+					"spam": true,
+					"spam_score": 6.2,
+					"threat": false,
+					"threat_details": null
+				},
+				"plain_body": "Hi",
+				"html_body": "<p>Hi</p>",
+				"attachments": [
+					{"filename": "a.txt", "content_type": "text/plain", "data": "aGk=", "size": 2, "hash": "c22b5f9178342609428d6f51b2c5af4c0bde6a42"}
+				],
+				"headers": {
+					"subject": ["Hello"],
+					"received": ["from a", "from b"]
+				},
+				"raw_message": "U3ViamVjdDogSGVsbG8NCg0KSGk="
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+
+	message, err := client.GetMessage(123, WithAllExpansions())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Check the status
+	if message.Status == nil || message.Status.Status != "Held" || !message.Status.Held {
+		t.Fatalf("Expected a held status, got %+v", message.Status)
+	}
+
+	if got := message.Status.HoldExpiry.Unix(); got != 1700086400 {
+		t.Errorf("Expected hold expiry to be 1700086400, got %d", got)
+	}
+
+	if got := message.Status.LastDeliveryAttempt.Seconds(); got != 1700000000.5 {
+		t.Errorf("Expected last delivery attempt to be 1700000000.5, got %f", got)
+	}
+
+	// Check the details
+	if message.Details == nil {
+		t.Fatal("Expected details, got nil")
+	}
+
+	if message.Details.Size != 2048 {
+		t.Errorf("Expected size to be 2048, got %d", message.Details.Size)
+	}
+
+	if message.Details.Direction != "outgoing" || message.Details.Tag != "welcome" || !message.Details.ReceivedWithSSL {
+		t.Errorf("Expected details to be decoded, got %+v", message.Details)
+	}
+
+	if message.Details.Timestamp.IsZero() {
+		t.Error("Expected a received timestamp, got zero")
+	}
+
+	// Check the inspection
+	if message.Inspection == nil || !message.Inspection.Spam || message.Inspection.SpamScore != 6.2 {
+		t.Errorf("Expected spam inspection to be decoded, got %+v", message.Inspection)
+	}
+
+	// Check the content
+	if len(message.Attachments) != 1 || message.Attachments[0].Name != "a.txt" {
+		t.Errorf("Expected attachment a.txt, got %+v", message.Attachments)
+	}
+
+	if got := message.Headers.Values("Received"); len(got) != 2 {
+		t.Errorf("Expected 2 Received headers, got %v", got)
+	}
+
+	raw, err := message.DecodeRawMessage()
+	if err != nil || !strings.HasPrefix(string(raw), "Subject: Hello") {
+		t.Errorf("Expected the raw message to decode, got %q (%v)", raw, err)
+	}
+}
+
+func TestSendWithValidation(t *testing.T) {
+	// Create a test server that counts requests
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":{"message_id":1,"token":"tok"}}`))
+	}))
+	defer server.Close()
+
+	// Record what the middleware sees
+	var calls []*Call
+	var errs []error
+	record := func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			err := next(ctx, call)
+			calls = append(calls, call)
+			errs = append(errs, err)
+			return err
+		}
+	}
+	client, err := New("test-api-key", WithBaseURL(server.URL+"/api/v1"), WithValidation(), WithMiddleware(record))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// An invalid message fails without a request
+	_, err = client.SendMessage(&models.SendMessageRequest{From: "not an address", Subject: "Hi"})
+	var invalid *InvalidRequestError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected an *InvalidRequestError, got %v", err)
+	}
+	if len(invalid.Errors) != 3 {
+		t.Errorf("Expected 3 field errors, got %v", invalid.Errors)
+	}
+	if !errors.Is(err, ErrValidation) || !errors.Is(err, ErrNoRecipients) || !errors.Is(err, ErrNoContent) {
+		t.Errorf("Expected the error to match ErrValidation, ErrNoRecipients, and ErrNoContent, got %v", err)
+	}
+	if errors.Is(err, ErrFromAddressMissing) {
+		t.Error("Expected the error not to match ErrFromAddressMissing")
+	}
+	var fieldErr *models.FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "to" {
+		t.Errorf("Expected the first field error to be for to, got %v", fieldErr)
+	}
+
+	_, err = client.SendRaw(&models.SendRawRequest{MailFrom: "a@example.com", RcptTo: []string{"b@example.org"}, Data: "not base64"})
+	if !errors.As(err, &invalid) || invalid.Errors[0].Field != "data" {
+		t.Errorf("Expected an *InvalidRequestError for data, got %v", err)
+	}
+
+	if requests != 0 {
+		t.Errorf("Expected no requests, got %d", requests)
+	}
+
+	// The middleware sees the rejected calls
+	if len(calls) != 2 || calls[0].Name != OperationSendMessage || calls[1].Name != OperationSendRaw {
+		t.Fatalf("Expected the middleware to see both calls, got %v", calls)
+	}
+	for i, call := range calls {
+		if !errors.As(errs[i], &invalid) || call.Attempts != 0 {
+			t.Errorf("Expected call %d to fail with an *InvalidRequestError and no attempts, got %v and %d attempts", i, errs[i], call.Attempts)
+		}
+	}
+
+	// A valid message is sent
+	_, err = client.SendMessage(&models.SendMessageRequest{From: "a@example.com", To: []string{"b@example.org"}, PlainBody: "Hi"})
+	if err != nil || requests != 1 {
+		t.Errorf("Expected the message to be sent, got %v and %d requests", err, requests)
+	}
+}
+
+func TestSendWithPlainTextFromHTML(t *testing.T) {
+	// Create a test server that records the plain bodies it receives
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.SendMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Expected a JSON body, got %v", err)
+		}
+		bodies = append(bodies, req.PlainBody)
+		_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":{"message_id":1,"token":"tok"}}`))
+	}))
+	defer server.Close()
+
+	// Record the requests the middleware sees once the call is done
+	var seen []string
+	record := func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			err := next(ctx, call)
+			if req, ok := call.Request.(*models.SendMessageRequest); ok && req != nil {
+				seen = append(seen, req.PlainBody)
+			}
+			return err
+		}
+	}
+	client, err := New("test-api-key", WithBaseURL(server.URL+"/api/v1"), WithPlainTextFromHTML(), WithValidation(), WithMiddleware(record))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A message with only an HTML body gets a plain one
+	req := &models.SendMessageRequest{From: "a@example.com", To: []string{"b@example.org"}, HTMLBody: `<p>Hi <a href="https://example.com">there</a></p>`}
+	if _, err := client.SendMessage(req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if req.PlainBody != "" {
+		t.Errorf("Expected the caller's request to be unchanged, got %q", req.PlainBody)
+	}
+
+	// A message with a plain body keeps it
+	req.PlainBody = "Hand written"
+	if _, err := client.SendMessage(req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := []string{"Hi there[1]\n\n[1] https://example.com", "Hand written"}
+	if len(bodies) != 2 || bodies[0] != want[0] || bodies[1] != want[1] {
+		t.Errorf("Expected the plain bodies %q, got %q", want, bodies)
+	}
+	if len(seen) != 2 || seen[0] != want[0] || seen[1] != want[1] {
+		t.Errorf("Expected the middleware to see the plain bodies %q, got %q", want, seen)
+	}
+
+	// A nil request is sent as is instead of panicking
+	if _, err := client.SendMessage(nil); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,303 @@
+// This file contains the operation middleware chain, which wraps every API
+// call made by the client, and the middleware that ships with the package.
+package postalclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Operation names passed to middleware in Call.Name.
+const (
+	// OperationSendMessage is the name of SendMessage calls.
+	OperationSendMessage = "send.message"
+
+	// OperationSendRaw is the name of SendRaw calls.
+	OperationSendRaw = "send.raw"
+
+	// OperationMessage is the name of GetMessage calls.
+	OperationMessage = "messages.message"
+
+	// OperationDeliveries is the name of GetMessageDeliveries calls.
+	OperationDeliveries = "messages.deliveries"
+)
+
+// Call describes a single API operation as it passes through the client's
+// middleware. Middleware may change the request fields before calling the
+// next handler, and read the result fields after it returns.
+type Call struct {
+	// Name is the logical name of the operation, e.g. "send.message".
+	// See the Operation constants.
+	Name string
+
+	// Method is the HTTP method of the request.
+	Method string
+
+	// Path is the API path of the request, e.g. "/send/message".
+	Path string
+
+	// Request is the request value, which is encoded as the JSON body:
+	// *models.SendMessageRequest, *models.SendRawRequest, *MessageRequest,
+	// or *DeliveriesRequest.
+	Request any
+
+	// Header holds extra headers sent with every attempt of this call.
+	// They take precedence over the client's Header but not over the
+	// headers the client sets itself. It is nil until a middleware sets it.
+	Header http.Header
+
+	// StatusCode is the HTTP status code of the last attempt, or 0 if no
+	// response was received.
+	StatusCode int
+
+	// Envelope is the raw API response of the last attempt, or nil if it
+	// failed.
+	Envelope *Response
+
+	// Attempts is the number of HTTP requests made for the call.
+	Attempts int
+
+	// RateLimitWait is how long the call waited for the client's
+	// RateLimiter.
+	RateLimitWait time.Duration
+
+	// Response is the decoded response once the call succeeds:
+	// *models.SendMessageResponse for sends, *models.Message for
+	// GetMessage, or []models.Delivery for GetMessageDeliveries.
+	// Middleware that replaces it must keep the same type.
+	Response any
+}
+
+// SetHeader sets a header sent with every attempt of the call.
+func (c *Call) SetHeader(name, value string) {
+	if c.Header == nil {
+		c.Header = make(http.Header)
+	}
+	c.Header.Set(name, value)
+}
+
+// Handler performs an API operation. The innermost handler makes the HTTP
+// requests and fills in the result fields of the Call.
+type Handler func(ctx context.Context, call *Call) error
+
+// Middleware wraps a Handler to run code around every API operation, such
+// as auditing, adding headers, or injecting faults. A middleware may
+// return an error without calling next to fail the operation.
+//
+// Example:
+//
+//	timing := func(next postalclient.Handler) postalclient.Handler {
+//	    return func(ctx context.Context, call *postalclient.Call) error {
+//	        start := time.Now()
+//	        err := next(ctx, call)
+//	        log.Printf("%s took %v (%d attempts): %v", call.Name, time.Since(start), call.Attempts, err)
+//	        return err
+//	    }
+//	}
+//	client, err := postalclient.New("your-api-key", postalclient.WithMiddleware(timing))
+type Middleware func(next Handler) Handler
+
+// invoke runs call through the client's middleware. The innermost handler
+// prepares the request, performs the HTTP requests, and decodes a successful
+// response with decode.
+func (c *Client) invoke(ctx context.Context, call *Call, decode func(*Response) (any, error)) error {
+	h := Handler(func(ctx context.Context, call *Call) error {
+		if err := c.prepareRequest(call); err != nil {
+			return err
+		}
+		resp, err := c.doCall(ctx, call)
+		if err != nil {
+			return err
+		}
+		v, err := decode(resp)
+		if err != nil {
+			return err
+		}
+		call.Response = v
+		return nil
+	})
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		h = c.Middleware[i](h)
+	}
+
+	start := time.Now()
+	err := h(ctx, call)
+	c.logCall(ctx, call, time.Since(start), err)
+	return err
+}
+
+// callAPI performs a POST operation through the client's middleware and
+// returns its response decoded as T. what describes the response in
+// DecodeErrors.
+func callAPI[T any](ctx context.Context, c *Client, name, path string, req any, what string) (T, error) {
+	var zero T
+	call := &Call{Name: name, Method: http.MethodPost, Path: path, Request: req}
+	err := c.invoke(ctx, call, func(resp *Response) (any, error) {
+		var v T
+		if err := json.Unmarshal(resp.Data, &v); err != nil {
+			return nil, &DecodeError{What: what, StatusCode: http.StatusOK, Body: resp.Data, Err: err}
+		}
+		return v, nil
+	})
+	if err != nil {
+		return zero, err
+	}
+
+	v, ok := call.Response.(T)
+	if !ok {
+		return zero, fmt.Errorf("middleware set a %T response for %s, want %T", call.Response, name, zero)
+	}
+	return v, nil
+}
+
+// HeaderMiddleware returns a middleware that sends the given headers with
+// every call, e.g. to tag requests for a proxy. Headers set by the
+// middleware override the client's Header.
+func HeaderMiddleware(header http.Header) Middleware {
+	header = header.Clone()
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			if call.Header == nil {
+				call.Header = make(http.Header, len(header))
+			}
+			for name, values := range header {
+				call.Header[name] = append([]string(nil), values...)
+			}
+			return next(ctx, call)
+		}
+	}
+}
+
+// AuditEntry records a completed API operation. It is passed to the
+// function given to AuditMiddleware.
+type AuditEntry struct {
+	// Name is the operation name, e.g. "send.message".
+	Name string
+
+	// Path is the API path of the request.
+	Path string
+
+	// Request is the request value. See Call.Request.
+	Request any
+
+	// Response is the decoded response, or nil if the operation failed.
+	// See Call.Response.
+	Response any
+
+	// Err is the error returned by the operation, or nil.
+	Err error
+
+	// StatusCode is the HTTP status code of the last attempt, or 0 if no
+	// response was received.
+	StatusCode int
+
+	// Attempts is the number of HTTP requests made.
+	Attempts int
+
+	// Start is when the operation started.
+	Start time.Time
+
+	// Duration is how long the operation took, including retries and
+	// rate limiting.
+	Duration time.Duration
+}
+
+// AuditMiddleware returns a middleware that calls record after every
+// operation, successful or not. record runs on the caller's goroutine, so
+// it should not block for long.
+//
+// Requests include message content and recipients. Take care where the
+// entries are stored.
+func AuditMiddleware(record func(ctx context.Context, entry AuditEntry)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			start := time.Now()
+			err := next(ctx, call)
+			entry := AuditEntry{
+				Name:       call.Name,
+				Path:       call.Path,
+				Request:    call.Request,
+				Err:        err,
+				StatusCode: call.StatusCode,
+				Attempts:   call.Attempts,
+				Start:      start,
+				Duration:   time.Since(start),
+			}
+			if err == nil {
+				entry.Response = call.Response
+			}
+			record(ctx, entry)
+			return err
+		}
+	}
+}
+
+// ErrChaos is the error returned by calls failed by ChaosMiddleware when
+// ChaosConfig.Err is nil.
+var ErrChaos = errors.New("postal: fault injected by chaos middleware")
+
+// ChaosConfig configures ChaosMiddleware.
+type ChaosConfig struct {
+	// FailureRate is the probability (between 0 and 1) that a call fails
+	// without reaching the server.
+	FailureRate float64
+
+	// Err is the error returned by failed calls. If nil, ErrChaos is used.
+	Err error
+
+	// Latency is added before every affected call, failed or not.
+	Latency time.Duration
+
+	// Operations limits the middleware to the named operations, e.g.
+	// OperationSendMessage. If empty, every operation is affected.
+	Operations []string
+
+	// Rand returns a number in [0, 1) used to decide whether a call fails.
+	// If nil, math/rand/v2 is used. Tests can set it to get repeatable
+	// failures.
+	Rand func() float64
+}
+
+// ChaosMiddleware returns a middleware that injects latency and failures
+// into calls, to test how an application copes with an unreliable Postal
+// server. Failed calls never reach the server.
+//
+// Example:
+//
+//	client, err := postalclient.New("your-api-key",
+//	    postalclient.WithMiddleware(postalclient.ChaosMiddleware(postalclient.ChaosConfig{
+//	        FailureRate: 0.1,
+//	        Latency:     200 * time.Millisecond,
+//	        Operations:  []string{postalclient.OperationSendMessage},
+//	    })),
+//	)
+func ChaosMiddleware(config ChaosConfig) Middleware {
+	random := config.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	failure := config.Err
+	if failure == nil {
+		failure = ErrChaos
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			if len(config.Operations) > 0 && !slices.Contains(config.Operations, call.Name) {
+				return next(ctx, call)
+			}
+			if err := sleepContext(ctx, config.Latency); err != nil {
+				return err
+			}
+			if config.FailureRate > 0 && random() < config.FailureRate {
+				return failure
+			}
+			return next(ctx, call)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package postalclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// newMiddlewareTestServer returns a test server that answers every request
+// with a successful send response and records the last request.
+func newMiddlewareTestServer(t *testing.T, last **http.Request) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*last = r
+		w.WriteHeader(http.StatusOK)
+		switch r.URL.Path {
+		case "/api/v1/messages/deliveries":
+			_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":[{"id":1,"status":"Sent"}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":{"message_id":7,"token":"abc","id":7}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMiddlewareSeesOperations(t *testing.T) {
+	var last *http.Request
+	server := newMiddlewareTestServer(t, &last)
+
+	var calls []*Call
+	record := func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			err := next(ctx, call)
+			calls = append(calls, call)
+			return err
+		}
+	}
+
+	client, err := New("test-api-key", WithBaseURL(server.URL+"/api/v1"), WithMiddleware(record))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	sendReq := &models.SendMessageRequest{From: "a@example.com", To: []string{"b@example.com"}}
+	if _, err := client.SendMessage(sendReq); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := client.SendRaw(&models.SendRawRequest{MailFrom: "a@example.com"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := client.GetMessage(7, WithExpansions(ExpansionStatus)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := client.GetMessageDeliveries(7); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	names := []string{OperationSendMessage, OperationSendRaw, OperationMessage, OperationDeliveries}
+	if len(calls) != len(names) {
+		t.Fatalf("Expected %d calls, got %d", len(names), len(calls))
+	}
+	for i, name := range names {
+		if calls[i].Name != name || calls[i].Method != http.MethodPost || calls[i].Attempts != 1 || calls[i].StatusCode != http.StatusOK {
+			t.Errorf("Expected call %d to be a successful %s, got %+v", i, name, calls[i])
+		}
+
+		if calls[i].Envelope == nil || calls[i].Envelope.Status != "success" {
+			t.Errorf("Expected call %d to have the response envelope, got %+v", i, calls[i].Envelope)
+		}
+	}
+
+	if calls[0].Request != sendReq {
+		t.Errorf("Expected the send request, got %#v", calls[0].Request)
+	}
+
+	if resp, ok := calls[0].Response.(*models.SendMessageResponse); !ok || resp.MessageID != 7 {
+		t.Errorf("Expected a decoded send response, got %#v", calls[0].Response)
+	}
+
+	if req, ok := calls[2].Request.(*MessageRequest); !ok || req.ID != 7 || len(req.Expansions) != 1 {
+		t.Errorf("Expected a message request, got %#v", calls[2].Request)
+	}
+
+	if deliveries, ok := calls[3].Response.([]models.Delivery); !ok || len(deliveries) != 1 {
+		t.Errorf("Expected decoded deliveries, got %#v", calls[3].Response)
+	}
+}
+
+func TestMiddlewareOrderAndShortCircuit(t *testing.T) {
+	var last *http.Request
+	server := newMiddlewareTestServer(t, &last)
+
+	var order []string
+	named := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, call *Call) error {
+				order = append(order, name)
+				return next(ctx, call)
+			}
+		}
+	}
+	errBlocked := errors.New("blocked")
+	block := func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			if call.Name == OperationSendRaw {
+				return errBlocked
+			}
+			return next(ctx, call)
+		}
+	}
+
+	client, err := New("test-api-key", WithBaseURL(server.URL+"/api/v1"), WithMiddleware(named("first"), named("second"), block))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := client.SendMessage(&models.SendMessageRequest{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := strings.Join(order, ","); got != "first,second" {
+		t.Errorf("Expected middleware to run in order, got %s", got)
+	}
+
+	last = nil
+	if _, err := client.SendRaw(&models.SendRawRequest{}); !errors.Is(err, errBlocked) {
+		t.Errorf("Expected the middleware's error, got %v", err)
+	}
+
+	if last != nil {
+		t.Error("Expected a blocked call not to reach the server")
+	}
+}
+
+func TestMiddlewareCanChangeRequestAndResponse(t *testing.T) {
+	var last *http.Request
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":{"message_id":7,"token":"abc"}}`))
+	}))
+	defer server.Close()
+
+	tagger := func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			if req, ok := call.Request.(*models.SendMessageRequest); ok {
+				tagged := *req
+				tagged.Tag = "tagged"
+				call.Request = &tagged
+			}
+			call.SetHeader("X-Trace", "123")
+			if err := next(ctx, call); err != nil {
+				return err
+			}
+			call.Response.(*models.SendMessageResponse).Token = "rewritten"
+			return nil
+		}
+	}
+
+	client, err := New("test-api-key", WithBaseURL(server.URL+"/api/v1"), WithMiddleware(tagger))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	resp, err := client.SendMessage(&models.SendMessageRequest{From: "a@example.com"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if body["tag"] != "tagged" {
+		t.Errorf("Expected the changed request to be sent, got %v", body)
+	}
+
+	if got := last.Header.Get("X-Trace"); got != "123" {
+		t.Errorf("Expected X-Trace to be 123, got %s", got)
+	}
+
+	if resp.Token != "rewritten" {
+		t.Errorf("Expected the changed response, got %s", resp.Token)
+	}
+
+	// Replacing the response with the wrong type is an error
+	client.Middleware = []Middleware{func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			err := next(ctx, call)
+			call.Response = "nope"
+			return err
+		}
+	}}
+	if _, err := client.SendMessage(&models.SendMessageRequest{}); err == nil || !strings.Contains(err.Error(), "middleware set a string response") {
+		t.Errorf("Expected a response type error, got %v", err)
+	}
+}
+
+func TestHeaderMiddleware(t *testing.T) {
+	var last *http.Request
+	server := newMiddlewareTestServer(t, &last)
+
+	header := http.Header{}
+	header.Set("X-Tenant", "acme")
+	header.Set("X-Server-API-Key", "overridden")
+	client, err := New("test-api-key",
+		WithBaseURL(server.URL+"/api/v1"),
+		WithHeader("X-Tenant", "default"),
+		WithMiddleware(HeaderMiddleware(header)),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Changing the header afterwards has no effect
+	header.Set("X-Tenant", "changed")
+
+	if _, err := client.SendMessage(&models.SendMessageRequest{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := last.Header.Values("X-Tenant"); len(got) != 1 || got[0] != "acme" {
+		t.Errorf("Expected X-Tenant to be acme, got %v", got)
+	}
+
+	if got := last.Header.Get("X-Server-API-Key"); got != "test-api-key" {
+		t.Errorf("Expected the API key not to be overridden, got %s", got)
+	}
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	// Create a test server that fails once before succeeding
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"error","time":0.1,"flags":{},"data":{"code":"MessageNotFound","message":"No message found"}}`))
+	}))
+	defer server.Close()
+
+	var entries []AuditEntry
+	client, err := New("test-api-key",
+		WithBaseURL(server.URL+"/api/v1"),
+		WithRetry(fastRetryPolicy(2)),
+		WithMiddleware(AuditMiddleware(func(ctx context.Context, entry AuditEntry) {
+			entries = append(entries, entry)
+		})),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := client.GetMessageDeliveries(3); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("Expected ErrMessageNotFound, got %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Name != OperationDeliveries || entry.Path != "/messages/deliveries" || entry.Attempts != 2 || entry.StatusCode != http.StatusOK {
+		t.Errorf("Expected an entry for 2 attempts at deliveries, got %+v", entry)
+	}
+
+	if !errors.Is(entry.Err, ErrMessageNotFound) || entry.Response != nil {
+		t.Errorf("Expected the error and no response, got %v and %v", entry.Err, entry.Response)
+	}
+
+	if req, ok := entry.Request.(*DeliveriesRequest); !ok || req.ID != 3 {
+		t.Errorf("Expected the deliveries request, got %#v", entry.Request)
+	}
+
+	if entry.Start.IsZero() || entry.Duration <= 0 {
+		t.Errorf("Expected the timing to be recorded, got %v and %v", entry.Start, entry.Duration)
+	}
+}
+
+func TestChaosMiddleware(t *testing.T) {
+	var last *http.Request
+	server := newMiddlewareTestServer(t, &last)
+
+	rolls := []float64{0.05, 0.5}
+	client, err := New("test-api-key",
+		WithBaseURL(server.URL+"/api/v1"),
+		WithMiddleware(ChaosMiddleware(ChaosConfig{
+			FailureRate: 0.1,
+			Operations:  []string{OperationSendMessage},
+			Rand: func() float64 {
+				roll := rolls[0]
+				rolls = rolls[1:]
+				return roll
+			},
+		})),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The first roll fails the call before it reaches the server
+	if _, err := client.SendMessage(&models.SendMessageRequest{}); !errors.Is(err, ErrChaos) {
+		t.Errorf("Expected ErrChaos, got %v", err)
+	}
+
+	if last != nil {
+		t.Error("Expected the failed call not to reach the server")
+	}
+
+	// The second roll lets it through
+	if _, err := client.SendMessage(&models.SendMessageRequest{}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	// Other operations are not affected and don't roll
+	if _, err := client.GetMessage(7); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if len(rolls) != 0 {
+		t.Errorf("Expected 2 rolls, got %d left over", len(rolls))
+	}
+}
+
+func TestChaosMiddlewareLatency(t *testing.T) {
+	var last *http.Request
+	server := newMiddlewareTestServer(t, &last)
+
+	custom := errors.New("custom failure")
+	client, err := New("test-api-key",
+		WithBaseURL(server.URL+"/api/v1"),
+		WithMiddleware(ChaosMiddleware(ChaosConfig{FailureRate: 1, Err: custom, Latency: 20 * time.Millisecond})),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	start := time.Now()
+	if _, err := client.GetMessage(7); !errors.Is(err, custom) {
+		t.Errorf("Expected the custom error, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected at least 20ms of latency, got %v", elapsed)
+	}
+
+	// The latency respects cancellation
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := client.GetMessageContext(ctx, 7); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
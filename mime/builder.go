@@ -0,0 +1,534 @@
+// Package mime builds RFC 5322 email messages for Postal's /send/raw
+// endpoint.
+//
+// A Builder assembles the headers and body of a message and produces a
+// models.SendRawRequest whose MailFrom and RcptTo are taken from the
+// message's addresses. It takes care of the parts of the format that are
+// easy to get wrong by hand: multipart/alternative text and HTML bodies,
+// attachments, inline images referenced by Content-ID, RFC 2047 encoding of
+// non-ASCII headers and display names, Message-ID and Date generation, and
+// folding of long header lines.
+//
+// Example:
+//
+//	req, err := mime.NewBuilder().
+//	    From("Example Shop <shop@example.com>").
+//	    To("Zoë Doe <zoe@example.org>").
+//	    BCC("archive@example.com").
+//	    Subject("Your order has shipped").
+//	    Text("Your order is on its way.").
+//	    HTML(`<p>Your order is on its way.</p><img src="cid:logo">`).
+//	    Inline("logo", "logo.png", "image/png", logo).
+//	    Attach("invoice.pdf", "application/pdf", invoice).
+//	    Request()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	resp, err := client.SendRaw(req)
+package mime
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	stdmime "mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// Errors returned by Build and Request.
+var (
+	// ErrNoFrom is returned when a message has no From address.
+	ErrNoFrom = errors.New("mime: From address is required")
+
+	// ErrNoRecipients is returned when a message has no To, CC, or BCC
+	// addresses.
+	ErrNoRecipients = errors.New("mime: at least one recipient is required")
+)
+
+// managedHeaders are the headers the builder writes itself. They can't be
+// set with Builder.Header.
+var managedHeaders = map[string]bool{
+	"Bcc":                       true,
+	"Cc":                        true,
+	"Content-Disposition":       true,
+	"Content-Id":                true,
+	"Content-Transfer-Encoding": true,
+	"Content-Type":              true,
+	"Date":                      true,
+	"From":                      true,
+	"Message-Id":                true,
+	"Mime-Version":              true,
+	"Reply-To":                  true,
+	"Sender":                    true,
+	"Subject":                   true,
+	"To":                        true,
+}
+
+// file is an attachment or inline image.
+type file struct {
+	name        string
+	contentType string
+	contentID   string
+	data        []byte
+}
+
+// Builder assembles an email message. Its methods return the builder so
+// calls can be chained. Invalid input, such as an unparseable address, is
+// reported by Build or Request rather than by the method that received it.
+//
+// A Builder is not safe for concurrent use.
+type Builder struct {
+	from      *mail.Address
+	sender    *mail.Address
+	replyTo   []*mail.Address
+	to        []*mail.Address
+	cc        []*mail.Address
+	bcc       []*mail.Address
+	mailFrom  string
+	subject   string
+	text      string
+	html      string
+	files     []file
+	inline    []file
+	headers   []header
+	messageID string
+	date      time.Time
+	bounce    bool
+	err       error
+}
+
+// header is a custom header added with Builder.Header.
+type header struct {
+	name  string
+	value string
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// From sets the From address. Addresses may include a display name, e.g.
+// "Jane Doe <jane@example.com>".
+func (b *Builder) From(address string) *Builder {
+	if addrs := b.parse("From", address); len(addrs) > 0 {
+		b.from = addrs[0]
+	}
+	return b
+}
+
+// Sender sets the Sender header, for messages sent on behalf of the From
+// address.
+func (b *Builder) Sender(address string) *Builder {
+	if addrs := b.parse("Sender", address); len(addrs) > 0 {
+		b.sender = addrs[0]
+	}
+	return b
+}
+
+// ReplyTo adds addresses to the Reply-To header.
+func (b *Builder) ReplyTo(addresses ...string) *Builder {
+	b.replyTo = append(b.replyTo, b.parse("Reply-To", addresses...)...)
+	return b
+}
+
+// To adds addresses to the To header.
+func (b *Builder) To(addresses ...string) *Builder {
+	b.to = append(b.to, b.parse("To", addresses...)...)
+	return b
+}
+
+// CC adds addresses to the Cc header.
+func (b *Builder) CC(addresses ...string) *Builder {
+	b.cc = append(b.cc, b.parse("CC", addresses...)...)
+	return b
+}
+
+// BCC adds blind carbon copy recipients. They receive the message but
+// don't appear in its headers.
+func (b *Builder) BCC(addresses ...string) *Builder {
+	b.bcc = append(b.bcc, b.parse("BCC", addresses...)...)
+	return b
+}
+
+// MailFrom sets the envelope sender used for the request's MailFrom, for
+// example a bounce address. By default the From address is used.
+func (b *Builder) MailFrom(address string) *Builder {
+	if addrs := b.parse("MailFrom", address); len(addrs) > 0 {
+		b.mailFrom = addrs[0].Address
+	}
+	return b
+}
+
+// Subject sets the subject. Non-ASCII text is encoded as required.
+func (b *Builder) Subject(subject string) *Builder {
+	b.subject = subject
+	return b
+}
+
+// Text sets the plain text body.
+func (b *Builder) Text(body string) *Builder {
+	b.text = body
+	return b
+}
+
+// HTML sets the HTML body. Inline images added with Inline can be
+// referenced from it as "cid:<contentID>".
+func (b *Builder) HTML(body string) *Builder {
+	b.html = body
+	return b
+}
+
+// Attach adds an attachment. If contentType is empty, it is guessed from
+// the file name's extension.
+func (b *Builder) Attach(name, contentType string, data []byte) *Builder {
+	b.files = append(b.files, file{name: name, contentType: contentType, data: data})
+	return b
+}
+
+// Inline adds an image, or other file, that the HTML body references as
+// "cid:<contentID>". If contentType is empty, it is guessed from the file
+// name's extension.
+func (b *Builder) Inline(contentID, name, contentType string, data []byte) *Builder {
+	contentID = strings.Trim(contentID, "<>")
+	if contentID == "" {
+		b.fail(fmt.Errorf("mime: inline file %q has no content ID", name))
+		return b
+	}
+	b.inline = append(b.inline, file{name: name, contentType: contentType, contentID: contentID, data: data})
+	return b
+}
+
+// Header adds a custom header, such as "X-Campaign" or "List-Unsubscribe".
+// Headers the builder manages itself, such as From, Subject, or
+// Content-Type, must be set with their own methods.
+func (b *Builder) Header(name, value string) *Builder {
+	name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+	switch {
+	case name == "" || strings.ContainsAny(name, ": \t\r\n"):
+		b.fail(fmt.Errorf("mime: invalid header name %q", name))
+	case managedHeaders[name]:
+		b.fail(fmt.Errorf("mime: header %s is set by the builder", name))
+	case strings.ContainsAny(value, "\r\n"):
+		b.fail(fmt.Errorf("mime: header %s contains a line break", name))
+	default:
+		b.headers = append(b.headers, header{name: name, value: value})
+	}
+	return b
+}
+
+// MessageID sets the Message-ID, with or without angle brackets. By
+// default a random ID at the From address's domain is generated.
+func (b *Builder) MessageID(id string) *Builder {
+	b.messageID = strings.Trim(id, "<> ")
+	return b
+}
+
+// Date sets the Date header. By default the time of the Build call is used.
+func (b *Builder) Date(t time.Time) *Builder {
+	b.date = t
+	return b
+}
+
+// Bounce marks the request as a bounce message.
+func (b *Builder) Bounce(bounce bool) *Builder {
+	b.bounce = bounce
+	return b
+}
+
+// Request builds the message and returns a request for SendRaw. MailFrom
+// is the MailFrom address, or the From address if it isn't set, and RcptTo
+// holds every To, CC, and BCC address, without duplicates.
+func (b *Builder) Request() (*models.SendRawRequest, error) {
+	data, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	mailFrom := b.mailFrom
+	if mailFrom == "" {
+		mailFrom = b.from.Address
+	}
+
+	var rcptTo []string
+	seen := make(map[string]bool)
+	for _, list := range [][]*mail.Address{b.to, b.cc, b.bcc} {
+		for _, addr := range list {
+			key := strings.ToLower(addr.Address)
+			if !seen[key] {
+				seen[key] = true
+				rcptTo = append(rcptTo, addr.Address)
+			}
+		}
+	}
+
+	return &models.SendRawRequest{
+		MailFrom: mailFrom,
+		RcptTo:   rcptTo,
+		Data:     base64.StdEncoding.EncodeToString(data),
+		Bounce:   b.bounce,
+	}, nil
+}
+
+// Build returns the message in RFC 5322 format, with CRLF line endings.
+func (b *Builder) Build() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.from == nil {
+		return nil, ErrNoFrom
+	}
+	if len(b.to) == 0 && len(b.cc) == 0 && len(b.bcc) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	body, err := b.body()
+	if err != nil {
+		return nil, err
+	}
+
+	date := b.date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	messageID := b.messageID
+	if messageID == "" {
+		messageID, err = newMessageID(b.from.Address)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&buf, "From", formatAddresses([]*mail.Address{b.from}))
+	if b.sender != nil {
+		writeHeader(&buf, "Sender", formatAddresses([]*mail.Address{b.sender}))
+	}
+	if len(b.replyTo) > 0 {
+		writeHeader(&buf, "Reply-To", formatAddresses(b.replyTo))
+	}
+	if len(b.to) > 0 {
+		writeHeader(&buf, "To", formatAddresses(b.to))
+	}
+	if len(b.cc) > 0 {
+		writeHeader(&buf, "Cc", formatAddresses(b.cc))
+	}
+	writeHeader(&buf, "Subject", encodeWord(b.subject))
+	writeHeader(&buf, "Message-ID", "<"+messageID+">")
+	for _, h := range b.headers {
+		writeHeader(&buf, h.name, encodeWord(h.value))
+	}
+	writeHeader(&buf, "MIME-Version", "1.0")
+	writePartHeader(&buf, body.header)
+	buf.WriteString("\r\n")
+	buf.Write(body.body)
+	return buf.Bytes(), nil
+}
+
+// part is a MIME entity: its headers and encoded body.
+type part struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// body returns the message's top-level MIME entity. Attachments wrap the
+// content in multipart/mixed, inline files wrap the HTML body in
+// multipart/related, and a text and HTML body together are combined in
+// multipart/alternative.
+func (b *Builder) body() (*part, error) {
+	var content *part
+	var err error
+
+	var html *part
+	if b.html != "" {
+		html = textPart("text/html", b.html)
+		if len(b.inline) > 0 {
+			related := []*part{html}
+			for _, f := range b.inline {
+				related = append(related, filePart(f, "inline"))
+			}
+			if html, err = multipartPart("related", related); err != nil {
+				return nil, err
+			}
+		}
+	} else if len(b.inline) > 0 {
+		return nil, errors.New("mime: inline files require an HTML body")
+	}
+
+	switch {
+	case b.text != "" && html != nil:
+		content, err = multipartPart("alternative", []*part{textPart("text/plain", b.text), html})
+		if err != nil {
+			return nil, err
+		}
+	case html != nil:
+		content = html
+	case b.text != "" || len(b.files) == 0:
+		content = textPart("text/plain", b.text)
+	}
+
+	if len(b.files) == 0 {
+		return content, nil
+	}
+
+	var mixed []*part
+	if content != nil {
+		mixed = append(mixed, content)
+	}
+	for _, f := range b.files {
+		mixed = append(mixed, filePart(f, "attachment"))
+	}
+	return multipartPart("mixed", mixed)
+}
+
+// textPart returns a quoted-printable UTF-8 text part.
+func textPart(contentType, text string) *part {
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	_, _ = w.Write([]byte(text))
+	_ = w.Close()
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", stdmime.FormatMediaType(contentType, map[string]string{"charset": "utf-8"}))
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	return &part{header: h, body: buf.Bytes()}
+}
+
+// filePart returns a base64-encoded part for an attachment or inline file.
+func filePart(f file, disposition string) *part {
+	contentType := f.contentType
+	if contentType == "" {
+		contentType = stdmime.TypeByExtension(filepath.Ext(f.name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := make(textproto.MIMEHeader)
+	params := map[string]string{}
+	if f.name != "" {
+		params["name"] = f.name
+	}
+	if mediaType, existing, err := stdmime.ParseMediaType(contentType); err == nil {
+		for k, v := range existing {
+			params[k] = v
+		}
+		contentType = mediaType
+	}
+	h.Set("Content-Type", stdmime.FormatMediaType(contentType, params))
+	h.Set("Content-Transfer-Encoding", "base64")
+	if f.name != "" {
+		h.Set("Content-Disposition", stdmime.FormatMediaType(disposition, map[string]string{"filename": f.name}))
+	} else {
+		h.Set("Content-Disposition", disposition)
+	}
+	if f.contentID != "" {
+		h.Set("Content-ID", "<"+f.contentID+">")
+	}
+
+	return &part{header: h, body: encodeBase64Lines(f.data)}
+}
+
+// multipartPart combines parts into a multipart entity with the given
+// subtype.
+func multipartPart(subtype string, parts []*part) (*part, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		pw, err := w.CreatePart(p.header)
+		if err != nil {
+			return nil, fmt.Errorf("mime: error writing %s part: %w", subtype, err)
+		}
+		if _, err := pw.Write(p.body); err != nil {
+			return nil, fmt.Errorf("mime: error writing %s part: %w", subtype, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("mime: error writing %s part: %w", subtype, err)
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", stdmime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": w.Boundary()}))
+	return &part{header: h, body: buf.Bytes()}, nil
+}
+
+// encodeBase64Lines base64-encodes data in lines of 76 characters.
+func encodeBase64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// writePartHeader writes the headers of the top-level entity in a stable
+// order.
+func writePartHeader(buf *bytes.Buffer, h textproto.MIMEHeader) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := k
+		if k == "Content-Id" {
+			name = "Content-ID"
+		}
+		for _, v := range h[k] {
+			writeHeader(buf, name, v)
+		}
+	}
+}
+
+// newMessageID returns a random Message-ID, without angle brackets, at the
+// domain of address.
+func newMessageID(address string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("mime: error generating Message-ID: %w", err)
+	}
+	domain := "localhost"
+	if at := strings.LastIndex(address, "@"); at >= 0 && at < len(address)-1 {
+		domain = address[at+1:]
+	}
+	return hex.EncodeToString(b) + "@" + domain, nil
+}
+
+// parse parses addresses for the named field, recording the first error.
+func (b *Builder) parse(field string, addresses ...string) []*mail.Address {
+	var parsed []*mail.Address
+	for _, address := range addresses {
+		addr, err := mail.ParseAddress(address)
+		if err != nil {
+			b.fail(fmt.Errorf("mime: invalid %s address %q: %w", field, address, err))
+			continue
+		}
+		parsed = append(parsed, addr)
+	}
+	return parsed
+}
+
+// fail records err if no earlier error has been recorded.
+func (b *Builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
@@ -0,0 +1,369 @@
+package mime
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	stdmime "mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseMessage parses a built message, failing the test on error.
+func parseMessage(t *testing.T, data []byte) *mail.Message {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a parseable message, got %v\n%s", err, data)
+	}
+	return msg
+}
+
+// readParts returns the parts of a multipart body with the given content
+// type, failing the test if it isn't multipart.
+func readParts(t *testing.T, contentType string, body io.Reader) []*multipart.Part {
+	t.Helper()
+	mediaType, params, err := stdmime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		t.Fatalf("Expected a multipart content type, got %q (%v)", contentType, err)
+	}
+
+	var parts []*multipart.Part
+	r := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("Expected no error reading parts, got %v", err)
+		}
+
+		// Buffer the part so the reader can move on to the next one
+		data, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatalf("Expected no error reading part, got %v", err)
+		}
+		p.Header.Set("X-Test-Body", base64.StdEncoding.EncodeToString(data))
+		parts = append(parts, p)
+	}
+}
+
+// partBody returns the decoded body of a part returned by readParts.
+func partBody(p *multipart.Part) string {
+	data, _ := base64.StdEncoding.DecodeString(p.Header.Get("X-Test-Body"))
+	return string(data)
+}
+
+func TestBuildPlainText(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	data, err := NewBuilder().
+		From("Jane Doe <jane@example.com>").
+		To("john@example.org").
+		Subject("Hello").
+		Text("Hi John,\nSee you soon.").
+		Date(date).
+		MessageID("<abc123@example.com>").
+		Build()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !bytes.Contains(data, []byte("\r\n\r\n")) || bytes.Contains(bytes.ReplaceAll(data, []byte("\r\n"), nil), []byte("\n")) {
+		t.Errorf("Expected CRLF line endings, got %q", data)
+	}
+
+	msg := parseMessage(t, data)
+
+	if got := msg.Header.Get("From"); got != `"Jane Doe" <jane@example.com>` {
+		t.Errorf("Expected From to be \"Jane Doe\" <jane@example.com>, got %s", got)
+	}
+
+	if got := msg.Header.Get("To"); got != "john@example.org" {
+		t.Errorf("Expected To to be john@example.org, got %s", got)
+	}
+
+	if got := msg.Header.Get("Message-ID"); got != "<abc123@example.com>" {
+		t.Errorf("Expected Message-ID to be <abc123@example.com>, got %s", got)
+	}
+
+	if got, err := msg.Header.Date(); err != nil || !got.Equal(date) {
+		t.Errorf("Expected Date to be %v, got %v (%v)", date, got, err)
+	}
+
+	if got := msg.Header.Get("MIME-Version"); got != "1.0" {
+		t.Errorf("Expected MIME-Version to be 1.0, got %s", got)
+	}
+
+	if got := msg.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Expected Content-Type to be text/plain; charset=utf-8, got %s", got)
+	}
+
+	body, _ := io.ReadAll(msg.Body)
+	if !strings.Contains(string(body), "Hi John,\r\nSee you soon.") {
+		t.Errorf("Expected body to contain the text, got %q", body)
+	}
+}
+
+func TestBuildAlternativeWithAttachmentsAndInline(t *testing.T) {
+	logo := []byte("\x89PNG fake image data")
+	invoice := bytes.Repeat([]byte("%PDF-1.4 "), 50)
+
+	data, err := NewBuilder().
+		From("shop@example.com").
+		To("customer@example.org").
+		Subject("Your order").
+		Text("Your order has shipped.").
+		HTML(`<p>Your order has shipped.</p><img src="cid:logo">`).
+		Inline("logo", "logo.png", "", logo).
+		Attach("invoice.pdf", "application/pdf", invoice).
+		Build()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	msg := parseMessage(t, data)
+
+	// multipart/mixed holds the content and the attachment
+	mixed := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	if len(mixed) != 2 {
+		t.Fatalf("Expected 2 mixed parts, got %d", len(mixed))
+	}
+
+	attachment := mixed[1]
+	if got := attachment.FileName(); got != "invoice.pdf" {
+		t.Errorf("Expected attachment file name to be invoice.pdf, got %s", got)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(partBody(attachment), "\r\n", ""))
+	if err != nil || !bytes.Equal(decoded, invoice) {
+		t.Errorf("Expected attachment data to round-trip, got error %v", err)
+	}
+
+	for _, line := range strings.Split(partBody(attachment), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("Expected base64 lines of at most 76 characters, got %d", len(line))
+		}
+	}
+
+	// multipart/alternative holds the text and the related HTML
+	alternative := readParts(t, mixed[0].Header.Get("Content-Type"), strings.NewReader(partBody(mixed[0])))
+	if len(alternative) != 2 {
+		t.Fatalf("Expected 2 alternative parts, got %d", len(alternative))
+	}
+
+	if !strings.HasPrefix(alternative[0].Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("Expected the first alternative to be text/plain, got %s", alternative[0].Header.Get("Content-Type"))
+	}
+
+	// multipart/related holds the HTML and the inline image
+	related := readParts(t, alternative[1].Header.Get("Content-Type"), strings.NewReader(partBody(alternative[1])))
+	if len(related) != 2 {
+		t.Fatalf("Expected 2 related parts, got %d", len(related))
+	}
+
+	if !strings.HasPrefix(related[0].Header.Get("Content-Type"), "text/html") {
+		t.Errorf("Expected the first related part to be text/html, got %s", related[0].Header.Get("Content-Type"))
+	}
+
+	image := related[1]
+	if got := image.Header.Get("Content-ID"); got != "<logo>" {
+		t.Errorf("Expected Content-ID to be <logo>, got %s", got)
+	}
+
+	if got := image.Header.Get("Content-Type"); !strings.HasPrefix(got, "image/png") {
+		t.Errorf("Expected the content type to be guessed as image/png, got %s", got)
+	}
+
+	if got := image.Header.Get("Content-Disposition"); !strings.HasPrefix(got, "inline") {
+		t.Errorf("Expected an inline disposition, got %s", got)
+	}
+}
+
+func TestBuildEncodesHeaders(t *testing.T) {
+	subject := "Réservation confirmée pour votre séjour à l'hôtel — merci beaucoup et à bientôt"
+	data, err := NewBuilder().
+		From("Zoë Müller <zoe@example.com>").
+		To("Björn <bjorn@example.org>").
+		Subject(subject).
+		Header("X-Campaign", "été").
+		Text("Bonjour").
+		Build()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Header lines stay ASCII and within the recommended length
+	head := string(data[:bytes.Index(data, []byte("\r\n\r\n"))])
+	for _, line := range strings.Split(head, "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("Expected header lines of at most %d characters, got %q", maxLineLength, line)
+		}
+		for _, r := range line {
+			if r > 127 {
+				t.Errorf("Expected an ASCII header line, got %q", line)
+				break
+			}
+		}
+	}
+
+	msg := parseMessage(t, data)
+	dec := new(stdmime.WordDecoder)
+
+	got, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || got != subject {
+		t.Errorf("Expected subject to decode to %q, got %q (%v)", subject, got, err)
+	}
+
+	from, err := msg.Header.AddressList("From")
+	if err != nil || len(from) != 1 || from[0].Name != "Zoë Müller" {
+		t.Errorf("Expected the From display name to decode to Zoë Müller, got %v (%v)", from, err)
+	}
+
+	if got, _ := dec.DecodeHeader(msg.Header.Get("X-Campaign")); got != "été" {
+		t.Errorf("Expected X-Campaign to decode to été, got %q", got)
+	}
+}
+
+func TestRequest(t *testing.T) {
+	req, err := NewBuilder().
+		From("Sender <sender@example.com>").
+		To("a@example.org", "B <b@example.org>").
+		CC("c@example.org").
+		BCC("hidden@example.org", "A@example.org").
+		Subject("Hi").
+		Text("Hi").
+		Bounce(true).
+		Request()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if req.MailFrom != "sender@example.com" {
+		t.Errorf("Expected MailFrom to be sender@example.com, got %s", req.MailFrom)
+	}
+
+	want := []string{"a@example.org", "b@example.org", "c@example.org", "hidden@example.org"}
+	if strings.Join(req.RcptTo, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected RcptTo to be %v, got %v", want, req.RcptTo)
+	}
+
+	if !req.Bounce {
+		t.Error("Expected Bounce to be true")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(req.Data)
+	if err != nil {
+		t.Fatalf("Expected base64 data, got %v", err)
+	}
+
+	msg := parseMessage(t, data)
+	if msg.Header.Get("Bcc") != "" || bytes.Contains(data, []byte("hidden@example.org")) {
+		t.Error("Expected BCC recipients to be left out of the message")
+	}
+
+	if id := msg.Header.Get("Message-ID"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Expected a generated Message-ID at example.com, got %s", id)
+	}
+
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("Expected a generated Date, got %v", err)
+	}
+
+	// MailFrom overrides the envelope sender
+	req, err = NewBuilder().
+		From("sender@example.com").
+		MailFrom("bounces@example.com").
+		To("a@example.org").
+		Text("Hi").
+		Request()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if req.MailFrom != "bounces@example.com" {
+		t.Errorf("Expected MailFrom to be bounces@example.com, got %s", req.MailFrom)
+	}
+}
+
+func TestAttachmentsOnly(t *testing.T) {
+	data, err := NewBuilder().
+		From("sender@example.com").
+		To("a@example.org").
+		Attach("report.csv", "", []byte("a,b\n1,2\n")).
+		Build()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	msg := parseMessage(t, data)
+	parts := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	if len(parts) != 1 {
+		t.Fatalf("Expected 1 part, got %d", len(parts))
+	}
+
+	if got := parts[0].Header.Get("Content-Type"); !strings.HasPrefix(got, "text/csv") {
+		t.Errorf("Expected the content type to be guessed as text/csv, got %s", got)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    error
+		message string
+	}{
+		{
+			name:    "missing from",
+			builder: NewBuilder().To("a@example.org").Text("Hi"),
+			want:    ErrNoFrom,
+		},
+		{
+			name:    "missing recipients",
+			builder: NewBuilder().From("sender@example.com").Text("Hi"),
+			want:    ErrNoRecipients,
+		},
+		{
+			name:    "invalid address",
+			builder: NewBuilder().From("sender@example.com").To("not an address").Text("Hi"),
+			message: "invalid To address",
+		},
+		{
+			name:    "managed header",
+			builder: NewBuilder().From("sender@example.com").To("a@example.org").Header("subject", "Hi"),
+			message: "header Subject is set by the builder",
+		},
+		{
+			name:    "header injection",
+			builder: NewBuilder().From("sender@example.com").To("a@example.org").Header("X-Test", "a\r\nBcc: evil@example.com"),
+			message: "contains a line break",
+		},
+		{
+			name:    "inline without HTML",
+			builder: NewBuilder().From("sender@example.com").To("a@example.org").Text("Hi").Inline("logo", "logo.png", "", []byte("x")),
+			message: "inline files require an HTML body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Request()
+			if err == nil {
+				t.Fatal("Expected an error, got nil")
+			}
+
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+
+			if tt.message != "" && !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error to contain %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
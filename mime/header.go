@@ -0,0 +1,66 @@
+// This file contains helpers for encoding and folding header fields.
+package mime
+
+import (
+	"bytes"
+	stdmime "mime"
+	"net/mail"
+	"strings"
+)
+
+// maxLineLength is the line length RFC 5322 recommends header lines stay
+// within. Lines are folded at spaces to meet it where possible.
+const maxLineLength = 78
+
+// writeHeader writes a folded header field to buf.
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(foldHeader(name + ": " + value))
+	buf.WriteString("\r\n")
+}
+
+// foldHeader folds a header line by replacing spaces with CRLF followed by
+// a space, so unfolding restores the original line. Words longer than a
+// line are left intact.
+func foldHeader(line string) string {
+	if len(line) <= maxLineLength {
+		return line
+	}
+
+	var b strings.Builder
+	lineLen := 0
+	for i, word := range strings.Split(line, " ") {
+		if i > 0 {
+			if lineLen+1+len(word) > maxLineLength {
+				b.WriteString("\r\n ")
+				lineLen = 1
+			} else {
+				b.WriteByte(' ')
+				lineLen++
+			}
+		}
+		b.WriteString(word)
+		lineLen += len(word)
+	}
+	return b.String()
+}
+
+// encodeWord encodes text as RFC 2047 encoded words if it contains
+// characters that can't appear in a header as is. ASCII text is returned
+// unchanged.
+func encodeWord(text string) string {
+	return stdmime.QEncoding.Encode("utf-8", text)
+}
+
+// formatAddresses formats an address list for a header. Display names are
+// quoted or encoded as needed, and addresses without one are written bare.
+func formatAddresses(addresses []*mail.Address) string {
+	formatted := make([]string, len(addresses))
+	for i, addr := range addresses {
+		if addr.Name == "" {
+			formatted[i] = addr.Address
+		} else {
+			formatted[i] = addr.String()
+		}
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,53 @@
+package mime
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestFoldHeader(t *testing.T) {
+	// Short lines are left alone
+	if got := foldHeader("Subject: Hello"); got != "Subject: Hello" {
+		t.Errorf("Expected short line to be unchanged, got %q", got)
+	}
+
+	line := "Subject: " + strings.Repeat("word ", 40) + "end"
+	folded := foldHeader(line)
+
+	for _, l := range strings.Split(folded, "\r\n") {
+		if len(l) > maxLineLength {
+			t.Errorf("Expected lines of at most %d characters, got %d", maxLineLength, len(l))
+		}
+	}
+
+	if unfolded := strings.ReplaceAll(folded, "\r\n", ""); unfolded != line {
+		t.Errorf("Expected unfolding to restore the line, got %q", unfolded)
+	}
+
+	// A word longer than a line is kept intact
+	long := strings.Repeat("a", 100)
+	if got := foldHeader("X-Long: " + long); got != "X-Long:\r\n "+long {
+		t.Errorf("Expected a long word not to be split, got %q", got)
+	}
+}
+
+func TestEncodeWord(t *testing.T) {
+	if got := encodeWord("Hello"); got != "Hello" {
+		t.Errorf("Expected ASCII to be unchanged, got %s", got)
+	}
+
+	if got := encodeWord("Grüße"); got != "=?utf-8?q?Gr=C3=BC=C3=9Fe?=" {
+		t.Errorf("Expected an encoded word, got %s", got)
+	}
+}
+
+func TestFormatAddresses(t *testing.T) {
+	got := formatAddresses([]*mail.Address{
+		{Address: "a@example.org"},
+		{Name: "Jane Doe", Address: "jane@example.org"},
+	})
+	if got != `a@example.org, "Jane Doe" <jane@example.org>` {
+		t.Errorf("Expected formatted address list, got %s", got)
+	}
+}
@@ -6,13 +6,21 @@
 package models
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"net/textproto"
+	"strconv"
 	"time"
 )
 
 // Message represents a message in the Postal API.
 // It contains all the details about an email message, including its
 // content, headers, and metadata.
+//
+// Apart from ID and Token, fields are only populated when the matching
+// expansion is requested, for example with postalclient.WithExpansions.
 type Message struct {
 	// ID is the unique identifier for the message.
 	ID int `json:"id"`
@@ -30,7 +38,7 @@ type Message struct {
 
 	// Inspection contains information about spam checks and other inspections.
 	// This is only included when the 'inspection' expansion is requested.
-	Inspection json.RawMessage `json:"inspection,omitempty"`
+	Inspection *MessageInspection `json:"inspection,omitempty"`
 
 	// PlainBody is the plain text body of the message.
 	// This is only included when the 'plain_body' expansion is requested.
@@ -44,39 +52,213 @@ type Message struct {
 	// This is only included when the 'attachments' expansion is requested.
 	Attachments []Attachment `json:"attachments,omitempty"`
 
-	// Headers is a map of headers included with the message.
+	// Headers contains the headers of the message.
 	// This is only included when the 'headers' expansion is requested.
-	Headers map[string]string `json:"headers,omitempty"`
+	Headers MessageHeaders `json:"headers,omitempty"`
 
-	// RawMessage is the raw RFC2822 message.
+	// RawMessage is the base64-encoded raw RFC2822 message.
 	// This is only included when the 'raw_message' expansion is requested.
+	// Use DecodeRawMessage to decode it.
 	RawMessage string `json:"raw_message,omitempty"`
 }
 
+// DecodeRawMessage returns the decoded raw RFC2822 message. It returns nil
+// if the 'raw_message' expansion wasn't requested.
+func (m *Message) DecodeRawMessage() ([]byte, error) {
+	if m.RawMessage == "" {
+		return nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(m.RawMessage)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding raw message: %w", err)
+	}
+	return data, nil
+}
+
 // MessageStatus represents the status of a message.
 // This structure is populated when the 'status' expansion is requested.
 type MessageStatus struct {
-	// Add status fields as needed based on API responses.
-	// Common fields might include:
-	// - Held (bool)
-	// - HoldExpiry (time.Time)
-	// - LastDeliveryAttempt (time.Time)
-	// - DeliveryStatus (string)
+	// Status is the delivery status of the message, such as StatusPending,
+	// StatusSent, or StatusHeld.
+	Status DeliveryStatus `json:"status"`
+
+	// LastDeliveryAttempt is when delivery was last attempted. It is zero
+	// if delivery hasn't been attempted yet.
+	LastDeliveryAttempt UnixTime `json:"last_delivery_attempt"`
+
+	// Held indicates whether the message is being held.
+	Held bool `json:"held"`
+
+	// HoldExpiry is when a held message will be released or discarded. It
+	// is zero if the message isn't held.
+	HoldExpiry UnixTime `json:"hold_expiry"`
 }
 
 // MessageDetails represents the details of a message.
 // This structure is populated when the 'details' expansion is requested.
 type MessageDetails struct {
-	// Add details fields as needed based on API responses.
-	// Common fields might include:
-	// - SpamScore (float64)
-	// - Size (int)
-	// - Bounce (bool)
-	// - Received (time.Time)
+	// RcptTo is the address the message was delivered to.
+	RcptTo string `json:"rcpt_to"`
+
+	// MailFrom is the envelope sender of the message.
+	MailFrom string `json:"mail_from"`
+
+	// Subject is the subject of the message.
+	Subject string `json:"subject"`
+
+	// MessageID is the value of the message's Message-ID header.
+	MessageID string `json:"message_id"`
+
+	// Timestamp is when the message was received by Postal.
+	Timestamp UnixTime `json:"timestamp"`
+
+	// Direction is "incoming" or "outgoing".
+	Direction string `json:"direction"`
+
+	// Size is the size of the raw message in bytes.
+	Size int `json:"size"`
+
+	// Bounce indicates whether the message is a bounce.
+	Bounce bool `json:"bounce"`
+
+	// BounceForID is the ID of the message this message is a bounce for,
+	// or zero if it isn't a bounce.
+	BounceForID int `json:"bounce_for_id"`
+
+	// Tag is the tag the message was sent with.
+	Tag string `json:"tag"`
+
+	// ReceivedWithSSL indicates whether the message was received over an
+	// encrypted connection.
+	ReceivedWithSSL bool `json:"received_with_ssl"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Postal returns
+// Size as a string on some versions, so both numbers and numeric strings
+// are accepted, and a null BounceForID decodes as zero.
+func (d *MessageDetails) UnmarshalJSON(data []byte) error {
+	type details MessageDetails
+	aux := struct {
+		*details
+		Size        json.RawMessage `json:"size"`
+		BounceForID json.RawMessage `json:"bounce_for_id"`
+	}{details: (*details)(d)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	var err error
+	if d.Size, err = flexibleInt(aux.Size); err != nil {
+		return fmt.Errorf("error decoding size: %w", err)
+	}
+	if d.BounceForID, err = flexibleInt(aux.BounceForID); err != nil {
+		return fmt.Errorf("error decoding bounce_for_id: %w", err)
+	}
+	return nil
+}
+
+// flexibleInt decodes a JSON number, numeric string, or null as an int.
+func flexibleInt(data json.RawMessage) (int, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return 0, nil
+	}
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return 0, err
+		}
+		if s == "" {
+			return 0, nil
+		}
+		return strconv.Atoi(s)
+	}
+	var n int
+	err := json.Unmarshal(data, &n)
+	return n, err
+}
+
+// MessageInspection represents the result of Postal's spam and threat
+// checks on a message.
+// This structure is populated when the 'inspection' expansion is requested.
+type MessageInspection struct {
+	// Inspected indicates whether the message has been inspected.
+	Inspected bool `json:"inspected"`
+
+	// Spam indicates whether the message was classified as spam.
+	Spam bool `json:"spam"`
+
+	// SpamScore is the score given to the message by the spam filter.
+	SpamScore float64 `json:"spam_score"`
+
+	// Threat indicates whether a threat, such as a virus, was found.
+	Threat bool `json:"threat"`
+
+	// ThreatDetails describes the threat, if one was found.
+	ThreatDetails string `json:"threat_details"`
+}
+
+// MessageHeaders holds the headers of a message, keyed by header name.
+// A header that appears more than once has several values.
+// This structure is populated when the 'headers' expansion is requested.
+type MessageHeaders map[string][]string
+
+// Get returns the first value of the named header, or "" if there is none.
+// The name is case-insensitive.
+func (h MessageHeaders) Get(name string) string {
+	if values := h.Values(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Values returns every value of the named header. The name is
+// case-insensitive.
+func (h MessageHeaders) Values(name string) []string {
+	if values, ok := h[name]; ok {
+		return values
+	}
+	canonical := textproto.CanonicalMIMEHeaderKey(name)
+	for key, values := range h {
+		if textproto.CanonicalMIMEHeaderKey(key) == canonical {
+			return values
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Postal returns
+// each header as a list of values, but single string values are accepted
+// too.
+func (h *MessageHeaders) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		*h = nil
+		return nil
+	}
+
+	headers := make(MessageHeaders, len(raw))
+	for name, value := range raw {
+		var values []string
+		if err := json.Unmarshal(value, &values); err != nil {
+			var single string
+			if err := json.Unmarshal(value, &single); err != nil {
+				return fmt.Errorf("error decoding header %s: %w", name, err)
+			}
+			values = []string{single}
+		}
+		headers[name] = values
+	}
+	*h = headers
+	return nil
 }
 
 // Attachment represents an attachment in a message.
-// This structure is populated when the 'attachments' expansion is requested.
+// It is used both to attach files when sending and, when the 'attachments'
+// expansion is requested, to describe the attachments of a message.
 type Attachment struct {
 	// Name is the filename of the attachment.
 	Name string `json:"name"`
@@ -89,6 +271,28 @@ type Attachment struct {
 
 	// Size is the size of the attachment in bytes.
 	Size int `json:"size"`
+
+	// Hash is the SHA1 hash of the attachment's content, as returned by
+	// the 'attachments' expansion.
+	Hash string `json:"hash,omitempty"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The messages
+// API calls the attachment's name "filename", so that is accepted as well
+// as "name".
+func (a *Attachment) UnmarshalJSON(data []byte) error {
+	type attachment Attachment
+	aux := struct {
+		*attachment
+		Filename string `json:"filename"`
+	}{attachment: (*attachment)(a)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if a.Name == "" {
+		a.Name = aux.Filename
+	}
+	return nil
 }
 
 // Delivery represents a delivery attempt for a message.
@@ -97,9 +301,9 @@ type Delivery struct {
 	// ID is the unique identifier for the delivery attempt.
 	ID int `json:"id"`
 
-	// Status is the current status of the delivery attempt.
-	// Common values include "delivered", "failed", "pending", etc.
-	Status string `json:"status"`
+	// Status is the status of the delivery attempt, such as StatusSent,
+	// StatusSoftFail, or StatusHardFail.
+	Status DeliveryStatus `json:"status"`
 
 	// Details provides additional information about the delivery attempt.
 	// This might include error messages or delivery confirmations.
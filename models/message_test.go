@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMessageDetailsUnmarshal(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		size        int
+		bounceForID int
+	}{
+		{"numbers", `{"size": 1024, "bounce_for_id": 7}`, 1024, 7},
+		{"strings", `{"size": "1024", "bounce_for_id": "7"}`, 1024, 7},
+		{"nulls", `{"size": null, "bounce_for_id": null}`, 0, 0},
+		{"missing", `{}`, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d MessageDetails
+			if err := json.Unmarshal([]byte(tt.input), &d); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if d.Size != tt.size {
+				t.Errorf("Expected size to be %d, got %d", tt.size, d.Size)
+			}
+
+			if d.BounceForID != tt.bounceForID {
+				t.Errorf("Expected bounce_for_id to be %d, got %d", tt.bounceForID, d.BounceForID)
+			}
+		})
+	}
+
+	var d MessageDetails
+	if err := json.Unmarshal([]byte(`{"size": "big"}`), &d); err == nil {
+		t.Error("Expected an error for a non-numeric size, got nil")
+	}
+}
+
+func TestMessageHeaders(t *testing.T) {
+	var h MessageHeaders
+	if err := json.Unmarshal([]byte(`{"received": ["a", "b"], "subject": "Hello"}`), &h); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := h.Get("Subject"); got != "Hello" {
+		t.Errorf("Expected subject to be Hello, got %s", got)
+	}
+
+	if got := h.Values("RECEIVED"); len(got) != 2 || got[1] != "b" {
+		t.Errorf("Expected 2 received headers, got %v", got)
+	}
+
+	if got := h.Get("X-Missing"); got != "" {
+		t.Errorf("Expected a missing header to be empty, got %s", got)
+	}
+}
+
+func TestAttachmentUnmarshal(t *testing.T) {
+	var a Attachment
+	if err := json.Unmarshal([]byte(`{"filename": "report.pdf", "content_type": "application/pdf", "size": 10}`), &a); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if a.Name != "report.pdf" {
+		t.Errorf("Expected name to be report.pdf, got %s", a.Name)
+	}
+
+	// Attachments in send requests still use "name"
+	data, err := json.Marshal(Attachment{Name: "a.txt", Data: "aGk="})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var fields map[string]any
+	_ = json.Unmarshal(data, &fields)
+	if fields["name"] != "a.txt" {
+		t.Errorf("Expected name to be marshaled as name, got %s", data)
+	}
+}
+
+func TestDecodeRawMessage(t *testing.T) {
+	m := Message{}
+	if data, err := m.DecodeRawMessage(); data != nil || err != nil {
+		t.Errorf("Expected nil without the expansion, got %q (%v)", data, err)
+	}
+
+	m.RawMessage = "SGk="
+	if data, err := m.DecodeRawMessage(); string(data) != "Hi" || err != nil {
+		t.Errorf("Expected Hi, got %q (%v)", data, err)
+	}
+
+	m.RawMessage = "!!"
+	if _, err := m.DecodeRawMessage(); err == nil {
+		t.Error("Expected an error for invalid base64, got nil")
+	}
+}
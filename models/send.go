@@ -4,6 +4,8 @@
 // through the Postal API, both in standard format and as raw RFC2822 messages.
 package models
 
+import "slices"
+
 // SendMessageRequest represents a request to send a message through the Postal API.
 // This structure is used with the SendMessage method to create and send an email.
 type SendMessageRequest struct {
@@ -91,4 +93,53 @@ type SendMessageResponse struct {
 	// Token is a unique token that can be used to reference the message.
 	// This is an alternative to using the MessageID.
 	Token string `json:"token"`
+
+	// Messages maps each recipient address to the message Postal created
+	// for it. Postal stores a separate message, with its own deliveries,
+	// for every recipient of a send.
+	Messages map[string]RecipientMessage `json:"messages,omitempty"`
+}
+
+// RecipientMessage identifies the message Postal created for one recipient
+// of a send.
+type RecipientMessage struct {
+	// ID is the ID of the message.
+	ID int `json:"id"`
+
+	// Token is the token of the message.
+	Token string `json:"token"`
+}
+
+// MessageIDs returns the IDs of the messages created for the recipients of
+// the send, in ascending order, without duplicates. If the response lists
+// no recipients, it returns MessageID alone.
+func (r *SendMessageResponse) MessageIDs() []int {
+	if len(r.Messages) == 0 {
+		return []int{r.MessageID}
+	}
+	ids := make([]int, 0, len(r.Messages))
+	for _, m := range r.Messages {
+		ids = append(ids, m.ID)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// Tokens returns the tokens of the messages created for the recipients of
+// the send, in the order of MessageIDs. If the response lists no
+// recipients, it returns Token alone.
+func (r *SendMessageResponse) Tokens() []string {
+	if len(r.Messages) == 0 {
+		return []string{r.Token}
+	}
+	byID := make(map[int]string, len(r.Messages))
+	for _, m := range r.Messages {
+		byID[m.ID] = m.Token
+	}
+	ids := r.MessageIDs()
+	tokens := make([]string, len(ids))
+	for i, id := range ids {
+		tokens[i] = byID[id]
+	}
+	return tokens
 }
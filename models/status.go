@@ -0,0 +1,171 @@
+// Package models provides data structures for the Postal API.
+//
+// This file contains the DeliveryStatus type and helpers for reading the
+// SMTP reply codes in a delivery's details and output.
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DeliveryStatus is the status of a message or of a delivery attempt, as
+// reported by Postal.
+type DeliveryStatus string
+
+// The delivery statuses Postal reports.
+const (
+	// StatusPending means the message is queued and has not been
+	// delivered yet.
+	StatusPending DeliveryStatus = "Pending"
+
+	// StatusSent means the receiving server accepted the message.
+	StatusSent DeliveryStatus = "Sent"
+
+	// StatusSoftFail means delivery failed temporarily. Postal retries it.
+	StatusSoftFail DeliveryStatus = "SoftFail"
+
+	// StatusHardFail means delivery failed permanently.
+	StatusHardFail DeliveryStatus = "HardFail"
+
+	// StatusHeld means the message is held, e.g. because it was flagged as
+	// spam or the recipient is on the suppression list, until it is
+	// released or expires.
+	StatusHeld DeliveryStatus = "Held"
+
+	// StatusBounced means the message bounced after it was accepted.
+	StatusBounced DeliveryStatus = "Bounced"
+
+	// StatusError means Postal could not attempt delivery, e.g. because of
+	// a configuration problem. Postal retries it.
+	StatusError DeliveryStatus = "Error"
+)
+
+// knownStatuses maps the lower-case form of each known status to the
+// status.
+var knownStatuses = map[string]DeliveryStatus{
+	"pending":  StatusPending,
+	"sent":     StatusSent,
+	"softfail": StatusSoftFail,
+	"hardfail": StatusHardFail,
+	"held":     StatusHeld,
+	"bounced":  StatusBounced,
+	"error":    StatusError,
+}
+
+// ParseDeliveryStatus returns the known status matching s regardless of
+// case, or s itself if it is not a known status.
+func ParseDeliveryStatus(s string) DeliveryStatus {
+	if status, ok := knownStatuses[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return status
+	}
+	return DeliveryStatus(s)
+}
+
+// String returns the status as a string.
+func (s DeliveryStatus) String() string {
+	return string(s)
+}
+
+// IsKnown reports whether s is one of the statuses defined above, spelled
+// the way Postal spells it.
+func (s DeliveryStatus) IsKnown() bool {
+	status, ok := knownStatuses[strings.ToLower(string(s))]
+	return ok && status == s
+}
+
+// IsTerminal reports whether Postal makes no further delivery attempts
+// after s: the message was sent, failed permanently, or bounced.
+func (s DeliveryStatus) IsTerminal() bool {
+	return s == StatusSent || s == StatusHardFail || s == StatusBounced
+}
+
+// IsSuccess reports whether s means the message was delivered.
+func (s DeliveryStatus) IsSuccess() bool {
+	return s == StatusSent
+}
+
+// IsRetryable reports whether s is a failure Postal retries on its own.
+func (s DeliveryStatus) IsRetryable() bool {
+	return s == StatusSoftFail || s == StatusError
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. It is lenient:
+// known statuses are matched regardless of case, unknown strings are kept
+// as they are, null decodes to "", and other JSON values are kept as their
+// JSON text.
+func (s *DeliveryStatus) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*s = ""
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		*s = DeliveryStatus(data)
+		return nil
+	}
+	*s = ParseDeliveryStatus(str)
+	return nil
+}
+
+// SMTPReply holds the reply codes found in an SMTP server's response.
+type SMTPReply struct {
+	// Code is the three-digit reply code, e.g. 550. It is zero if none was
+	// found.
+	Code int
+
+	// EnhancedCode is the RFC 3463 enhanced status code, e.g. "5.1.1". It
+	// is empty if none was found.
+	EnhancedCode string
+}
+
+// IsPermanent reports whether the reply is a permanent failure (5xx).
+func (r SMTPReply) IsPermanent() bool {
+	return r.Code/100 == 5 || r.Code == 0 && strings.HasPrefix(r.EnhancedCode, "5.")
+}
+
+// IsTransient reports whether the reply is a transient failure (4xx).
+func (r SMTPReply) IsTransient() bool {
+	return r.Code/100 == 4 || r.Code == 0 && strings.HasPrefix(r.EnhancedCode, "4.")
+}
+
+var (
+	// replyPattern matches a reply code, optionally followed by an
+	// enhanced status code, at the start of a line or after a separator.
+	replyPattern = regexp.MustCompile(`(?:^|[\s:(\[])([2-5][0-9]{2})(?:[ -]+([245]\.[0-9]{1,3}\.[0-9]{1,3}))?(?:[\s-]|$)`)
+
+	// enhancedPattern matches an enhanced status code on its own, but not
+	// part of a longer dotted number such as an IP address.
+	enhancedPattern = regexp.MustCompile(`(?:^|[^0-9.])([245]\.[0-9]{1,3}\.[0-9]{1,3})(?:\.?(?:[^0-9.]|$))`)
+)
+
+// ParseSMTPReply extracts the reply code and enhanced status code from
+// text such as a delivery's Output or Details, e.g.
+// "550 5.1.1 <user@example.org>: Recipient address rejected". It reports
+// whether any code was found.
+func ParseSMTPReply(text string) (SMTPReply, bool) {
+	var reply SMTPReply
+	if m := replyPattern.FindStringSubmatch(text); m != nil {
+		reply.Code, _ = strconv.Atoi(m[1])
+		reply.EnhancedCode = m[2]
+	}
+	if reply.EnhancedCode == "" {
+		if m := enhancedPattern.FindStringSubmatch(text); m != nil {
+			reply.EnhancedCode = m[1]
+		}
+	}
+	return reply, reply.Code != 0 || reply.EnhancedCode != ""
+}
+
+// SMTPReply returns the reply codes of the receiving server, parsed from
+// Output or, if it has none, from Details. It reports whether any code was
+// found.
+func (d *Delivery) SMTPReply() (SMTPReply, bool) {
+	if reply, ok := ParseSMTPReply(d.Output); ok {
+		return reply, true
+	}
+	return ParseSMTPReply(d.Details)
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDeliveryStatusClassification(t *testing.T) {
+	tests := []struct {
+		status                       DeliveryStatus
+		terminal, success, retryable bool
+	}{
+		{StatusPending, false, false, false},
+		{StatusSent, true, true, false},
+		{StatusSoftFail, false, false, true},
+		{StatusHardFail, true, false, false},
+		{StatusHeld, false, false, false},
+		{StatusBounced, true, false, false},
+		{StatusError, false, false, true},
+		{"Quarantined", false, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.IsTerminal(); got != tt.terminal {
+			t.Errorf("Expected %s.IsTerminal() to be %v, got %v", tt.status, tt.terminal, got)
+		}
+		if got := tt.status.IsSuccess(); got != tt.success {
+			t.Errorf("Expected %s.IsSuccess() to be %v, got %v", tt.status, tt.success, got)
+		}
+		if got := tt.status.IsRetryable(); got != tt.retryable {
+			t.Errorf("Expected %s.IsRetryable() to be %v, got %v", tt.status, tt.retryable, got)
+		}
+	}
+
+	if !StatusSent.IsKnown() || DeliveryStatus("sent").IsKnown() || DeliveryStatus("Quarantined").IsKnown() {
+		t.Error("Expected only Postal's spelling of known statuses to be known")
+	}
+}
+
+func TestDeliveryStatusUnmarshal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  DeliveryStatus
+	}{
+		{`"Sent"`, StatusSent},
+		{`"softfail"`, StatusSoftFail},
+		{`" HARDFAIL "`, StatusHardFail},
+		{`"Quarantined"`, "Quarantined"},
+		{`null`, ""},
+		{`3`, "3"},
+	}
+
+	for _, tt := range tests {
+		var d Delivery
+		if err := json.Unmarshal([]byte(`{"status": `+tt.input+`}`), &d); err != nil {
+			t.Fatalf("Expected no error for %s, got %v", tt.input, err)
+		}
+		if d.Status != tt.want {
+			t.Errorf("Expected %s to decode to %q, got %q", tt.input, tt.want, d.Status)
+		}
+	}
+
+	data, _ := json.Marshal(Delivery{Status: StatusHeld})
+	var d Delivery
+	if err := json.Unmarshal(data, &d); err != nil || d.Status != StatusHeld {
+		t.Errorf("Expected the status to round-trip, got %q and %v", d.Status, err)
+	}
+}
+
+func TestParseSMTPReply(t *testing.T) {
+	tests := []struct {
+		text     string
+		code     int
+		enhanced string
+		found    bool
+	}{
+		{"550 5.1.1 <user@example.org>: Recipient address rejected", 550, "5.1.1", true},
+		{"250 2.0.0 OK 1234 - gsmtp", 250, "2.0.0", true},
+		{"421-4.7.0 Try again later", 421, "4.7.0", true},
+		{"554 Message rejected", 554, "", true},
+		{"Permanent SMTP delivery error when sending to user@example.org: 550 5.7.1 Blocked", 550, "5.7.1", true},
+		{"Recipient rejected (5.1.1).", 0, "5.1.1", true},
+		{"Message accepted by mx.example.org (192.0.2.10)", 0, "", false},
+		{"Connection to 10.5.1.1 refused", 0, "", false},
+		{"", 0, "", false},
+	}
+
+	for _, tt := range tests {
+		reply, found := ParseSMTPReply(tt.text)
+		if found != tt.found || reply.Code != tt.code || reply.EnhancedCode != tt.enhanced {
+			t.Errorf("Expected %q to parse as %d %q %v, got %d %q %v", tt.text, tt.code, tt.enhanced, tt.found, reply.Code, reply.EnhancedCode, found)
+		}
+	}
+
+	if r := (SMTPReply{Code: 550}); !r.IsPermanent() || r.IsTransient() {
+		t.Errorf("Expected 550 to be permanent, got %+v", r)
+	}
+	if r := (SMTPReply{EnhancedCode: "4.2.2"}); r.IsPermanent() || !r.IsTransient() {
+		t.Errorf("Expected 4.2.2 to be transient, got %+v", r)
+	}
+}
+
+func TestDeliverySMTPReply(t *testing.T) {
+	d := Delivery{Details: "Message for user@example.org rejected: 550 5.1.1", Output: "552 5.2.2 Mailbox full"}
+	if reply, ok := d.SMTPReply(); !ok || reply.Code != 552 || reply.EnhancedCode != "5.2.2" {
+		t.Errorf("Expected the output's reply, got %+v", reply)
+	}
+
+	d.Output = ""
+	if reply, ok := d.SMTPReply(); !ok || reply.Code != 550 || reply.EnhancedCode != "5.1.1" {
+		t.Errorf("Expected the details' reply, got %+v", reply)
+	}
+
+	d.Details = "Message accepted"
+	if _, ok := d.SMTPReply(); ok {
+		t.Error("Expected no reply")
+	}
+}
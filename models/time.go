@@ -0,0 +1,69 @@
+// Package models provides data structures for the Postal API.
+//
+// This file contains the UnixTime type used for timestamps that Postal
+// encodes as fractional seconds since the Unix epoch.
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// UnixTime is a time.Time that is encoded in JSON as fractional seconds
+// since the Unix epoch, e.g. 1477945177.12994, which is how Postal encodes
+// timestamps in webhooks and message expansions.
+//
+// A JSON null decodes to the zero time, and the zero time encodes as null.
+type UnixTime struct {
+	time.Time
+}
+
+// NewUnixTime returns a UnixTime for the given number of seconds since the
+// Unix epoch.
+func NewUnixTime(seconds float64) UnixTime {
+	whole, frac := math.Modf(seconds)
+	return UnixTime{time.Unix(int64(whole), int64(math.Round(frac*1e6))*1e3).UTC()}
+}
+
+// Seconds returns t as fractional seconds since the Unix epoch.
+func (t UnixTime) Seconds() float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t UnixTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Seconds())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// It accepts a number of seconds, a numeric string, an RFC 3339 string,
+// or null.
+func (t *UnixTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*t = UnixTime{}
+		return nil
+	}
+
+	var seconds json.Number
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		f, err := seconds.Float64()
+		if err != nil {
+			return err
+		}
+		*t = NewUnixTime(f)
+		return nil
+	}
+
+	// Fall back to an RFC 3339 string
+	var parsed time.Time
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	*t = UnixTime{parsed}
+	return nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestUnixTimeUnmarshal(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{`1477945177.12994`, time.Date(2016, 10, 31, 20, 19, 37, 129940000, time.UTC)},
+		{`1477945177`, time.Date(2016, 10, 31, 20, 19, 37, 0, time.UTC)},
+		{`"1477945177"`, time.Date(2016, 10, 31, 20, 19, 37, 0, time.UTC)},
+		{`"2016-10-31T20:19:37Z"`, time.Date(2016, 10, 31, 20, 19, 37, 0, time.UTC)},
+		{`null`, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		var got UnixTime
+		if err := json.Unmarshal([]byte(tt.input), &got); err != nil {
+			t.Errorf("Expected no error for %s, got %v", tt.input, err)
+			continue
+		}
+
+		if !got.Equal(tt.expected) {
+			t.Errorf("Expected %s to decode to %s, got %s", tt.input, tt.expected, got.Time)
+		}
+	}
+
+	var got UnixTime
+	if err := json.Unmarshal([]byte(`"yesterday"`), &got); err == nil {
+		t.Error("Expected error for invalid timestamp, got nil")
+	}
+}
+
+func TestUnixTimeMarshal(t *testing.T) {
+	data, err := json.Marshal(NewUnixTime(1477945177.5))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if string(data) != "1477945177.5" {
+		t.Errorf("Expected 1477945177.5, got %s", data)
+	}
+
+	data, err = json.Marshal(UnixTime{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if string(data) != "null" {
+		t.Errorf("Expected null, got %s", data)
+	}
+}
@@ -0,0 +1,298 @@
+// Package models provides data structures for the Postal API.
+//
+// This file contains Validate methods that check send requests against the
+// rules Postal applies, so mistakes are caught before a round trip.
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/mail"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxRecipientsPerField is the largest number of addresses Postal accepts
+// in each of the To, CC, and BCC fields of a message.
+const MaxRecipientsPerField = 50
+
+// Error codes Postal returns for the problems Validate checks for. They
+// are reported in FieldError.Code.
+const (
+	CodeNoRecipients          = "NoRecipients"
+	CodeNoContent             = "NoContent"
+	CodeTooManyToAddresses    = "TooManyToAddresses"
+	CodeTooManyCCAddresses    = "TooManyCCAddresses"
+	CodeTooManyBCCAddresses   = "TooManyBCCAddresses"
+	CodeFromAddressMissing    = "FromAddressMissing"
+	CodeAttachmentMissingName = "AttachmentMissingName"
+	CodeAttachmentMissingData = "AttachmentMissingData"
+)
+
+// Address length limits from RFC 5321.
+const (
+	maxLocalPartLength = 64
+	maxDomainLength    = 253
+	maxLabelLength     = 63
+	maxAddressLength   = 254
+)
+
+// FieldError describes a problem with one field of a request.
+type FieldError struct {
+	// Field is the path of the field, using the JSON names of the request,
+	// e.g. "to[2]", "attachments[0].data", or `headers["X-Tag"]`.
+	Field string
+
+	// Code is the error code Postal would return for the problem, e.g.
+	// CodeNoRecipients, or empty if Postal has no code for it.
+	Code string
+
+	// Message describes the problem.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors lists the problems found by Validate, in field order.
+type ValidationErrors []*FieldError
+
+// Error implements the error interface.
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("%d validation errors: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the individual field errors, so errors.As can extract a
+// *FieldError.
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fe := range e {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// validator collects the problems found in a request.
+type validator struct {
+	errs ValidationErrors
+}
+
+// add records a problem with field.
+func (v *validator) add(field, code, format string, args ...any) {
+	v.errs = append(v.errs, &FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns the problems found as an error, or nil if there were none.
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// address checks a single address, which may include a display name.
+func (v *validator) address(field, address string) {
+	if msg := checkAddress(address); msg != "" {
+		v.add(field, "", "%s", msg)
+	}
+}
+
+// addresses checks a list of recipients and its length.
+func (v *validator) addresses(field, tooManyCode string, addresses []string) {
+	if len(addresses) > MaxRecipientsPerField {
+		v.add(field, tooManyCode, "has %d addresses, more than the maximum of %d", len(addresses), MaxRecipientsPerField)
+	}
+	for i, address := range addresses {
+		v.address(fmt.Sprintf("%s[%d]", field, i), address)
+	}
+}
+
+// Validate checks the request against Postal's rules without sending it:
+// that there are recipients and not too many of them, that every address
+// is valid, that a body is present, that attachments have a name, content
+// type, and base64 data, and that header names are legal. It returns nil
+// or ValidationErrors listing every problem found.
+//
+// Passing Validate does not guarantee Postal accepts the message, e.g. the
+// From address may still not be authorized for the server.
+func (r *SendMessageRequest) Validate() error {
+	var v validator
+	if len(r.To) == 0 && len(r.CC) == 0 && len(r.BCC) == 0 {
+		v.add("to", CodeNoRecipients, "no recipients in to, cc, or bcc")
+	}
+	v.addresses("to", CodeTooManyToAddresses, r.To)
+	v.addresses("cc", CodeTooManyCCAddresses, r.CC)
+	v.addresses("bcc", CodeTooManyBCCAddresses, r.BCC)
+
+	if r.From == "" {
+		v.add("from", CodeFromAddressMissing, "is required")
+	} else {
+		v.address("from", r.From)
+	}
+	if r.Sender != "" {
+		v.address("sender", r.Sender)
+	}
+	if r.ReplyTo != "" {
+		v.address("reply_to", r.ReplyTo)
+	}
+
+	if r.PlainBody == "" && r.HTMLBody == "" {
+		v.add("plain_body", CodeNoContent, "plain_body or html_body is required")
+	}
+
+	for i, a := range r.Attachments {
+		field := fmt.Sprintf("attachments[%d]", i)
+		if a.Name == "" {
+			v.add(field+".name", CodeAttachmentMissingName, "is required")
+		}
+		if a.ContentType == "" {
+			v.add(field+".content_type", "", "is required")
+		} else if _, _, err := mime.ParseMediaType(a.ContentType); err != nil {
+			v.add(field+".content_type", "", "invalid media type %q: %v", a.ContentType, err)
+		}
+		if a.Data == "" {
+			v.add(field+".data", CodeAttachmentMissingData, "is required")
+		} else if _, err := base64.StdEncoding.DecodeString(a.Data); err != nil {
+			v.add(field+".data", "", "invalid base64: %v", err)
+		}
+	}
+
+	// Check headers in a stable order
+	names := make([]string, 0, len(r.Headers))
+	for name := range r.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field := fmt.Sprintf("headers[%q]", name)
+		if !validHeaderName(name) {
+			v.add(field, "", "invalid header name")
+		}
+		if strings.ContainsAny(r.Headers[name], "\r\n") {
+			v.add(field, "", "value must not contain line breaks")
+		}
+	}
+
+	return v.err()
+}
+
+// Validate checks the request without sending it: that MailFrom and every
+// RcptTo address are valid and that Data is base64 holding a parseable
+// RFC 2822 message. It returns nil or ValidationErrors listing every
+// problem found.
+func (r *SendRawRequest) Validate() error {
+	var v validator
+	if r.MailFrom == "" {
+		v.add("mail_from", "", "is required")
+	} else {
+		v.address("mail_from", r.MailFrom)
+	}
+
+	if len(r.RcptTo) == 0 {
+		v.add("rcpt_to", "", "is required")
+	}
+	for i, address := range r.RcptTo {
+		v.address(fmt.Sprintf("rcpt_to[%d]", i), address)
+	}
+
+	if r.Data == "" {
+		v.add("data", "", "is required")
+	} else if data, err := base64.StdEncoding.DecodeString(r.Data); err != nil {
+		v.add("data", "", "invalid base64: %v", err)
+	} else if _, err := mail.ReadMessage(bytes.NewReader(data)); err != nil {
+		v.add("data", "", "invalid RFC 2822 message: %v", err)
+	}
+
+	return v.err()
+}
+
+// checkAddress returns a description of what is wrong with address, or ""
+// if it is a valid RFC 5322 address, optionally with a display name.
+// Internationalized local parts and domains are accepted.
+func checkAddress(address string) string {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return fmt.Sprintf("invalid address %q: %v", address, strings.TrimPrefix(err.Error(), "mail: "))
+	}
+
+	addr := parsed.Address
+	at := strings.LastIndexByte(addr, '@')
+	local, domain := addr[:at], addr[at+1:]
+	switch {
+	case len(addr) > maxAddressLength:
+		return fmt.Sprintf("invalid address %q: longer than %d characters", address, maxAddressLength)
+	case len(local) > maxLocalPartLength:
+		return fmt.Sprintf("invalid address %q: local part longer than %d characters", address, maxLocalPartLength)
+	}
+	if msg := checkDomain(domain); msg != "" {
+		return fmt.Sprintf("invalid address %q: %s", address, msg)
+	}
+	return ""
+}
+
+// checkDomain returns a description of what is wrong with domain, or "" if
+// it is a valid host name, internationalized domain name, or address
+// literal.
+func checkDomain(domain string) string {
+	if strings.HasPrefix(domain, "[") {
+		// net/mail has already checked the literal's syntax
+		return ""
+	}
+	if len(domain) > maxDomainLength {
+		return fmt.Sprintf("domain longer than %d characters", maxDomainLength)
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" {
+			return "domain has an empty label"
+		}
+		if utf8.RuneCountInString(label) > maxLabelLength {
+			return fmt.Sprintf("domain label %q longer than %d characters", label, maxLabelLength)
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Sprintf("domain label %q starts or ends with a hyphen", label)
+		}
+		for _, c := range label {
+			if !validDomainRune(c) {
+				return fmt.Sprintf("domain label %q contains %q", label, c)
+			}
+		}
+	}
+	return ""
+}
+
+// validDomainRune reports whether c may appear in a domain label: a
+// letter, digit, or hyphen, or a letter, digit, or mark of an
+// internationalized label.
+func validDomainRune(c rune) bool {
+	if c < utf8.RuneSelf {
+		return c == '-' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+	}
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.IsMark(c)
+}
+
+// validHeaderName reports whether name is a legal RFC 5322 field name:
+// one or more printable ASCII characters other than a colon.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c < 33 || c > 126 || c == ':' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fieldErrors returns the field errors in err, keyed by field.
+func fieldErrors(t *testing.T, err error) map[string]*FieldError {
+	t.Helper()
+	fields := make(map[string]*FieldError)
+	if err == nil {
+		return fields
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %T: %v", err, err)
+	}
+	for _, fe := range errs {
+		fields[fe.Field] = fe
+	}
+	return fields
+}
+
+// recipients returns n valid addresses.
+func recipients(n int) []string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = fmt.Sprintf("user%d@example.org", i)
+	}
+	return list
+}
+
+func TestSendMessageRequestValidate(t *testing.T) {
+	valid := func() *SendMessageRequest {
+		return &SendMessageRequest{
+			From:      "Sender <sender@example.com>",
+			To:        []string{"recipient@example.org"},
+			PlainBody: "Hi",
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *SendMessageRequest)
+		field  string
+		code   string
+	}{
+		{"valid", func(r *SendMessageRequest) {}, "", ""},
+		{"display name and IDN", func(r *SendMessageRequest) {
+			r.To = []string{`"Doe, Jane" <jane@example.org>`, "Jöhn <jöhn@bücher.de>", "user@例え.jp", "x@[192.0.2.1]"}
+		}, "", ""},
+		{"bcc only", func(r *SendMessageRequest) { r.To, r.BCC = nil, []string{"b@example.org"} }, "", ""},
+		{"no recipients", func(r *SendMessageRequest) { r.To = nil }, "to", "NoRecipients"},
+		{"too many to", func(r *SendMessageRequest) { r.To = recipients(51) }, "to", "TooManyToAddresses"},
+		{"too many cc", func(r *SendMessageRequest) { r.CC = recipients(51) }, "cc", "TooManyCCAddresses"},
+		{"too many bcc", func(r *SendMessageRequest) { r.BCC = recipients(51) }, "bcc", "TooManyBCCAddresses"},
+		{"invalid to", func(r *SendMessageRequest) { r.To = []string{"a@example.org", "nobody"} }, "to[1]", ""},
+		{"address list", func(r *SendMessageRequest) { r.CC = []string{"a@example.org, b@example.org"} }, "cc[0]", ""},
+		{"hyphen label", func(r *SendMessageRequest) { r.BCC = []string{"a@-example.org"} }, "bcc[0]", ""},
+		{"empty label", func(r *SendMessageRequest) { r.To = []string{"a@example..org"} }, "to[0]", ""},
+		{"bad domain rune", func(r *SendMessageRequest) { r.To = []string{"a@exa_mple.org"} }, "to[0]", ""},
+		{"long local part", func(r *SendMessageRequest) { r.To = []string{strings.Repeat("a", 65) + "@example.org"} }, "to[0]", ""},
+		{"long label", func(r *SendMessageRequest) { r.To = []string{"a@" + strings.Repeat("a", 64) + ".org"} }, "to[0]", ""},
+		{"missing from", func(r *SendMessageRequest) { r.From = "" }, "from", "FromAddressMissing"},
+		{"invalid from", func(r *SendMessageRequest) { r.From = "sender" }, "from", ""},
+		{"invalid sender", func(r *SendMessageRequest) { r.Sender = "@example.com" }, "sender", ""},
+		{"invalid reply to", func(r *SendMessageRequest) { r.ReplyTo = "reply" }, "reply_to", ""},
+		{"no content", func(r *SendMessageRequest) { r.PlainBody = "" }, "plain_body", "NoContent"},
+		{"html only", func(r *SendMessageRequest) { r.PlainBody, r.HTMLBody = "", "<p>Hi</p>" }, "", ""},
+		{"attachment name", func(r *SendMessageRequest) {
+			r.Attachments = []Attachment{{ContentType: "text/plain", Data: "SGk="}}
+		}, "attachments[0].name", "AttachmentMissingName"},
+		{"attachment content type", func(r *SendMessageRequest) {
+			r.Attachments = []Attachment{{Name: "a.txt", Data: "SGk="}}
+		}, "attachments[0].content_type", ""},
+		{"attachment invalid content type", func(r *SendMessageRequest) {
+			r.Attachments = []Attachment{{Name: "a.txt", ContentType: "text plain", Data: "SGk="}}
+		}, "attachments[0].content_type", ""},
+		{"attachment data", func(r *SendMessageRequest) {
+			r.Attachments = []Attachment{{Name: "a.txt", ContentType: "text/plain"}}
+		}, "attachments[0].data", "AttachmentMissingData"},
+		{"attachment base64", func(r *SendMessageRequest) {
+			r.Attachments = []Attachment{{Name: "a.txt", ContentType: "text/plain", Data: "SGk="}, {Name: "b.txt", ContentType: "text/plain", Data: "Hi!"}}
+		}, "attachments[1].data", ""},
+		{"header name", func(r *SendMessageRequest) { r.Headers = map[string]string{"X Tag": "a"} }, `headers["X Tag"]`, ""},
+		{"header colon", func(r *SendMessageRequest) { r.Headers = map[string]string{"X-Tag:": "a"} }, `headers["X-Tag:"]`, ""},
+		{"header value", func(r *SendMessageRequest) { r.Headers = map[string]string{"X-Tag": "a\r\nBcc: x@example.org"} }, `headers["X-Tag"]`, ""},
+		{"valid header", func(r *SendMessageRequest) { r.Headers = map[string]string{"X-Tag": "a"} }, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(req)
+			err := req.Validate()
+			fields := fieldErrors(t, err)
+
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if len(fields) != 1 {
+				t.Errorf("Expected 1 field error, got %v", err)
+			}
+			fe, ok := fields[tt.field]
+			if !ok {
+				t.Fatalf("Expected an error for %s, got %v", tt.field, err)
+			}
+			if fe.Code != tt.code {
+				t.Errorf("Expected code %q, got %q", tt.code, fe.Code)
+			}
+		})
+	}
+}
+
+func TestSendMessageRequestValidateMultipleErrors(t *testing.T) {
+	req := &SendMessageRequest{
+		To:      []string{"nobody"},
+		Subject: "Hi",
+		Headers: map[string]string{"B Bad": "x", "A Bad": "x"},
+	}
+	err := req.Validate()
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+
+	var fields []string
+	for _, fe := range errs {
+		fields = append(fields, fe.Field)
+	}
+	want := `[to[0] from plain_body headers["A Bad"] headers["B Bad"]]`
+	if fmt.Sprint(fields) != want {
+		t.Errorf("Expected fields %s, got %v", want, fields)
+	}
+
+	if !strings.HasPrefix(err.Error(), "5 validation errors: to[0]: invalid address") {
+		t.Errorf("Expected a summary of the errors, got %q", err.Error())
+	}
+
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "to[0]" {
+		t.Errorf("Expected errors.As to find the first field error, got %v", fe)
+	}
+}
+
+func TestSendRawRequestValidate(t *testing.T) {
+	message := base64.StdEncoding.EncodeToString([]byte("From: a@example.com\r\nTo: b@example.org\r\nSubject: Hi\r\n\r\nHello"))
+
+	tests := []struct {
+		name  string
+		req   SendRawRequest
+		field string
+	}{
+		{"valid", SendRawRequest{MailFrom: "a@example.com", RcptTo: []string{"b@example.org"}, Data: message}, ""},
+		{"missing mail from", SendRawRequest{RcptTo: []string{"b@example.org"}, Data: message}, "mail_from"},
+		{"invalid mail from", SendRawRequest{MailFrom: "a", RcptTo: []string{"b@example.org"}, Data: message}, "mail_from"},
+		{"missing rcpt to", SendRawRequest{MailFrom: "a@example.com", Data: message}, "rcpt_to"},
+		{"invalid rcpt to", SendRawRequest{MailFrom: "a@example.com", RcptTo: []string{"b@example.org", "c"}, Data: message}, "rcpt_to[1]"},
+		{"missing data", SendRawRequest{MailFrom: "a@example.com", RcptTo: []string{"b@example.org"}}, "data"},
+		{"invalid base64", SendRawRequest{MailFrom: "a@example.com", RcptTo: []string{"b@example.org"}, Data: "Hello!"}, "data"},
+		{"invalid message", SendRawRequest{MailFrom: "a@example.com", RcptTo: []string{"b@example.org"},
+			Data: base64.StdEncoding.EncodeToString([]byte("not a header\r\n\r\nHello"))}, "data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			fields := fieldErrors(t, err)
+
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if _, ok := fields[tt.field]; !ok || len(fields) != 1 {
+				t.Errorf("Expected a single error for %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
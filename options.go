@@ -0,0 +1,271 @@
+// This file contains New and the functional options it accepts.
+package postalclient
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultUserAgent is the User-Agent header sent by clients created with
+// New, unless WithUserAgent is used.
+const DefaultUserAgent = "postalclient-go"
+
+// apiPath is the path under which Postal serves its API.
+const apiPath = "/api/v1"
+
+// Option configures a Client created with New.
+type Option func(*clientOptions)
+
+// clientOptions holds the settings collected from the Options passed to New.
+type clientOptions struct {
+	baseURL     string
+	httpClient  *http.Client
+	timeout     time.Duration
+	hasTimeout  bool
+	userAgent   string
+	header      http.Header
+	retry       *RetryPolicy
+	rateLimiter *RateLimiter
+	logger      *slog.Logger
+	logOptions  LogOptions
+	transport   []TransportMiddleware
+	middleware  []Middleware
+	validate    bool
+	plainText   bool
+}
+
+// TransportMiddleware wraps the http.RoundTripper that carries the client's
+// requests, e.g. to sign requests for a proxy. It is called once, when the
+// client is created. Unlike Middleware, it sees each HTTP attempt rather
+// than each API operation, and only the encoded request and response.
+type TransportMiddleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to allow the use of ordinary functions as
+// http.RoundTrippers.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithBaseURL sets the base URL of the Postal API, which must be an http or
+// https URL ending in /api/v1, e.g. "https://postal.example.com/api/v1".
+// A trailing slash is removed. The default is DefaultBaseURL.
+func WithBaseURL(baseURL string) Option {
+	return func(o *clientOptions) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to make requests. The client is
+// copied, so options that change it, such as WithTimeout and
+// WithTransportMiddleware, do not affect the caller's value.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *clientOptions) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithTimeout sets the timeout for a single attempt, overriding the timeout
+// of the HTTP client. The default is DefaultTimeout. Zero means no timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+		o.hasTimeout = true
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+// The default is DefaultUserAgent.
+func WithUserAgent(userAgent string) Option {
+	return func(o *clientOptions) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithHeader adds a header sent with every request. It may be used more
+// than once. Headers that the client sets itself, such as
+// X-Server-API-Key, cannot be overridden.
+func WithHeader(name, value string) Option {
+	return func(o *clientOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Add(name, value)
+	}
+}
+
+// WithRetry sets the retry policy. See RetryPolicy.
+func WithRetry(policy *RetryPolicy) Option {
+	return func(o *clientOptions) {
+		o.retry = policy
+	}
+}
+
+// WithRateLimiter sets the rate limiter. See RateLimiter.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(o *clientOptions) {
+		o.rateLimiter = limiter
+	}
+}
+
+// WithLogger sets the logger the client reports its requests to.
+// By default nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *clientOptions) {
+		o.logger = logger
+	}
+}
+
+// WithLogOptions sets how addresses, subjects, and bodies appear in the
+// logger's records, and whether full request and response bodies are
+// dumped. See LogOptions.
+func WithLogOptions(opts LogOptions) Option {
+	return func(o *clientOptions) {
+		o.logOptions = opts
+	}
+}
+
+// WithValidation makes the client check messages with their Validate
+// method before sending them, so invalid messages fail with an
+// *InvalidRequestError instead of a round trip to Postal. The check is made
+// after the client's middleware, which sees the failed call with no
+// attempts. See models.SendMessageRequest.Validate.
+func WithValidation() Option {
+	return func(o *clientOptions) {
+		o.validate = true
+	}
+}
+
+// WithPlainTextFromHTML makes the client generate the plain text body of
+// messages that have an HTML body but no plain one, so that they reach
+// text-only clients and look less like spam. The text is derived with
+// htmltext.Convert, which can also be called directly.
+func WithPlainTextFromHTML() Option {
+	return func(o *clientOptions) {
+		o.plainText = true
+	}
+}
+
+// WithMiddleware wraps every API operation with the given middleware. It
+// may be used more than once. The first middleware is the outermost: it
+// sees each call first and each result last. See Middleware.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *clientOptions) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// WithTransportMiddleware wraps the client's HTTP transport with the given
+// middleware, which sees every HTTP attempt, including retries. It may be
+// used more than once. The first middleware is the outermost.
+func WithTransportMiddleware(middleware ...TransportMiddleware) Option {
+	return func(o *clientOptions) {
+		o.transport = append(o.transport, middleware...)
+	}
+}
+
+// New creates a new Postal API client with the given API key and options.
+// It returns an error if the API key is empty or an option is invalid.
+//
+// Example:
+//
+//	client, err := postalclient.New("your-api-key",
+//	    postalclient.WithBaseURL("https://postal.yourdomain.com/api/v1"),
+//	    postalclient.WithTimeout(10*time.Second),
+//	    postalclient.WithRetry(postalclient.DefaultRetryPolicy()),
+//	)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+func New(apiKey string, opts ...Option) (*Client, error) {
+	if apiKey == "" {
+		return nil, errors.New("an API key is required")
+	}
+
+	o := &clientOptions{
+		baseURL:   DefaultBaseURL,
+		userAgent: DefaultUserAgent,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	baseURL, err := normalizeBaseURL(o.baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy the HTTP client so changing it doesn't affect the caller's
+	var httpClient http.Client
+	if o.httpClient != nil {
+		httpClient = *o.httpClient
+	} else {
+		httpClient.Timeout = DefaultTimeout
+	}
+	if o.hasTimeout {
+		if o.timeout < 0 {
+			return nil, fmt.Errorf("invalid timeout %v: must not be negative", o.timeout)
+		}
+		httpClient.Timeout = o.timeout
+	}
+	if len(o.transport) > 0 {
+		transport := httpClient.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		for i := len(o.transport) - 1; i >= 0; i-- {
+			transport = o.transport[i](transport)
+		}
+		httpClient.Transport = transport
+	}
+
+	return &Client{
+		BaseURL:     baseURL,
+		APIKey:      apiKey,
+		HTTPClient:  &httpClient,
+		RetryPolicy: o.retry,
+		RateLimiter: o.rateLimiter,
+		UserAgent:   o.userAgent,
+		Header:      o.header,
+		Logger:      o.logger,
+		LogOptions:  o.logOptions,
+		Middleware:  o.middleware,
+
+		ValidateRequests:  o.validate,
+		GeneratePlainText: o.plainText,
+	}, nil
+}
+
+// normalizeBaseURL checks that rawURL is a valid Postal API base URL and
+// returns it without a trailing slash.
+func normalizeBaseURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid base URL %q: scheme must be http or https", rawURL)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid base URL %q: missing host", rawURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid base URL %q: must not have a query or fragment", rawURL)
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	if !strings.HasSuffix(u.Path, apiPath) {
+		suggestion := *u
+		suggestion.Path += apiPath
+		return "", fmt.Errorf("invalid base URL %q: must end with %s (did you mean %q?)", rawURL, apiPath, suggestion.String())
+	}
+	return u.String(), nil
+}
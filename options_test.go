@@ -0,0 +1,269 @@
+package postalclient
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	client, err := New("test-api-key")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if client.APIKey != "test-api-key" {
+		t.Errorf("Expected APIKey to be test-api-key, got %s", client.APIKey)
+	}
+
+	if client.BaseURL != DefaultBaseURL {
+		t.Errorf("Expected BaseURL to be %s, got %s", DefaultBaseURL, client.BaseURL)
+	}
+
+	if client.HTTPClient.Timeout != DefaultTimeout {
+		t.Errorf("Expected Timeout to be %v, got %v", DefaultTimeout, client.HTTPClient.Timeout)
+	}
+
+	if client.UserAgent != DefaultUserAgent {
+		t.Errorf("Expected UserAgent to be %s, got %s", DefaultUserAgent, client.UserAgent)
+	}
+
+	if client.RetryPolicy != nil || client.RateLimiter != nil || client.Logger != nil {
+		t.Errorf("Expected no retry policy, rate limiter, or logger, got %+v", client)
+	}
+
+	if _, err := New(""); err == nil {
+		t.Error("Expected an error for an empty API key, got nil")
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	httpClient := &http.Client{Timeout: time.Second}
+	policy := DefaultRetryPolicy()
+	limiter := NewRateLimiter(RateLimiterConfig{})
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	client, err := New("test-api-key",
+		WithBaseURL("https://postal.example.org/api/v1/"),
+		WithHTTPClient(httpClient),
+		WithTimeout(10*time.Second),
+		WithUserAgent("my-app/1.0"),
+		WithRetry(policy),
+		WithRateLimiter(limiter),
+		WithLogger(logger),
+		WithHeader("X-Tenant", "a"),
+		WithHeader("X-Tenant", "b"),
+		WithValidation(),
+		WithPlainTextFromHTML(),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if client.BaseURL != "https://postal.example.org/api/v1" {
+		t.Errorf("Expected the trailing slash to be removed, got %s", client.BaseURL)
+	}
+
+	if client.HTTPClient == httpClient || client.HTTPClient.Timeout != 10*time.Second {
+		t.Errorf("Expected a copy of the HTTP client with a 10s timeout, got %+v", client.HTTPClient)
+	}
+
+	if httpClient.Timeout != time.Second {
+		t.Errorf("Expected the caller's HTTP client to be unchanged, got %v", httpClient.Timeout)
+	}
+
+	if client.UserAgent != "my-app/1.0" || client.RetryPolicy != policy || client.RateLimiter != limiter || client.Logger != logger {
+		t.Errorf("Expected the options to be applied, got %+v", client)
+	}
+
+	if !client.ValidateRequests {
+		t.Error("Expected request validation to be enabled")
+	}
+
+	if !client.GeneratePlainText {
+		t.Error("Expected plain text generation to be enabled")
+	}
+
+	if got := client.Header.Values("X-Tenant"); len(got) != 2 {
+		t.Errorf("Expected 2 X-Tenant headers, got %v", got)
+	}
+
+	if _, err := New("test-api-key", WithTimeout(-time.Second)); err == nil {
+		t.Error("Expected an error for a negative timeout, got nil")
+	}
+}
+
+func TestNormalizeBaseURL(t *testing.T) {
+	valid := map[string]string{
+		"https://postal.example.com/api/v1":      "https://postal.example.com/api/v1",
+		"https://postal.example.com/api/v1/":     "https://postal.example.com/api/v1",
+		"http://localhost:5000/api/v1//":         "http://localhost:5000/api/v1",
+		" https://example.com/postal/api/v1 ":    "https://example.com/postal/api/v1",
+		"https://postal.example.com:8443/api/v1": "https://postal.example.com:8443/api/v1",
+	}
+	for input, want := range valid {
+		got, err := normalizeBaseURL(input)
+		if err != nil {
+			t.Errorf("Expected %q to be valid, got %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Expected %q to normalize to %q, got %q", input, want, got)
+		}
+	}
+
+	invalid := map[string]string{
+		"https://postal.example.com":          `did you mean "https://postal.example.com/api/v1"?`,
+		"https://postal.example.com/":         "must end with /api/v1",
+		"https://postal.example.com/api":      "must end with /api/v1",
+		"postal.example.com/api/v1":           "scheme must be http or https",
+		"ftp://postal.example.com/api/v1":     "scheme must be http or https",
+		"https:///api/v1":                     "missing host",
+		"https://postal.example.com/api/v1?x": "query or fragment",
+		"https://postal.example.com/%zz":      "invalid base URL",
+	}
+	for input, want := range invalid {
+		_, err := normalizeBaseURL(input)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error containing %q for %q, got %v", want, input, err)
+		}
+	}
+
+	if _, err := New("test-api-key", WithBaseURL("https://postal.example.com")); err == nil {
+		t.Error("Expected New to reject a base URL without /api/v1, got nil")
+	}
+}
+
+func TestNewSendsHeaders(t *testing.T) {
+	// Create a test server that records the request headers
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":{}}`))
+	}))
+	defer server.Close()
+
+	client, err := New("test-api-key",
+		WithBaseURL(server.URL+"/api/v1"),
+		WithUserAgent("my-app/1.0"),
+		WithHeader("X-Tenant", "acme"),
+		WithHeader("X-Server-API-Key", "overridden"),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := client.post("/test", nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := header.Get("User-Agent"); got != "my-app/1.0" {
+		t.Errorf("Expected User-Agent to be my-app/1.0, got %s", got)
+	}
+
+	if got := header.Get("X-Tenant"); got != "acme" {
+		t.Errorf("Expected X-Tenant to be acme, got %s", got)
+	}
+
+	if got := header.Values("X-Server-API-Key"); len(got) != 1 || got[0] != "test-api-key" {
+		t.Errorf("Expected the API key not to be overridden, got %v", got)
+	}
+}
+
+func TestWithTransportMiddleware(t *testing.T) {
+	// Create a test server that echoes a header back
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Order", strings.Join(r.Header.Values("X-Order"), ","))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":{}}`))
+	}))
+	defer server.Close()
+
+	var order []string
+	middleware := func(name string) TransportMiddleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+" request")
+				req.Header.Add("X-Order", name)
+				resp, err := next.RoundTrip(req)
+				order = append(order, name+" response")
+				return resp, err
+			})
+		}
+	}
+
+	httpClient := server.Client()
+	transport := httpClient.Transport
+	client, err := New("test-api-key",
+		WithBaseURL(server.URL+"/api/v1"),
+		WithHTTPClient(httpClient),
+		WithTransportMiddleware(middleware("outer")),
+		WithTransportMiddleware(middleware("inner")),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := client.postContext(context.Background(), "/test", nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := "outer request,inner request,inner response,outer response"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("Expected middleware order %q, got %q", want, got)
+	}
+
+	if httpClient.Transport != transport {
+		t.Error("Expected the caller's HTTP client transport to be unchanged")
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	// Create a test server that fails once before succeeding
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"status":"error","message":"unavailable"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":{}}`))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client, err := New("test-api-key",
+		WithBaseURL(server.URL+"/api/v1"),
+		WithRetry(fastRetryPolicy(2)),
+		WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := client.post("/test", nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	logs := buf.String()
+	for _, want := range []string{
+		`level=DEBUG msg="postal request failed, retrying" method=POST path=/test attempt=1 status_code=503`,
+		`level=DEBUG msg="postal request succeeded" method=POST path=/test attempt=2 status_code=200`,
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("Expected logs to contain %q, got:\n%s", want, logs)
+		}
+	}
+
+	if strings.Contains(logs, "test-api-key") {
+		t.Errorf("Expected logs not to contain the API key, got:\n%s", logs)
+	}
+}
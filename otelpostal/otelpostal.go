@@ -0,0 +1,259 @@
+// Package otelpostal instruments a postalclient.Client with OpenTelemetry
+// tracing and metrics.
+//
+// It lives in its own module so the core client keeps no dependencies.
+// Install it with a middleware:
+//
+//	client, err := postalclient.New("your-api-key",
+//	    postalclient.WithBaseURL("https://postal.yourdomain.com/api/v1"),
+//	    postalclient.WithMiddleware(otelpostal.Middleware()),
+//	)
+//
+// Every call creates a client span named after the Postal operation, e.g.
+// "send.message", with these attributes:
+//
+//   - postal.operation and postal.endpoint: the operation name and API path
+//   - http.request.method and http.response.status_code
+//   - postal.status and postal.error_code: the status and error code of
+//     Postal's response
+//   - postal.server_time: the processing time reported by Postal, in seconds
+//   - postal.recipient_count: the number of recipients of a send
+//   - postal.message_id: the ID of the message sent or looked up
+//   - postal.attempts: the number of HTTP requests made, including retries
+//
+// Addresses, subjects, bodies, attachments, and the API key are never
+// recorded. Error messages can contain addresses and request URLs too, so
+// failed calls record only the error's type, as classified by
+// postalclient.ErrorType.
+//
+// Calls also record these metrics, each with the postal.operation
+// attribute, and error.type for failed calls:
+//
+//   - postal.client.operation.duration: a histogram of call latency, in
+//     seconds, including retries and rate limiting
+//   - postal.client.operation.errors: a counter of failed calls
+//   - postal.client.operation.attempts: a histogram of HTTP requests per call
+//   - postal.server.duration: a histogram of Postal's reported processing
+//     time, in seconds
+package otelpostal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"github.com/Suhaibinator/postalclient-go/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name used for the tracer and
+// meter.
+const ScopeName = "github.com/Suhaibinator/postalclient-go/otelpostal"
+
+// Attribute keys set on spans and metrics.
+const (
+	AttrOperation      = attribute.Key("postal.operation")
+	AttrEndpoint       = attribute.Key("postal.endpoint")
+	AttrStatus         = attribute.Key("postal.status")
+	AttrErrorCode      = attribute.Key("postal.error_code")
+	AttrServerTime     = attribute.Key("postal.server_time")
+	AttrRecipientCount = attribute.Key("postal.recipient_count")
+	AttrMessageID      = attribute.Key("postal.message_id")
+	AttrAttempts       = attribute.Key("postal.attempts")
+	AttrHTTPMethod     = attribute.Key("http.request.method")
+	AttrHTTPStatusCode = attribute.Key("http.response.status_code")
+	AttrErrorType      = attribute.Key("error.type")
+)
+
+// Option configures Middleware.
+type Option func(*config)
+
+// config holds the settings for Middleware.
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// WithTracerProvider sets the tracer provider. The default is the global
+// provider, otel.GetTracerProvider().
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider sets the meter provider. The default is the global
+// provider, otel.GetMeterProvider().
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = provider
+	}
+}
+
+// instruments holds the metric instruments recorded by the middleware.
+type instruments struct {
+	duration   metric.Float64Histogram
+	errors     metric.Int64Counter
+	attempts   metric.Int64Histogram
+	serverTime metric.Float64Histogram
+}
+
+// Middleware returns a postalclient.Middleware that traces every call and
+// records its metrics. Errors creating the metric instruments are reported
+// to otel.Handle, and the affected instruments are left as no-ops.
+func Middleware(opts ...Option) postalclient.Middleware {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.tracerProvider == nil {
+		c.tracerProvider = otel.GetTracerProvider()
+	}
+	if c.meterProvider == nil {
+		c.meterProvider = otel.GetMeterProvider()
+	}
+
+	tracer := c.tracerProvider.Tracer(ScopeName)
+	inst := newInstruments(c.meterProvider.Meter(ScopeName))
+
+	return func(next postalclient.Handler) postalclient.Handler {
+		return func(ctx context.Context, call *postalclient.Call) error {
+			ctx, span := tracer.Start(ctx, call.Name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(requestAttributes(call)...),
+			)
+			defer span.End()
+
+			start := time.Now()
+			err := next(ctx, call)
+			elapsed := time.Since(start)
+
+			attrs := resultAttributes(call, err)
+			span.SetAttributes(attrs...)
+
+			metricAttrs := []attribute.KeyValue{AttrOperation.String(call.Name)}
+			if err != nil {
+				errorType := postalclient.ErrorType(err)
+				metricAttrs = append(metricAttrs, AttrErrorType.String(errorType))
+				span.SetAttributes(AttrErrorType.String(errorType))
+				span.SetStatus(codes.Error, errorDescription(err))
+				inst.errors.Add(ctx, 1, metric.WithAttributes(metricAttrs...))
+			}
+			set := metric.WithAttributes(metricAttrs...)
+			inst.duration.Record(ctx, elapsed.Seconds(), set)
+			inst.attempts.Record(ctx, int64(call.Attempts), set)
+			if serverTime, ok := serverTime(call, err); ok {
+				inst.serverTime.Record(ctx, serverTime, set)
+			}
+			return err
+		}
+	}
+}
+
+// newInstruments creates the metric instruments. Instruments that cannot be
+// created are replaced with no-ops.
+func newInstruments(meter metric.Meter) *instruments {
+	var inst instruments
+	var err error
+	if inst.duration, err = meter.Float64Histogram("postal.client.operation.duration",
+		metric.WithDescription("Duration of Postal API calls, including retries and rate limiting."),
+		metric.WithUnit("s"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	if inst.errors, err = meter.Int64Counter("postal.client.operation.errors",
+		metric.WithDescription("Number of failed Postal API calls."),
+		metric.WithUnit("{call}"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	if inst.attempts, err = meter.Int64Histogram("postal.client.operation.attempts",
+		metric.WithDescription("Number of HTTP requests made per Postal API call."),
+		metric.WithUnit("{request}"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	if inst.serverTime, err = meter.Float64Histogram("postal.server.duration",
+		metric.WithDescription("Processing time reported by the Postal server."),
+		metric.WithUnit("s"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	return &inst
+}
+
+// requestAttributes returns the span attributes known before the call.
+func requestAttributes(call *postalclient.Call) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		AttrOperation.String(call.Name),
+		AttrEndpoint.String(call.Path),
+		AttrHTTPMethod.String(call.Method),
+	}
+	switch req := call.Request.(type) {
+	case *models.SendMessageRequest:
+		attrs = append(attrs, AttrRecipientCount.Int(len(req.To)+len(req.CC)+len(req.BCC)))
+	case *models.SendRawRequest:
+		attrs = append(attrs, AttrRecipientCount.Int(len(req.RcptTo)))
+	case *postalclient.MessageRequest:
+		attrs = append(attrs, AttrMessageID.Int(req.ID))
+	case *postalclient.DeliveriesRequest:
+		attrs = append(attrs, AttrMessageID.Int(req.ID))
+	}
+	return attrs
+}
+
+// resultAttributes returns the span attributes known after the call.
+func resultAttributes(call *postalclient.Call, err error) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{AttrAttempts.Int(call.Attempts)}
+	if call.StatusCode != 0 {
+		attrs = append(attrs, AttrHTTPStatusCode.Int(call.StatusCode))
+	}
+	if serverTime, ok := serverTime(call, err); ok {
+		attrs = append(attrs, AttrServerTime.Float64(serverTime))
+	}
+
+	var apiErr *postalclient.Error
+	switch {
+	case errors.As(err, &apiErr):
+		attrs = append(attrs, AttrStatus.String(apiErr.Status))
+		if apiErr.ErrorCode != "" {
+			attrs = append(attrs, AttrErrorCode.String(apiErr.ErrorCode))
+		}
+	case err == nil && call.Envelope != nil:
+		attrs = append(attrs, AttrStatus.String(call.Envelope.Status))
+	}
+
+	if resp, ok := call.Response.(*models.SendMessageResponse); ok && err == nil {
+		attrs = append(attrs, AttrMessageID.Int(resp.MessageID))
+	}
+	return attrs
+}
+
+// serverTime returns the processing time reported by Postal for the call,
+// if a response was received.
+func serverTime(call *postalclient.Call, err error) (float64, bool) {
+	var apiErr *postalclient.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.Time, true
+	case err == nil && call.Envelope != nil:
+		return call.Envelope.Time, true
+	}
+	return 0, false
+}
+
+// errorDescription returns the span status description for err. Error
+// messages can include addresses and request URLs, so every error is
+// described by its postalclient.ErrorType only.
+func errorDescription(err error) string {
+	var apiErr *postalclient.Error
+	if errors.As(err, &apiErr) {
+		return "postal API error: " + postalclient.ErrorType(err)
+	}
+	return "postal client error: " + postalclient.ErrorType(err)
+}
@@ -0,0 +1,241 @@
+package otelpostal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"github.com/Suhaibinator/postalclient-go/models"
+	"github.com/Suhaibinator/postalclient-go/postaltest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// testSetup holds an instrumented client and the in-memory telemetry it
+// records to.
+type testSetup struct {
+	server *postaltest.Server
+	client *postalclient.Client
+	spans  *tracetest.InMemoryExporter
+	reader *sdkmetric.ManualReader
+}
+
+// newTestSetup returns a client for a fake Postal server, instrumented with
+// in-memory exporters.
+func newTestSetup(t *testing.T, opts ...postalclient.Option) *testSetup {
+	t.Helper()
+	server := postaltest.NewServer()
+	t.Cleanup(server.Close)
+
+	spans := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	opts = append([]postalclient.Option{
+		postalclient.WithBaseURL(server.URL),
+		postalclient.WithMiddleware(Middleware(
+			WithTracerProvider(tracerProvider),
+			WithMeterProvider(meterProvider),
+		)),
+	}, opts...)
+	client, err := postalclient.New(server.APIKey(), opts...)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return &testSetup{server: server, client: client, spans: spans, reader: reader}
+}
+
+// attrs returns a span's attributes as a map.
+func attrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+// collect returns the recorded metrics by name.
+func (s *testSetup) collect(t *testing.T) map[string]metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := s.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	metrics := make(map[string]metricdata.Metrics)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+func TestSendSpan(t *testing.T) {
+	s := newTestSetup(t)
+
+	resp, err := s.client.SendMessage(&models.SendMessageRequest{
+		From:      "secret-sender@example.com",
+		To:        []string{"a@example.org", "b@example.org"},
+		BCC:       []string{"c@example.org"},
+		Subject:   "Secret subject",
+		PlainBody: "Secret body",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	spans := s.spans.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name != postalclient.OperationSendMessage || span.SpanKind != trace.SpanKindClient {
+		t.Errorf("Expected a client span named send.message, got %s (%v)", span.Name, span.SpanKind)
+	}
+
+	if span.Status.Code != codes.Unset {
+		t.Errorf("Expected an unset status, got %v", span.Status)
+	}
+
+	a := attrs(span)
+	want := map[attribute.Key]attribute.Value{
+		AttrOperation:      attribute.StringValue("send.message"),
+		AttrEndpoint:       attribute.StringValue("/send/message"),
+		AttrHTTPMethod:     attribute.StringValue("POST"),
+		AttrHTTPStatusCode: attribute.IntValue(200),
+		AttrStatus:         attribute.StringValue("success"),
+		AttrRecipientCount: attribute.IntValue(3),
+		AttrMessageID:      attribute.IntValue(resp.MessageID),
+		AttrAttempts:       attribute.IntValue(1),
+	}
+	for key, value := range want {
+		if a[key] != value {
+			t.Errorf("Expected %s to be %v, got %v", key, value.Emit(), a[key].Emit())
+		}
+	}
+
+	if _, ok := a[AttrServerTime]; !ok {
+		t.Error("Expected the server time to be recorded")
+	}
+
+	// Nothing sensitive is recorded
+	for _, kv := range span.Attributes {
+		if v := kv.Value.Emit(); strings.Contains(v, "example") || strings.Contains(v, "Secret") || strings.Contains(v, s.server.APIKey()) {
+			t.Errorf("Expected no sensitive values, got %s=%s", kv.Key, v)
+		}
+	}
+}
+
+func TestErrorSpanAndMetrics(t *testing.T) {
+	s := newTestSetup(t)
+
+	// A Postal error
+	_, err := s.client.GetMessageDeliveries(42)
+	if !errors.Is(err, postalclient.ErrMessageNotFound) {
+		t.Fatalf("Expected ErrMessageNotFound, got %v", err)
+	}
+
+	span := s.spans.GetSpans()[0]
+	if span.Status.Code != codes.Error || span.Status.Description != "postal API error: MessageNotFound" {
+		t.Errorf("Expected an error status with the code only, got %+v", span.Status)
+	}
+
+	a := attrs(span)
+	if a[AttrErrorCode].AsString() != "MessageNotFound" || a[AttrStatus].AsString() != "error" || a[AttrMessageID].AsInt64() != 42 {
+		t.Errorf("Expected the error code, status, and message ID, got %v", span.Attributes)
+	}
+
+	// A successful lookup
+	send, err := s.client.SendMessage(&models.SendMessageRequest{From: "a@example.com", To: []string{"b@example.org"}, PlainBody: "Hi"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := s.client.GetMessage(send.MessageID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	metrics := s.collect(t)
+
+	duration, ok := metrics["postal.client.operation.duration"].Data.(metricdata.Histogram[float64])
+	if !ok || len(duration.DataPoints) != 3 {
+		t.Fatalf("Expected 3 duration series, got %+v", metrics["postal.client.operation.duration"])
+	}
+
+	errorCounts, ok := metrics["postal.client.operation.errors"].Data.(metricdata.Sum[int64])
+	if !ok || len(errorCounts.DataPoints) != 1 {
+		t.Fatalf("Expected 1 error series, got %+v", metrics["postal.client.operation.errors"])
+	}
+
+	point := errorCounts.DataPoints[0]
+	operation, _ := point.Attributes.Value(AttrOperation)
+	errorType, _ := point.Attributes.Value(AttrErrorType)
+	if point.Value != 1 || operation.AsString() != "messages.deliveries" || errorType.AsString() != "MessageNotFound" {
+		t.Errorf("Expected 1 MessageNotFound error for messages.deliveries, got %v %v %v", point.Value, operation.Emit(), errorType.Emit())
+	}
+
+	serverTime, ok := metrics["postal.server.duration"].Data.(metricdata.Histogram[float64])
+	if !ok || len(serverTime.DataPoints) != 3 {
+		t.Errorf("Expected 3 server time series, got %+v", metrics["postal.server.duration"])
+	}
+}
+
+func TestRetriesAndTransportErrors(t *testing.T) {
+	s := newTestSetup(t, postalclient.WithRetry(&postalclient.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}))
+	s.server.Script(postaltest.EndpointSendMessage).
+		Next(2, postaltest.Unavailable(0)).
+		Always(postaltest.Disconnect())
+
+	_, err := s.client.SendMessage(&models.SendMessageRequest{From: "a@example.com", To: []string{"b@example.org"}, PlainBody: "Hi"})
+	var transportErr *postalclient.TransportError
+	if !errors.As(err, &transportErr) {
+		t.Fatalf("Expected a TransportError, got %v", err)
+	}
+
+	a := attrs(s.spans.GetSpans()[0])
+	if a[AttrAttempts].AsInt64() != 3 || a[AttrErrorType].AsString() != "transport" {
+		t.Errorf("Expected 3 attempts and a transport error, got %v", s.spans.GetSpans()[0].Attributes)
+	}
+
+	// The status leaves out the error message, which includes the URL
+	if status := s.spans.GetSpans()[0].Status; status.Description != "postal client error: transport" {
+		t.Errorf("Expected the status to describe only the error type, got %q", status.Description)
+	}
+
+	attempts, ok := s.collect(t)["postal.client.operation.attempts"].Data.(metricdata.Histogram[int64])
+	if !ok || len(attempts.DataPoints) != 1 || attempts.DataPoints[0].Sum != 3 {
+		t.Errorf("Expected 3 attempts to be recorded, got %+v", attempts)
+	}
+}
+
+func TestSpanParent(t *testing.T) {
+	s := newTestSetup(t)
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(s.spans)).Tracer("test")
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	if _, err := s.client.GetMessageContext(ctx, 1); err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+	parent.End()
+
+	spans := s.spans.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+
+	if spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Error("Expected the call's span to be a child of the parent span")
+	}
+}
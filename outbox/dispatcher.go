@@ -0,0 +1,400 @@
+// This file contains the Dispatcher, which adds messages to a Store and
+// delivers them with a pool of background workers.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+const (
+	// DefaultWorkers is the default number of concurrent senders.
+	DefaultWorkers = 4
+
+	// DefaultMaxAttempts is the default number of sends attempted for an
+	// entry before it is dead-lettered.
+	DefaultMaxAttempts = 10
+
+	// DefaultInitialBackoff is the default delay before an entry's first
+	// retry.
+	DefaultInitialBackoff = time.Second
+
+	// DefaultMaxBackoff is the default upper bound for the delay between
+	// retries.
+	DefaultMaxBackoff = 5 * time.Minute
+
+	// DefaultPollInterval is the default interval at which idle workers
+	// look for entries that became due.
+	DefaultPollInterval = time.Second
+)
+
+var (
+	// ErrStarted is returned by Start if the dispatcher is already running.
+	ErrStarted = errors.New("outbox: dispatcher already started")
+
+	// ErrStopping is returned by Start if workers of a previous run, whose
+	// Shutdown ran out of time, are still sending.
+	ErrStopping = errors.New("outbox: previous workers still running")
+
+	// ErrNotDead is returned by Requeue for entries that are not dead.
+	ErrNotDead = errors.New("outbox: entry is not dead")
+)
+
+// Option configures a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithWorkers sets the number of entries sent concurrently. The default is
+// DefaultWorkers.
+func WithWorkers(n int) Option {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.workers = n
+		}
+	}
+}
+
+// WithMaxAttempts sets the number of sends attempted for an entry before
+// it is dead-lettered. The default is DefaultMaxAttempts.
+func WithMaxAttempts(n int) Option {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.maxAttempts = n
+		}
+	}
+}
+
+// WithBackoff sets the delay before an entry's first retry, which doubles
+// with every further attempt up to max. The defaults are
+// DefaultInitialBackoff and DefaultMaxBackoff.
+func WithBackoff(initial, max time.Duration) Option {
+	return func(d *Dispatcher) {
+		if initial > 0 {
+			d.initialBackoff = initial
+		}
+		if max > 0 {
+			d.maxBackoff = max
+		}
+	}
+}
+
+// WithPollInterval sets how often idle workers look for entries that
+// became due. The default is DefaultPollInterval.
+func WithPollInterval(interval time.Duration) Option {
+	return func(d *Dispatcher) {
+		if interval > 0 {
+			d.pollInterval = interval
+		}
+	}
+}
+
+// WithLogger sets the logger the dispatcher reports failed sends and store
+// errors to. By default nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(d *Dispatcher) {
+		if logger != nil {
+			d.logger = logger
+		}
+	}
+}
+
+// Dispatcher adds messages to a Store and delivers them to Postal with a
+// pool of background workers.
+//
+// Sends that fail with a transport error or a Postal error are retried
+// with exponential backoff. Messages Postal rejects as invalid (a
+// *postalclient.SendError or *postalclient.ParameterError, or a
+// *postalclient.InvalidRequestError from the client's own validation) are
+// dead-lettered at once, and other messages after the maximum number of
+// attempts.
+//
+// Delivery is at least once: if the process stops after Postal accepted a
+// message but before the entry was marked as sent, the message is sent
+// again when the dispatcher restarts.
+type Dispatcher struct {
+	client *postalclient.Client
+	store  Store
+
+	workers        int
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	pollInterval   time.Duration
+	logger         *slog.Logger
+
+	// now returns the current time. Tests replace it.
+	now func() time.Time
+
+	// wake signals an idle worker that an entry was added.
+	wake chan struct{}
+
+	mu   sync.Mutex
+	stop chan struct{}
+
+	// done is closed when the workers of the last run have exited.
+	done chan struct{}
+}
+
+// NewDispatcher returns a Dispatcher that delivers the entries of store
+// with client. Call Start to begin sending.
+func NewDispatcher(client *postalclient.Client, store Store, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		client:         client,
+		store:          store,
+		workers:        DefaultWorkers,
+		maxAttempts:    DefaultMaxAttempts,
+		initialBackoff: DefaultInitialBackoff,
+		maxBackoff:     DefaultMaxBackoff,
+		pollInterval:   DefaultPollInterval,
+		logger:         slog.New(slog.DiscardHandler),
+		now:            time.Now,
+		wake:           make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Enqueue saves req to the outbox and returns its entry. The message is
+// sent in the background once the dispatcher is started.
+func (d *Dispatcher) Enqueue(ctx context.Context, req *models.SendMessageRequest) (*Entry, error) {
+	if req == nil {
+		return nil, errors.New("outbox: nil message")
+	}
+	return d.add(ctx, &Entry{Message: req})
+}
+
+// EnqueueRaw saves req to the outbox and returns its entry. The message is
+// sent in the background once the dispatcher is started.
+func (d *Dispatcher) EnqueueRaw(ctx context.Context, req *models.SendRawRequest) (*Entry, error) {
+	if req == nil {
+		return nil, errors.New("outbox: nil message")
+	}
+	return d.add(ctx, &Entry{Raw: req})
+}
+
+// add saves a new pending entry and wakes a worker.
+func (d *Dispatcher) add(ctx context.Context, entry *Entry) (*Entry, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, fmt.Errorf("error generating outbox entry ID: %w", err)
+	}
+	now := d.now()
+	entry.ID = id
+	entry.Status = StatusPending
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+	entry.NextAttemptAt = now
+	if err := d.store.Add(ctx, entry); err != nil {
+		return nil, fmt.Errorf("error adding outbox entry: %w", err)
+	}
+	d.notify()
+	return entry, nil
+}
+
+// Status returns the entry with the given ID, or ErrNotFound.
+func (d *Dispatcher) Status(ctx context.Context, id string) (*Entry, error) {
+	return d.store.Get(ctx, id)
+}
+
+// Requeue moves a dead entry back to pending, with a fresh set of
+// attempts. It returns ErrNotDead for entries in any other status.
+func (d *Dispatcher) Requeue(ctx context.Context, id string) error {
+	entry, err := d.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if entry.Status != StatusDead {
+		return ErrNotDead
+	}
+	now := d.now()
+	entry.Status = StatusPending
+	entry.Attempts = 0
+	entry.UpdatedAt = now
+	entry.NextAttemptAt = now
+	if err := d.store.Update(ctx, entry); err != nil {
+		return err
+	}
+	d.notify()
+	return nil
+}
+
+// Start begins sending entries in the background. Entries left in
+// StatusSending by a previous run that stopped mid-send are returned to
+// pending first. It returns ErrStarted if the dispatcher is running, and
+// ErrStopping if sends from before a Shutdown that ran out of time are
+// still in progress; call Shutdown again to wait for them.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		return ErrStarted
+	}
+	if d.done != nil {
+		select {
+		case <-d.done:
+		default:
+			return ErrStopping
+		}
+	}
+
+	// Recover entries claimed by a previous run
+	stale, err := d.store.List(ctx, StatusSending)
+	if err != nil {
+		return fmt.Errorf("error listing outbox entries: %w", err)
+	}
+	for _, entry := range stale {
+		entry.Status = StatusPending
+		entry.UpdatedAt = d.now()
+		if err := d.store.Update(ctx, entry); err != nil {
+			return fmt.Errorf("error recovering outbox entry %s: %w", entry.ID, err)
+		}
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	d.stop, d.done = stop, done
+	var running sync.WaitGroup
+	for i := 0; i < d.workers; i++ {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			d.work(stop)
+		}()
+	}
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	return nil
+}
+
+// Shutdown stops the workers from claiming new entries and waits for the
+// sends in progress to finish, or for ctx to end. Sends are not
+// interrupted, and the dispatcher cannot be started again until they
+// finish; calling Shutdown again after it ran out of time waits for them.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if d.stop != nil {
+		close(d.stop)
+		d.stop = nil
+	}
+	done := d.done
+	d.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// notify wakes an idle worker, if any.
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work claims and sends entries until stop is closed.
+func (d *Dispatcher) work(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		entries, err := d.store.Claim(context.Background(), d.now(), 1)
+		if err != nil {
+			d.logger.Error("outbox claim failed", slog.Any("error", err))
+		}
+		if len(entries) == 0 {
+			select {
+			case <-stop:
+				return
+			case <-d.wake:
+			case <-time.After(d.pollInterval):
+			}
+			continue
+		}
+		d.deliver(entries[0])
+	}
+}
+
+// deliver sends a claimed entry and records the outcome.
+func (d *Dispatcher) deliver(entry *Entry) {
+	// The send is not bound to the dispatcher's lifetime, so that a
+	// shutdown doesn't abandon a message Postal may already have accepted.
+	ctx := context.Background()
+
+	var resp *models.SendMessageResponse
+	var err error
+	switch {
+	case entry.Message != nil:
+		resp, err = d.client.SendMessageContext(ctx, entry.Message)
+	case entry.Raw != nil:
+		resp, err = d.client.SendRawContext(ctx, entry.Raw)
+	default:
+		err = errors.New("outbox: entry has no message")
+	}
+
+	now := d.now()
+	entry.Attempts++
+	entry.UpdatedAt = now
+	switch {
+	case err == nil:
+		entry.Status = StatusSent
+		entry.LastError = ""
+		entry.MessageID = resp.MessageID
+		entry.Token = resp.Token
+	case permanent(err) || entry.Attempts >= d.maxAttempts:
+		entry.Status = StatusDead
+		entry.LastError = err.Error()
+		d.logger.Error("outbox entry dead-lettered",
+			slog.String("id", entry.ID), slog.Int("attempts", entry.Attempts), slog.String("error_type", postalclient.ErrorType(err)))
+	default:
+		entry.Status = StatusPending
+		entry.LastError = err.Error()
+		entry.NextAttemptAt = now.Add(d.backoff(entry.Attempts))
+		d.logger.Warn("outbox send failed, retrying",
+			slog.String("id", entry.ID), slog.Int("attempts", entry.Attempts), slog.String("error_type", postalclient.ErrorType(err)),
+			slog.Time("next_attempt_at", entry.NextAttemptAt))
+	}
+
+	if err := d.store.Update(ctx, entry); err != nil {
+		d.logger.Error("outbox update failed", slog.String("id", entry.ID), slog.Any("error", err))
+	}
+}
+
+// backoff returns the delay before the retry following the given number
+// of attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.initialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.maxBackoff {
+			return d.maxBackoff
+		}
+	}
+	return min(delay, d.maxBackoff)
+}
+
+// permanent reports whether err means the message will never be accepted.
+func permanent(err error) bool {
+	var sendErr *postalclient.SendError
+	var paramErr *postalclient.ParameterError
+	var invalidErr *postalclient.InvalidRequestError
+	return errors.As(err, &sendErr) || errors.As(err, &paramErr) || errors.As(err, &invalidErr)
+}
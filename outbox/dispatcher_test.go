@@ -0,0 +1,244 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+	"github.com/Suhaibinator/postalclient-go/postaltest"
+)
+
+// newTestDispatcher returns a fake Postal server and a dispatcher for it
+// with fast retries. The dispatcher is shut down when the test ends.
+func newTestDispatcher(t *testing.T, store Store, opts ...Option) (*postaltest.Server, *Dispatcher) {
+	t.Helper()
+	server := postaltest.NewServer()
+	t.Cleanup(server.Close)
+
+	opts = append([]Option{WithBackoff(time.Millisecond, 10*time.Millisecond), WithPollInterval(5 * time.Millisecond)}, opts...)
+	d := NewDispatcher(server.Client(), store, opts...)
+	t.Cleanup(func() {
+		_ = d.Shutdown(context.Background())
+	})
+	return server, d
+}
+
+// waitForStatus waits until the entry with the given ID has status.
+func waitForStatus(t *testing.T, d *Dispatcher, id string, status Status) *Entry {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		entry, err := d.Status(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if entry.Status == status {
+			return entry
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected entry %s to be %s, got %+v", id, status, entry)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// testOutboxMessage returns a valid message.
+func testOutboxMessage() *models.SendMessageRequest {
+	return &models.SendMessageRequest{From: "a@example.com", To: []string{"b@example.org"}, Subject: "Hi", PlainBody: "Hi"}
+}
+
+func TestDispatcherSends(t *testing.T) {
+	server, d := newTestDispatcher(t, NewMemoryStore())
+	ctx := context.Background()
+
+	// Entries added before Start are kept until then
+	entry, err := d.Enqueue(ctx, testOutboxMessage())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	raw, err := d.EnqueueRaw(ctx, &models.SendRawRequest{MailFrom: "a@example.com", RcptTo: []string{"c@example.org"}, Data: "SGk="})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if entry.Status != StatusPending {
+		t.Errorf("Expected a pending entry, got %s", entry.Status)
+	}
+
+	if err := d.Start(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := d.Start(ctx); !errors.Is(err, ErrStarted) {
+		t.Errorf("Expected ErrStarted, got %v", err)
+	}
+
+	sent := waitForStatus(t, d, entry.ID, StatusSent)
+	if sent.Attempts != 1 || sent.MessageID == 0 || sent.Token == "" {
+		t.Errorf("Expected 1 attempt with a message ID and token, got %+v", sent)
+	}
+
+	if m := server.Message(sent.MessageID); m == nil || m.Request == nil || m.Request.Subject != "Hi" {
+		t.Errorf("Expected the message to reach the server, got %+v", m)
+	}
+
+	sentRaw := waitForStatus(t, d, raw.ID, StatusSent)
+	if m := server.Message(sentRaw.MessageID); m == nil || m.Raw == nil {
+		t.Errorf("Expected the raw message to reach the server, got %+v", m)
+	}
+
+	// Entries added while running are sent promptly
+	next, err := d.Enqueue(ctx, testOutboxMessage())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	waitForStatus(t, d, next.ID, StatusSent)
+
+	if _, err := d.Status(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	server, d := newTestDispatcher(t, NewMemoryStore())
+	server.Script(postaltest.EndpointSendMessage).Next(2, postaltest.Unavailable(0))
+	ctx := context.Background()
+
+	if err := d.Start(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	entry, err := d.Enqueue(ctx, testOutboxMessage())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	sent := waitForStatus(t, d, entry.ID, StatusSent)
+	if sent.Attempts != 3 || sent.LastError != "" {
+		t.Errorf("Expected 3 attempts and no error, got %+v", sent)
+	}
+}
+
+func TestDispatcherDeadLetters(t *testing.T) {
+	server, d := newTestDispatcher(t, NewMemoryStore(), WithMaxAttempts(2))
+	ctx := context.Background()
+	if err := d.Start(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// An invalid message is dead-lettered at once
+	invalid, err := d.Enqueue(ctx, &models.SendMessageRequest{From: "a@example.com", PlainBody: "Hi"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	dead := waitForStatus(t, d, invalid.ID, StatusDead)
+	if dead.Attempts != 1 || dead.LastError == "" {
+		t.Errorf("Expected 1 attempt and an error, got %+v", dead)
+	}
+
+	// A failing message is dead-lettered after the maximum attempts
+	server.Script(postaltest.EndpointSendMessage).Always(postaltest.Unavailable(0))
+	failing, err := d.Enqueue(ctx, testOutboxMessage())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	dead = waitForStatus(t, d, failing.ID, StatusDead)
+	if dead.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", dead.Attempts)
+	}
+
+	// Requeuing it sends it again
+	if err := d.Requeue(ctx, dead.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	server.ClearScripts()
+	sent := waitForStatus(t, d, failing.ID, StatusSent)
+	if sent.Attempts != 1 {
+		t.Errorf("Expected 1 attempt after requeuing, got %d", sent.Attempts)
+	}
+
+	if err := d.Requeue(ctx, sent.ID); !errors.Is(err, ErrNotDead) {
+		t.Errorf("Expected ErrNotDead, got %v", err)
+	}
+}
+
+func TestDispatcherRecoversClaimedEntries(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	if err := store.Add(ctx, &Entry{ID: "stale", Message: testOutboxMessage(), Status: StatusSending, CreatedAt: now, NextAttemptAt: now}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, d := newTestDispatcher(t, store)
+	if err := d.Start(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	waitForStatus(t, d, "stale", StatusSent)
+}
+
+func TestDispatcherShutdownWaitsForSends(t *testing.T) {
+	server, d := newTestDispatcher(t, NewMemoryStore(), WithWorkers(1))
+	server.Script(postaltest.EndpointSendMessage).Next(1, postaltest.Delay(100*time.Millisecond))
+	ctx := context.Background()
+
+	if err := d.Start(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	first, _ := d.Enqueue(ctx, testOutboxMessage())
+
+	// Wait for the send to reach the server, then shut down
+	deadline := time.Now().Add(time.Second)
+	for server.Calls(postaltest.EndpointSendMessage) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	second, _ := d.Enqueue(ctx, testOutboxMessage())
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The send in progress finished; the next entry was not claimed
+	if entry, _ := d.Status(ctx, first.ID); entry.Status != StatusSent {
+		t.Errorf("Expected the first entry to be sent, got %s", entry.Status)
+	}
+	if entry, _ := d.Status(ctx, second.ID); entry.Status != StatusPending {
+		t.Errorf("Expected the second entry to be pending, got %s", entry.Status)
+	}
+
+	// A shutdown that runs out of time reports it
+	server.Script(postaltest.EndpointSendMessage).Next(1, postaltest.Delay(200*time.Millisecond))
+	if err := d.Start(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	waitForStatus(t, d, second.ID, StatusSending)
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// The dispatcher can't restart until the send finishes, so the entry
+	// is not sent twice
+	if err := d.Start(ctx); !errors.Is(err, ErrStopping) {
+		t.Errorf("Expected ErrStopping, got %v", err)
+	}
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := d.Start(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	waitForStatus(t, d, second.ID, StatusSent)
+	if calls := server.Calls(postaltest.EndpointSendMessage); calls != 2 {
+		t.Errorf("Expected 2 sends, got %d", calls)
+	}
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d := NewDispatcher(nil, NewMemoryStore(), WithBackoff(time.Second, 10*time.Second))
+	tests := map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 50: 10 * time.Second}
+	for attempts, want := range tests {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("Expected backoff after %d attempts to be %v, got %v", attempts, want, got)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// This file contains FileStore, a Store that keeps each entry in a JSON
+// file in a directory.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// fileExt is the extension of entry files.
+const fileExt = ".json"
+
+// FileStore is a Store that keeps each entry in its own JSON file in a
+// directory, and an index of them in memory. Every change is written to a
+// temporary file, synced, and renamed into place, and the directory is
+// synced after it, so an entry survives a crash in either its old or its
+// new state. Files are written without holding the store's lock, so a slow
+// disk only delays changes to the same entry.
+//
+// The directory must be used by a single process at a time.
+type FileStore struct {
+	dir   string
+	index *MemoryStore
+}
+
+// OpenFileStore opens the store in dir, creating the directory if needed,
+// and loads the entries it contains.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating outbox directory: %w", err)
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*"+fileExt))
+	if err != nil {
+		return nil, fmt.Errorf("error listing outbox directory: %w", err)
+	}
+
+	index := NewMemoryStore()
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("error reading outbox entry: %w", err)
+		}
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("error decoding outbox entry %s: %w", filepath.Base(name), err)
+		}
+		index.entries[entry.ID] = &entry
+	}
+
+	s := &FileStore{dir: dir, index: index}
+	index.persist = s.persist
+	return s, nil
+}
+
+// Dir returns the directory of the store.
+func (s *FileStore) Dir() string {
+	return s.dir
+}
+
+// Add implements Store.
+func (s *FileStore) Add(ctx context.Context, entry *Entry) error {
+	if err := validID(entry.ID); err != nil {
+		return err
+	}
+	return s.index.Add(ctx, entry)
+}
+
+// Claim implements Store.
+func (s *FileStore) Claim(ctx context.Context, now time.Time, limit int) ([]*Entry, error) {
+	return s.index.Claim(ctx, now, limit)
+}
+
+// Update implements Store.
+func (s *FileStore) Update(ctx context.Context, entry *Entry) error {
+	return s.index.Update(ctx, entry)
+}
+
+// Get implements Store.
+func (s *FileStore) Get(ctx context.Context, id string) (*Entry, error) {
+	return s.index.Get(ctx, id)
+}
+
+// List implements Store.
+func (s *FileStore) List(ctx context.Context, status Status) ([]*Entry, error) {
+	return s.index.List(ctx, status)
+}
+
+// Delete implements Store.
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	return s.index.Delete(ctx, id)
+}
+
+// persist writes entry to its file, or removes the file of deletedID.
+func (s *FileStore) persist(entry *Entry, deletedID string) error {
+	if entry == nil {
+		if err := os.Remove(s.path(deletedID)); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("error removing outbox entry: %w", err)
+		}
+		if err := syncDir(s.dir); err != nil {
+			return fmt.Errorf("error removing outbox entry: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding outbox entry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("error writing outbox entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing outbox entry: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing outbox entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing outbox entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(entry.ID)); err != nil {
+		return fmt.Errorf("error writing outbox entry: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return fmt.Errorf("error writing outbox entry: %w", err)
+	}
+	return nil
+}
+
+// syncDir syncs the directory dir, so that files renamed into or removed
+// from it stay that way after a crash. Windows cannot sync directories, and
+// does not need to.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// path returns the file name of the entry with the given ID.
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+fileExt)
+}
+
+// validID checks that id can be used as a file name.
+func validID(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("outbox: invalid entry ID %q", id)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+func TestFileStore(t *testing.T) {
+	store, err := OpenFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	testStore(t, store)
+}
+
+func TestFileStorePersists(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	entry := &Entry{
+		ID:            "entry-1",
+		Raw:           &models.SendRawRequest{MailFrom: "a@example.com", RcptTo: []string{"b@example.org"}, Data: "SGk="},
+		Status:        StatusPending,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+	if err := store.Add(ctx, entry); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := store.Claim(ctx, now, 1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := store.Add(ctx, &Entry{ID: "entry-2", Status: StatusPending}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := store.Delete(ctx, "entry-2"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Reopen the directory
+	reopened, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	entries, err := reopened.List(ctx, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+
+	got := entries[0]
+	if got.ID != "entry-1" || got.Status != StatusSending || !got.CreatedAt.Equal(now) {
+		t.Errorf("Expected the claimed entry to be restored, got %+v", got)
+	}
+
+	if got.Raw == nil || got.Raw.Data != "SGk=" || got.Raw.RcptTo[0] != "b@example.org" {
+		t.Errorf("Expected the raw message to be restored, got %+v", got.Raw)
+	}
+
+	// No temporary files are left behind
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 || files[0].Name() != "entry-1.json" {
+		t.Errorf("Expected only entry-1.json, got %v", files)
+	}
+}
+
+func TestFileStoreInvalidID(t *testing.T) {
+	store, err := OpenFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, id := range []string{"", "../escape", ".hidden", `a\b`} {
+		if err := store.Add(context.Background(), &Entry{ID: id}); err == nil {
+			t.Errorf("Expected an error for ID %q, got nil", id)
+		}
+	}
+}
+
+func TestOpenFileStoreCorruptEntry(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileStore(dir); err == nil {
+		t.Error("Expected an error for a corrupt entry, got nil")
+	}
+}
+
+func TestSyncDir(t *testing.T) {
+	if err := syncDir(t.TempDir()); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := syncDir(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing directory, got nil")
+	}
+}
@@ -0,0 +1,192 @@
+// This file contains MemoryStore, a Store that keeps entries in memory.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps entries in memory. Entries are lost
+// when the process exits, so it is meant for tests and for applications
+// that only need the outbox's retries.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+
+	// writing holds a channel for each entry whose change is being
+	// persisted, closed when it is done. Only one change to an entry is
+	// persisted at a time.
+	writing map[string]chan struct{}
+
+	// persist, if set, is called with every changed entry, or with the ID
+	// of a deleted one, before the change is applied. The change is
+	// abandoned if it returns an error. It is called without holding mu,
+	// so slow writes don't block other entries. It is used by FileStore.
+	persist func(entry *Entry, deletedID string) error
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry), writing: make(map[string]chan struct{})}
+}
+
+// Add implements Store.
+func (s *MemoryStore) Add(ctx context.Context, entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lockEntryLocked(entry.ID)
+	defer s.unlockEntryLocked(entry.ID)
+	if _, ok := s.entries[entry.ID]; ok {
+		return fmt.Errorf("outbox: entry %s already exists", entry.ID)
+	}
+	return s.saveLocked(entry.clone())
+}
+
+// Claim implements Store.
+func (s *MemoryStore) Claim(ctx context.Context, now time.Time, limit int) ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Entries with a change being persisted are left for a later claim
+	var due []*Entry
+	for _, e := range s.entries {
+		if _, busy := s.writing[e.ID]; busy {
+			continue
+		}
+		if e.Status == StatusPending && !e.NextAttemptAt.After(now) {
+			due = append(due, e)
+		}
+	}
+	slices.SortFunc(due, func(a, b *Entry) int {
+		if c := a.NextAttemptAt.Compare(b.NextAttemptAt); c != 0 {
+			return c
+		}
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, e := range due {
+		s.lockEntryLocked(e.ID)
+	}
+
+	claimed := make([]*Entry, 0, len(due))
+	for i, e := range due {
+		c := e.clone()
+		c.Status = StatusSending
+		c.UpdatedAt = now
+		err := s.saveLocked(c)
+		s.unlockEntryLocked(e.ID)
+		if err != nil {
+			for _, rest := range due[i+1:] {
+				s.unlockEntryLocked(rest.ID)
+			}
+			return claimed, err
+		}
+		claimed = append(claimed, c.clone())
+	}
+	return claimed, nil
+}
+
+// Update implements Store.
+func (s *MemoryStore) Update(ctx context.Context, entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lockEntryLocked(entry.ID)
+	defer s.unlockEntryLocked(entry.ID)
+	if _, ok := s.entries[entry.ID]; !ok {
+		return ErrNotFound
+	}
+	return s.saveLocked(entry.clone())
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return e.clone(), nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(ctx context.Context, status Status) ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []*Entry
+	for _, e := range s.entries {
+		if status == "" || e.Status == status {
+			entries = append(entries, e.clone())
+		}
+	}
+	slices.SortFunc(entries, func(a, b *Entry) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return entries, nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lockEntryLocked(id)
+	defer s.unlockEntryLocked(id)
+	if _, ok := s.entries[id]; !ok {
+		return ErrNotFound
+	}
+	if err := s.persistLocked(nil, id); err != nil {
+		return err
+	}
+	delete(s.entries, id)
+	return nil
+}
+
+// saveLocked persists entry, if needed, and stores it. s.mu must be held,
+// and the entry locked with lockEntryLocked.
+func (s *MemoryStore) saveLocked(entry *Entry) error {
+	if err := s.persistLocked(entry, ""); err != nil {
+		return err
+	}
+	s.entries[entry.ID] = entry
+	return nil
+}
+
+// persistLocked calls s.persist, if set, releasing s.mu for the duration
+// of the call. s.mu must be held, and the entry locked with
+// lockEntryLocked.
+func (s *MemoryStore) persistLocked(entry *Entry, deletedID string) error {
+	if s.persist == nil {
+		return nil
+	}
+	s.mu.Unlock()
+	defer s.mu.Lock()
+	return s.persist(entry, deletedID)
+}
+
+// lockEntryLocked waits until no change to the entry with the given ID is
+// being persisted, then marks one as in progress. s.mu must be held; it is
+// released while waiting.
+func (s *MemoryStore) lockEntryLocked(id string) {
+	for {
+		done, ok := s.writing[id]
+		if !ok {
+			break
+		}
+		s.mu.Unlock()
+		<-done
+		s.mu.Lock()
+	}
+	s.writing[id] = make(chan struct{})
+}
+
+// unlockEntryLocked marks the change to the entry with the given ID as
+// persisted. s.mu must be held.
+func (s *MemoryStore) unlockEntryLocked(id string) {
+	close(s.writing[id])
+	delete(s.writing, id)
+}
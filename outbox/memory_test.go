@@ -0,0 +1,117 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMemoryStoreConcurrentClaims(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		id, _ := newID()
+		if err := store.Add(ctx, &Entry{ID: id, Status: StatusPending, NextAttemptAt: now}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	// Every entry is claimed exactly once
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				entries, err := store.Claim(ctx, now, 3)
+				if err != nil || len(entries) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, e := range entries {
+					seen[e.ID]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 100 {
+		t.Errorf("Expected 100 entries to be claimed, got %d", len(seen))
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("Expected entry %s to be claimed once, got %d", id, n)
+		}
+	}
+}
+
+func TestMemoryStorePersistsWithoutLock(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	for _, id := range []string{"slow", "fast"} {
+		if err := store.Add(ctx, &Entry{ID: id, Status: StatusPending, NextAttemptAt: now}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	// Block the write of one entry
+	started, release := make(chan struct{}), make(chan struct{})
+	var order []Status
+	var mu sync.Mutex
+	store.persist = func(entry *Entry, deletedID string) error {
+		if entry != nil && entry.ID == "slow" {
+			mu.Lock()
+			first := len(order) == 0
+			order = append(order, entry.Status)
+			mu.Unlock()
+			if first {
+				close(started)
+				<-release
+			}
+		}
+		return nil
+	}
+	done := make(chan error, 2)
+	go func() {
+		done <- store.Update(ctx, &Entry{ID: "slow", Status: StatusSent, NextAttemptAt: now})
+	}()
+	<-started
+
+	// Other entries can be read, claimed, and changed meanwhile
+	if _, err := store.Get(ctx, "slow"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	claimed, err := store.Claim(ctx, now, 10)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != "fast" {
+		t.Errorf("Expected only the other entry to be claimed, got %v, %v", claimed, err)
+	}
+
+	// Changes to the same entry wait for the write in progress
+	go func() {
+		done <- store.Update(ctx, &Entry{ID: "slow", Status: StatusDead, NextAttemptAt: now})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}
+	if len(order) != 2 || order[0] != StatusSent || order[1] != StatusDead {
+		t.Errorf("Expected the writes in order, got %v", order)
+	}
+	if entry, _ := store.Get(ctx, "slow"); entry.Status != StatusDead {
+		t.Errorf("Expected the last update to be kept, got %s", entry.Status)
+	}
+}
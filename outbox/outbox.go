@@ -0,0 +1,145 @@
+// Package outbox persists outgoing messages and delivers them to Postal in
+// the background, so that a message accepted by the application is not
+// lost when Postal is unreachable.
+//
+// Messages are first saved to a Store and then sent by a Dispatcher's
+// worker pool through Client.SendMessage or Client.SendRaw. Failed sends
+// are retried with exponential backoff; messages that Postal rejects as
+// invalid, or that keep failing, are moved to the dead-letter status.
+//
+// Basic usage:
+//
+//	store, err := outbox.OpenFileStore("/var/lib/myapp/outbox")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	dispatcher := outbox.NewDispatcher(client, store, outbox.WithWorkers(4))
+//	if err := dispatcher.Start(ctx); err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer dispatcher.Shutdown(context.Background())
+//
+//	entry, err := dispatcher.Enqueue(ctx, &models.SendMessageRequest{...})
+//	// Later: dispatcher.Status(ctx, entry.ID)
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// Status is the delivery state of an outbox entry.
+type Status string
+
+// Entry statuses.
+const (
+	// StatusPending entries are waiting to be sent, either for the first
+	// time or for a retry at NextAttemptAt.
+	StatusPending Status = "pending"
+
+	// StatusSending entries have been claimed by a worker and are being
+	// sent.
+	StatusSending Status = "sending"
+
+	// StatusSent entries were accepted by Postal. Their MessageID is set.
+	StatusSent Status = "sent"
+
+	// StatusDead entries were rejected by Postal or ran out of attempts.
+	// They are not retried unless requeued.
+	StatusDead Status = "dead"
+)
+
+// ErrNotFound is returned by a Store when no entry has the requested ID.
+var ErrNotFound = errors.New("outbox: entry not found")
+
+// Entry is a message in the outbox. Exactly one of Message and Raw is set.
+type Entry struct {
+	// ID identifies the entry in its store.
+	ID string `json:"id"`
+
+	// Message is the message to send with Client.SendMessage.
+	Message *models.SendMessageRequest `json:"message,omitempty"`
+
+	// Raw is the message to send with Client.SendRaw.
+	Raw *models.SendRawRequest `json:"raw,omitempty"`
+
+	// Status is the delivery state of the entry.
+	Status Status `json:"status"`
+
+	// Attempts is the number of sends attempted so far.
+	Attempts int `json:"attempts"`
+
+	// LastError describes the error of the last failed attempt.
+	LastError string `json:"last_error,omitempty"`
+
+	// MessageID is the ID Postal assigned to the message once sent.
+	MessageID int `json:"message_id,omitempty"`
+
+	// Token is the token Postal assigned to the message once sent.
+	Token string `json:"token,omitempty"`
+
+	// CreatedAt is when the entry was added to the outbox.
+	CreatedAt time.Time `json:"created_at"`
+
+	// UpdatedAt is when the entry last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// NextAttemptAt is the earliest time a pending entry may be sent.
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+// clone returns a copy of e that shares no mutable state with it, except
+// for the slices and maps of its message, which the outbox never changes.
+func (e *Entry) clone() *Entry {
+	c := *e
+	if e.Message != nil {
+		m := *e.Message
+		c.Message = &m
+	}
+	if e.Raw != nil {
+		r := *e.Raw
+		c.Raw = &r
+	}
+	return &c
+}
+
+// Store persists outbox entries. Implementations must be safe for
+// concurrent use, and the entries they return must not share state with
+// the stored ones.
+type Store interface {
+	// Add saves a new entry. Its ID must not be in use.
+	Add(ctx context.Context, entry *Entry) error
+
+	// Claim atomically marks up to limit pending entries whose
+	// NextAttemptAt is not after now as StatusSending, and returns them,
+	// earliest NextAttemptAt first.
+	Claim(ctx context.Context, now time.Time, limit int) ([]*Entry, error)
+
+	// Update replaces a stored entry with entry, matched by ID. It returns
+	// ErrNotFound if there is none.
+	Update(ctx context.Context, entry *Entry) error
+
+	// Get returns the entry with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Entry, error)
+
+	// List returns the entries with the given status, or all entries if
+	// status is empty, oldest first.
+	List(ctx context.Context, status Status) ([]*Entry, error)
+
+	// Delete removes the entry with the given ID, or returns ErrNotFound.
+	Delete(ctx context.Context, id string) error
+}
+
+// newID returns a random entry ID.
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
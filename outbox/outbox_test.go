@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// testStore checks that store behaves as described by the Store interface.
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Add three entries, the last one not due until later
+	for i, id := range []string{"a", "b", "c"} {
+		entry := &Entry{
+			ID:            id,
+			Message:       &models.SendMessageRequest{From: "a@example.com", To: []string{"b@example.org"}, Subject: id},
+			Status:        StatusPending,
+			CreatedAt:     base.Add(time.Duration(i) * time.Second),
+			NextAttemptAt: base.Add(time.Duration(i) * time.Second),
+		}
+		if id == "c" {
+			entry.NextAttemptAt = base.Add(time.Hour)
+		}
+		if err := store.Add(ctx, entry); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	if err := store.Add(ctx, &Entry{ID: "a", Status: StatusPending}); err == nil {
+		t.Error("Expected an error adding a duplicate ID, got nil")
+	}
+
+	// Claim returns due entries in order and marks them as sending
+	claimed, err := store.Claim(ctx, base.Add(time.Minute), 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(claimed) != 2 || claimed[0].ID != "a" || claimed[1].ID != "b" {
+		t.Fatalf("Expected entries a and b to be claimed, got %v", claimed)
+	}
+	if claimed[0].Status != StatusSending {
+		t.Errorf("Expected claimed entries to be sending, got %s", claimed[0].Status)
+	}
+
+	again, err := store.Claim(ctx, base.Add(time.Minute), 10)
+	if err != nil || len(again) != 0 {
+		t.Errorf("Expected nothing left to claim, got %v %v", again, err)
+	}
+
+	// The limit is respected
+	later, err := store.Claim(ctx, base.Add(2*time.Hour), 0)
+	if err != nil || len(later) != 0 {
+		t.Errorf("Expected no entries with a zero limit, got %v %v", later, err)
+	}
+
+	// Updates are saved, and returned entries are copies
+	claimed[0].Status = StatusSent
+	claimed[0].MessageID = 42
+	if err := store.Update(ctx, claimed[0]); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	claimed[0].MessageID = 43
+
+	got, err := store.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got.Status != StatusSent || got.MessageID != 42 || got.Message.Subject != "a" {
+		t.Errorf("Expected entry a to be sent as message 42, got %+v", got)
+	}
+
+	// List filters by status
+	sending, err := store.List(ctx, StatusSending)
+	if err != nil || len(sending) != 1 || sending[0].ID != "b" {
+		t.Errorf("Expected entry b to be sending, got %v %v", sending, err)
+	}
+
+	all, err := store.List(ctx, "")
+	if err != nil || len(all) != 3 || all[0].ID != "a" || all[2].ID != "c" {
+		t.Errorf("Expected all entries oldest first, got %v %v", all, err)
+	}
+
+	// Delete removes entries
+	if err := store.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := store.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if err := store.Delete(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if err := store.Update(ctx, &Entry{ID: "missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestNewID(t *testing.T) {
+	a, err := newID()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	b, _ := newID()
+	if len(a) != 32 || a == b {
+		t.Errorf("Expected distinct 32-character IDs, got %q and %q", a, b)
+	}
+}
@@ -0,0 +1,170 @@
+// This file contains the fake server's support for the _expansions
+// parameter of /messages/message, which builds the optional sections of a
+// message from what the server stored when it was sent.
+package postaltest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/mail"
+	"strings"
+
+	"github.com/Suhaibinator/postalclient-go/mime"
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// allExpansions lists every expansion, in the order Postal documents them.
+var allExpansions = []string{
+	"status", "details", "inspection", "plain_body", "html_body",
+	"attachments", "headers", "raw_message",
+}
+
+// parseExpansions decodes the _expansions parameter, which is either a
+// list of expansion names or true for all of them.
+func parseExpansions(raw json.RawMessage) (map[string]bool, bool) {
+	expansions := make(map[string]bool)
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) || bytes.Equal(raw, []byte("false")) {
+		return expansions, true
+	}
+
+	if bytes.Equal(raw, []byte("true")) {
+		for _, name := range allExpansions {
+			expansions[name] = true
+		}
+		return expansions, true
+	}
+
+	var names []string
+	if err := json.Unmarshal(raw, &names); err != nil {
+		return nil, false
+	}
+	for _, name := range names {
+		expansions[name] = true
+	}
+	return expansions, true
+}
+
+// expandMessage returns m as the messages API describes it, with the
+// requested expansions.
+func expandMessage(m *Message, expansions map[string]bool) models.Message {
+	out := models.Message{ID: m.ID, Token: m.Token}
+	raw := m.rawMessage()
+	parsed, _ := mail.ReadMessage(bytes.NewReader(raw))
+
+	if expansions["status"] {
+		status := &models.MessageStatus{Status: models.StatusPending}
+		if n := len(m.Deliveries); n > 0 {
+			last := m.Deliveries[n-1]
+			status.Status = last.Status
+			status.LastDeliveryAttempt = models.UnixTime{Time: last.Timestamp}
+			status.Held = last.Status == models.StatusHeld
+		}
+		out.Status = status
+	}
+
+	if expansions["details"] {
+		details := &models.MessageDetails{
+			MessageID: m.Token + "@postaltest",
+			Timestamp: models.UnixTime{Time: m.ReceivedAt},
+			Direction: "outgoing",
+			Size:      len(raw),
+		}
+		details.RcptTo = m.Rcpt
+		if m.Raw != nil {
+			details.MailFrom = m.Raw.MailFrom
+			details.Bounce = m.Raw.Bounce
+			if parsed != nil {
+				details.Subject = parsed.Header.Get("Subject")
+				details.MessageID = strings.Trim(parsed.Header.Get("Message-ID"), "<>")
+			}
+		} else {
+			details.MailFrom = m.Request.From
+			details.Subject = m.Request.Subject
+			details.Tag = m.Request.Tag
+			details.Bounce = m.Request.Bounce
+		}
+		out.Details = details
+	}
+
+	if expansions["inspection"] {
+		out.Inspection = &models.MessageInspection{Inspected: true}
+	}
+
+	if m.Request != nil {
+		if expansions["plain_body"] {
+			out.PlainBody = m.Request.PlainBody
+		}
+		if expansions["html_body"] {
+			out.HTMLBody = m.Request.HTMLBody
+		}
+		if expansions["attachments"] {
+			out.Attachments = []models.Attachment{}
+			for _, a := range m.Request.Attachments {
+				data, _ := base64.StdEncoding.DecodeString(a.Data)
+				out.Attachments = append(out.Attachments, models.Attachment{
+					Name:        a.Name,
+					ContentType: a.ContentType,
+					Data:        a.Data,
+					Size:        len(data),
+				})
+			}
+		}
+	}
+
+	if expansions["headers"] {
+		out.Headers = models.MessageHeaders{}
+		if parsed != nil {
+			for name, values := range parsed.Header {
+				out.Headers[strings.ToLower(name)] = values
+			}
+		}
+	}
+
+	if expansions["raw_message"] {
+		out.RawMessage = base64.StdEncoding.EncodeToString(raw)
+	}
+
+	return out
+}
+
+// rawMessage returns the message in RFC 2822 format: the data it was sent
+// with for raw messages, or a message built from the request otherwise.
+func (m *Message) rawMessage() []byte {
+	if m.Raw != nil {
+		data, _ := base64.StdEncoding.DecodeString(m.Raw.Data)
+		return data
+	}
+
+	req := m.Request
+	b := mime.NewBuilder().
+		From(req.From).
+		To(req.To...).
+		CC(req.CC...).
+		BCC(req.BCC...).
+		Subject(req.Subject).
+		Text(req.PlainBody).
+		HTML(req.HTMLBody).
+		MessageID(m.Token + "@postaltest").
+		Date(m.ReceivedAt)
+	if req.Sender != "" {
+		b.Sender(req.Sender)
+	}
+	if req.ReplyTo != "" {
+		b.ReplyTo(req.ReplyTo)
+	}
+	for name, value := range req.Headers {
+		b.Header(name, value)
+	}
+	for _, a := range req.Attachments {
+		data, _ := base64.StdEncoding.DecodeString(a.Data)
+		b.Attach(a.Name, a.ContentType, data)
+	}
+
+	data, err := b.Build()
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,121 @@
+package postaltest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+func TestGetMessageExpansions(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	resp, err := client.SendMessage(&models.SendMessageRequest{
+		To:        []string{"recipient@example.com"},
+		From:      "sender@example.com",
+		Subject:   "Hello",
+		Tag:       "welcome",
+		PlainBody: "Hi there",
+		HTMLBody:  "<p>Hi there</p>",
+		Headers:   map[string]string{"X-Campaign": "spring"},
+		Attachments: []models.Attachment{
+			{Name: "a.txt", ContentType: "text/plain", Data: "aGk="},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Without expansions only the ID and token are returned
+	message, err := client.GetMessage(resp.MessageID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if message.Status != nil || message.Details != nil || message.PlainBody != "" {
+		t.Errorf("Expected no expansions, got %+v", message)
+	}
+
+	// Selected expansions
+	message, err = client.GetMessage(resp.MessageID, postalclient.WithExpansions(postalclient.ExpansionStatus, postalclient.ExpansionDetails))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if message.Status == nil || message.Status.Status != "Pending" {
+		t.Errorf("Expected a pending status, got %+v", message.Status)
+	}
+
+	if message.Details == nil || message.Details.Subject != "Hello" || message.Details.Tag != "welcome" || message.Details.RcptTo != "recipient@example.com" {
+		t.Errorf("Expected details to match the request, got %+v", message.Details)
+	}
+
+	if message.PlainBody != "" {
+		t.Errorf("Expected no plain body, got %q", message.PlainBody)
+	}
+
+	// Deliveries are reflected in the status
+	server.SimulateDelivery(resp.MessageID, models.Delivery{Status: "Held"})
+
+	// All expansions
+	message, err = client.GetMessage(resp.MessageID, postalclient.WithAllExpansions())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if message.Status == nil || !message.Status.Held || message.Status.LastDeliveryAttempt.IsZero() {
+		t.Errorf("Expected a held status, got %+v", message.Status)
+	}
+
+	if message.PlainBody != "Hi there" || message.HTMLBody != "<p>Hi there</p>" {
+		t.Errorf("Expected the bodies, got %q and %q", message.PlainBody, message.HTMLBody)
+	}
+
+	if len(message.Attachments) != 1 || message.Attachments[0].Size != 2 {
+		t.Errorf("Expected one 2 byte attachment, got %+v", message.Attachments)
+	}
+
+	if got := message.Headers.Get("X-Campaign"); got != "spring" {
+		t.Errorf("Expected X-Campaign header to be spring, got %q", got)
+	}
+
+	raw, err := message.DecodeRawMessage()
+	if err != nil || !strings.Contains(string(raw), "Subject: Hello") {
+		t.Errorf("Expected a raw message with the subject, got %q (%v)", raw, err)
+	}
+
+	if message.Details.Size != len(raw) {
+		t.Errorf("Expected size to be %d, got %d", len(raw), message.Details.Size)
+	}
+}
+
+func TestGetMessageExpansionsRaw(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	resp, err := client.SendRaw(&models.SendRawRequest{
+		MailFrom: "sender@example.com",
+		RcptTo:   []string{"recipient@example.com"},
+		Data:     "U3ViamVjdDogSGkNCk1lc3NhZ2UtSUQ6IDxyYXdAZXhhbXBsZS5jb20+DQoNCkhp",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	message, err := client.GetMessage(resp.MessageID, postalclient.WithExpansions(postalclient.ExpansionDetails, postalclient.ExpansionHeaders))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if message.Details.Subject != "Hi" || message.Details.MessageID != "raw@example.com" || message.Details.MailFrom != "sender@example.com" {
+		t.Errorf("Expected details from the raw message, got %+v", message.Details)
+	}
+
+	if got := message.Headers.Get("subject"); got != "Hi" {
+		t.Errorf("Expected subject header to be Hi, got %q", got)
+	}
+}
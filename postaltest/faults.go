@@ -0,0 +1,305 @@
+// This file contains fault injection for the fake server. Tests script the
+// failures an endpoint should produce, such as gateway errors, latency,
+// dropped connections, malformed JSON, or error responses with HTTP 200,
+// and the server plays them back in order for the following requests.
+package postaltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Endpoints served by the fake server, for use with Server.Script and
+// Server.Calls.
+const (
+	EndpointSendMessage       = "/send/message"
+	EndpointSendRaw           = "/send/raw"
+	EndpointMessage           = "/messages/message"
+	EndpointMessageDeliveries = "/messages/deliveries"
+
+	// AnyEndpoint matches every endpoint that has no script of its own.
+	AnyEndpoint = "*"
+)
+
+// Fault describes how the server misbehaves for a single request.
+// Latency can be combined with any other fault. A Fault with only Latency
+// set delays the request and then handles it normally.
+type Fault struct {
+	// Latency delays the response. The delay ends early if the client
+	// gives up on the request.
+	Latency time.Duration
+
+	// StatusCode, if non-zero, makes the server respond with this HTTP
+	// status and an error envelope instead of handling the request.
+	StatusCode int
+
+	// RetryAfter, if non-zero, is sent in a Retry-After header, rounded
+	// up to whole seconds.
+	RetryAfter time.Duration
+
+	// ErrorCode and ErrorMessage, if ErrorCode is set, are used in the
+	// error envelope. Without StatusCode, the error is sent with HTTP 200
+	// and status "error", the way Postal reports most errors.
+	ErrorCode    string
+	ErrorMessage string
+
+	// MalformedJSON makes the server respond with HTTP 200 and a body that
+	// is not valid JSON.
+	MalformedJSON bool
+
+	// DropConnection makes the server send the response headers and part
+	// of the body, then close the connection.
+	DropConnection bool
+}
+
+// Status returns a Fault that responds with the given HTTP status code.
+func Status(code int) Fault {
+	return Fault{StatusCode: code}
+}
+
+// Unavailable returns a Fault that responds with 503 Service Unavailable
+// and asks the client to retry after the given delay, if it is non-zero.
+func Unavailable(retryAfter time.Duration) Fault {
+	return Fault{StatusCode: http.StatusServiceUnavailable, RetryAfter: retryAfter}
+}
+
+// APIError returns a Fault that responds with HTTP 200 and an error
+// envelope with the given Postal error code and message.
+func APIError(code, message string) Fault {
+	return Fault{ErrorCode: code, ErrorMessage: message}
+}
+
+// Delay returns a Fault that delays the request and then handles it
+// normally.
+func Delay(d time.Duration) Fault {
+	return Fault{Latency: d}
+}
+
+// Disconnect returns a Fault that closes the connection partway through
+// the response body.
+func Disconnect() Fault {
+	return Fault{DropConnection: true}
+}
+
+// Malformed returns a Fault that responds with HTTP 200 and invalid JSON.
+func Malformed() Fault {
+	return Fault{MalformedJSON: true}
+}
+
+// apply writes the fault's response to w. It reports whether the request
+// was fully handled; if not, the server should handle it normally.
+func (f Fault) apply(w http.ResponseWriter, r *http.Request) bool {
+	if f.Latency > 0 {
+		// Read the body first: the server only notices a client hanging up
+		// once the body has been consumed
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		timer := time.NewTimer(f.Latency)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return true
+		}
+	}
+
+	switch {
+	case f.DropConnection:
+		dropConnection(w)
+		return true
+	case f.MalformedJSON:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"success","time":0.01,"flags":{},"data":{`))
+		return true
+	case f.StatusCode != 0 || f.ErrorCode != "":
+		f.writeError(w)
+		return true
+	}
+	return false
+}
+
+// writeError writes the fault's error envelope.
+func (f Fault) writeError(w http.ResponseWriter) {
+	statusCode := f.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	message := f.ErrorMessage
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	data := map[string]string{"message": message}
+	if f.ErrorCode != "" {
+		data["code"] = f.ErrorCode
+	}
+
+	if f.RetryAfter > 0 {
+		seconds := int((f.RetryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(envelope{
+		Status: "error",
+		Flags:  json.RawMessage(`{}`),
+		Data:   data,
+	})
+}
+
+// dropConnection writes a response that promises more body than it
+// delivers and closes the underlying connection.
+func dropConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic("postaltest: response writer does not support hijacking")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		panic(fmt.Sprintf("postaltest: error hijacking connection: %v", err))
+	}
+	defer conn.Close()
+
+	partial := `{"status":"success","time":0.01,"flags":{},"data":{"message_id":`
+	_, _ = fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(partial)+100, partial)
+	_ = buf.Flush()
+}
+
+// step is one entry of a script: a fault applied to the next count
+// requests, or to every request if count is negative.
+type step struct {
+	fault Fault
+	count int
+}
+
+// Script is a sequence of faults played back, in order, for the requests
+// to one endpoint. Requests beyond the end of the script are handled
+// normally. Build a script with Server.Script:
+//
+//	server.Script(postaltest.EndpointSendMessage).
+//	    Next(2, postaltest.Unavailable(0)).
+//	    Next(1, postaltest.Disconnect()).
+//	    Next(1, postaltest.APIError("ServerBusy", "Try again later"))
+//
+// A Script's methods are safe to call while the server is handling
+// requests.
+type Script struct {
+	server *Server
+	steps  []step
+}
+
+// Script returns the script for endpoint (one of the Endpoint constants,
+// or AnyEndpoint), creating it if needed. Steps added to it apply to the
+// next requests to that endpoint.
+func (s *Server) Script(endpoint string) *Script {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sc, ok := s.scripts[endpoint]; ok {
+		return sc
+	}
+	sc := &Script{server: s}
+	s.scripts[endpoint] = sc
+	return sc
+}
+
+// ClearScripts removes every script, so all requests are handled normally.
+func (s *Server) ClearScripts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts = make(map[string]*Script)
+}
+
+// Calls returns the number of requests the server has received for
+// endpoint, including requests that were answered with a fault.
+func (s *Server) Calls(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[endpoint]
+}
+
+// Next appends a step that applies fault to the next n requests.
+func (sc *Script) Next(n int, fault Fault) *Script {
+	if n <= 0 {
+		return sc
+	}
+	return sc.add(step{fault: fault, count: n})
+}
+
+// Always appends a step that applies fault to every remaining request.
+// Steps added after it are never reached.
+func (sc *Script) Always(fault Fault) *Script {
+	return sc.add(step{fault: fault, count: -1})
+}
+
+// Remaining returns the number of requests still to be answered by the
+// script, or -1 if it ends with an Always step.
+func (sc *Script) Remaining() int {
+	sc.server.mu.Lock()
+	defer sc.server.mu.Unlock()
+	total := 0
+	for _, st := range sc.steps {
+		if st.count < 0 {
+			return -1
+		}
+		total += st.count
+	}
+	return total
+}
+
+// Done reports whether every step of the script has been played.
+func (sc *Script) Done() bool {
+	return sc.Remaining() == 0
+}
+
+// add appends a step under the server's lock.
+func (sc *Script) add(st step) *Script {
+	sc.server.mu.Lock()
+	defer sc.server.mu.Unlock()
+	sc.steps = append(sc.steps, st)
+	return sc
+}
+
+// nextFaultLocked consumes and returns the next fault for endpoint.
+// s.mu must be held.
+func (s *Server) nextFaultLocked(endpoint string) (Fault, bool) {
+	sc, ok := s.scripts[endpoint]
+	if !ok || len(sc.steps) == 0 {
+		sc, ok = s.scripts[AnyEndpoint]
+	}
+	if !ok || len(sc.steps) == 0 {
+		return Fault{}, false
+	}
+
+	st := &sc.steps[0]
+	fault := st.fault
+	if st.count > 0 {
+		st.count--
+		if st.count == 0 {
+			sc.steps = sc.steps[1:]
+		}
+	}
+	return fault, true
+}
+
+// serveHTTP counts the request, applies any scripted fault, and otherwise
+// passes the request to the endpoint handlers.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(r.URL.Path, APIPath)
+
+	s.mu.Lock()
+	s.calls[endpoint]++
+	fault, ok := s.nextFaultLocked(endpoint)
+	s.mu.Unlock()
+
+	if ok && fault.apply(w, r) {
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
@@ -0,0 +1,293 @@
+package postaltest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// testMessage returns a valid message for fault tests.
+func testMessage() *models.SendMessageRequest {
+	return &models.SendMessageRequest{
+		To:        []string{"recipient@example.com"},
+		From:      "sender@example.com",
+		Subject:   "Hello",
+		PlainBody: "Hi there",
+	}
+}
+
+// fastRetries returns a retry policy with tiny delays for tests.
+func fastRetries(maxAttempts int) *postalclient.RetryPolicy {
+	return &postalclient.RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}
+}
+
+func TestScriptUnavailableThenSuccess(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	script := server.Script(EndpointSendMessage).Next(2, Unavailable(0))
+
+	client := server.Client()
+	var statuses []int
+	client.RetryPolicy = fastRetries(3)
+	client.RetryPolicy.OnAttempt = func(a postalclient.RetryAttempt) {
+		statuses = append(statuses, a.StatusCode)
+	}
+
+	resp, err := client.SendMessage(testMessage())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if resp.MessageID != 1 {
+		t.Errorf("Expected message ID to be 1, got %d", resp.MessageID)
+	}
+
+	if got := server.Calls(EndpointSendMessage); got != 3 {
+		t.Errorf("Expected 3 calls, got %d", got)
+	}
+
+	if len(statuses) != 3 || statuses[0] != http.StatusServiceUnavailable || statuses[1] != http.StatusServiceUnavailable || statuses[2] != http.StatusOK {
+		t.Errorf("Expected statuses [503 503 200], got %v", statuses)
+	}
+
+	if len(server.Messages()) != 1 {
+		t.Errorf("Expected 1 stored message, got %d", len(server.Messages()))
+	}
+
+	if !script.Done() {
+		t.Errorf("Expected script to be done, %d requests remaining", script.Remaining())
+	}
+}
+
+func TestScriptStatusWithoutRetries(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Script(EndpointSendMessage).Next(1, Status(http.StatusBadGateway))
+
+	_, err := server.Client().SendMessage(testMessage())
+	if err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+
+	var apiErr *postalclient.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *postalclient.Error, got %T", err)
+	}
+
+	if apiErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected status code to be 502, got %d", apiErr.StatusCode)
+	}
+
+	// The next request is handled normally
+	if _, err := server.Client().SendMessage(testMessage()); err != nil {
+		t.Errorf("Expected no error after the script ended, got %v", err)
+	}
+}
+
+func TestScriptRetryAfter(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Script(EndpointSendMessage).Next(1, Unavailable(1500*time.Millisecond))
+
+	// Check the header directly, since honouring it would slow the test
+	req, err := http.NewRequest(http.MethodPost, server.URL+EndpointSendMessage, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code to be 503, got %d", resp.StatusCode)
+	}
+
+	if got := resp.Header.Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After to be 2, got %q", got)
+	}
+}
+
+func TestScriptAPIError(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Script(EndpointSendMessage).Next(1, APIError(postalclient.CodeServerSuspended, "Server is suspended"))
+
+	_, err := server.Client().SendMessage(testMessage())
+	if !errors.Is(err, postalclient.ErrServerSuspended) {
+		t.Fatalf("Expected ErrServerSuspended, got %v", err)
+	}
+
+	var apiErr *postalclient.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code to be 200, got %d", apiErr.StatusCode)
+	}
+}
+
+func TestScriptMalformedJSON(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Script(EndpointSendMessage).Next(1, Malformed())
+
+	_, err := server.Client().SendMessage(testMessage())
+
+	var decodeErr *postalclient.DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("Expected *postalclient.DecodeError, got %T: %v", err, err)
+	}
+
+	if len(server.Messages()) != 0 {
+		t.Errorf("Expected no stored messages, got %d", len(server.Messages()))
+	}
+}
+
+func TestScriptDisconnect(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Script(EndpointSendMessage).Next(1, Disconnect())
+
+	_, err := server.Client().SendMessage(testMessage())
+
+	var transportErr *postalclient.TransportError
+	if !errors.As(err, &transportErr) {
+		t.Fatalf("Expected *postalclient.TransportError, got %T: %v", err, err)
+	}
+
+	if transportErr.StatusCode != http.StatusOK {
+		t.Errorf("Expected the error to happen while reading the body, got status code %d", transportErr.StatusCode)
+	}
+}
+
+func TestScriptDisconnectRetried(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	script := server.Script(EndpointSendMessage).Next(1, Disconnect())
+
+	client := server.Client()
+	client.RetryPolicy = fastRetries(2)
+
+	resp, err := client.SendMessage(testMessage())
+	if err != nil {
+		t.Fatalf("Expected the dropped connection to be retried, got %v", err)
+	}
+
+	if resp.MessageID != 1 {
+		t.Errorf("Expected message ID to be 1, got %d", resp.MessageID)
+	}
+
+	if got := server.Calls(EndpointSendMessage); got != 2 {
+		t.Errorf("Expected 2 calls, got %d", got)
+	}
+
+	if !script.Done() {
+		t.Errorf("Expected script to be done, %d requests remaining", script.Remaining())
+	}
+}
+
+func TestScriptDelay(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Script(EndpointSendMessage).Next(1, Delay(time.Second))
+
+	// A deadline shorter than the delay ends the request
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := server.Client().SendMessageContext(ctx, testMessage())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// A delay alone still handles the request
+	server.Script(EndpointSendMessage).Next(1, Delay(10*time.Millisecond))
+	start := time.Now()
+	if _, err := server.Client().SendMessage(testMessage()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("Expected the request to take at least 10ms, took %v", elapsed)
+	}
+
+	if len(server.Messages()) != 1 {
+		t.Errorf("Expected 1 stored message, got %d", len(server.Messages()))
+	}
+}
+
+func TestScriptPerEndpoint(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	resp, err := client.SendMessage(testMessage())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	server.Script(EndpointMessageDeliveries).Next(1, Status(http.StatusServiceUnavailable))
+
+	// Other endpoints are unaffected
+	if _, err := client.GetMessage(resp.MessageID); err != nil {
+		t.Errorf("Expected no error for /messages/message, got %v", err)
+	}
+
+	if _, err := client.GetMessageDeliveries(resp.MessageID); err == nil {
+		t.Error("Expected an error for /messages/deliveries, got nil")
+	}
+
+	if got := server.Calls(EndpointMessage); got != 1 {
+		t.Errorf("Expected 1 call to /messages/message, got %d", got)
+	}
+}
+
+func TestScriptAnyEndpointAndAlways(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	script := server.Script(AnyEndpoint).Always(Status(http.StatusInternalServerError))
+	if script.Remaining() != -1 {
+		t.Errorf("Expected Remaining to be -1, got %d", script.Remaining())
+	}
+
+	// An endpoint script takes precedence until it runs out
+	server.Script(EndpointSendMessage).Next(1, Delay(0))
+
+	if _, err := client.SendMessage(testMessage()); err != nil {
+		t.Errorf("Expected the endpoint script to apply first, got %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.SendMessage(testMessage()); err == nil {
+			t.Errorf("Expected request %d to fail, got nil", i)
+		}
+	}
+
+	if _, err := client.GetMessage(1); err == nil {
+		t.Error("Expected /messages/message to fail, got nil")
+	}
+
+	// Clearing the scripts restores normal behavior
+	server.ClearScripts()
+	if _, err := client.GetMessage(1); err != nil {
+		t.Errorf("Expected no error after ClearScripts, got %v", err)
+	}
+
+	if got := server.Calls(EndpointSendMessage); got != 4 {
+		t.Errorf("Expected 4 calls to /send/message, got %d", got)
+	}
+
+	server.Reset()
+	if got := server.Calls(EndpointSendMessage); got != 0 {
+		t.Errorf("Expected Reset to clear the call counts, got %d", got)
+	}
+}
@@ -0,0 +1,231 @@
+// This file contains the fake server's endpoint handlers and the helpers
+// that write Postal's JSON response envelopes.
+package postaltest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// authenticated wraps an endpoint handler with the method and API key
+// checks Postal performs on every request.
+func (s *Server) authenticated(next func(w http.ResponseWriter, r *http.Request, start time.Time)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		key := r.Header.Get("X-Server-API-Key")
+		if key == "" {
+			writeError(w, start, postalclient.CodeAccessDenied, "Must be authenticated as a server.", nil)
+			return
+		}
+		if key != s.apiKey {
+			writeError(w, start, postalclient.CodeInvalidServerAPIKey, "The API token provided in X-Server-API-Key was not valid.", map[string]any{"token": key})
+			return
+		}
+
+		next(w, r, start)
+	}
+}
+
+// handleSendMessage implements /send/message.
+func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request, start time.Time) {
+	var req models.SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeParameterError(w, start, "The request body was not valid JSON")
+		return
+	}
+
+	// Validate the message the same way Postal does, in the same order
+	switch {
+	case len(req.To) == 0 && len(req.CC) == 0 && len(req.BCC) == 0:
+		writeError(w, start, postalclient.CodeNoRecipients, "There are no recipients defined to receive this message", nil)
+		return
+	case len(req.To) > postalclient.MaxRecipientsPerField:
+		writeError(w, start, postalclient.CodeTooManyToAddresses, "The maximum number of To addresses has been reached (maximum 50)", nil)
+		return
+	case len(req.CC) > postalclient.MaxRecipientsPerField:
+		writeError(w, start, postalclient.CodeTooManyCCAddresses, "The maximum number of CC addresses has been reached (maximum 50)", nil)
+		return
+	case len(req.BCC) > postalclient.MaxRecipientsPerField:
+		writeError(w, start, postalclient.CodeTooManyBCCAddresses, "The maximum number of BCC addresses has been reached (maximum 50)", nil)
+		return
+	case req.From == "":
+		writeError(w, start, postalclient.CodeFromAddressMissing, "The From address is missing and is required", nil)
+		return
+	case !s.authorizedSender(req.From):
+		writeError(w, start, postalclient.CodeUnauthenticatedFromAddress, "The From address is not authorised to send mail from this server", nil)
+		return
+	case req.PlainBody == "" && req.HTMLBody == "":
+		writeError(w, start, postalclient.CodeNoContent, "There is no content defined for this e-mail", nil)
+		return
+	}
+	for _, attachment := range req.Attachments {
+		if attachment.Name == "" {
+			writeError(w, start, postalclient.CodeAttachmentMissingName, "An attachment is missing a name", nil)
+			return
+		}
+		if attachment.Data == "" {
+			writeError(w, start, postalclient.CodeAttachmentMissingData, "An attachment is missing data", nil)
+			return
+		}
+	}
+
+	writeSuccess(w, start, s.storeSend(&Message{Request: &req}))
+}
+
+// handleSendRaw implements /send/raw.
+func (s *Server) handleSendRaw(w http.ResponseWriter, r *http.Request, start time.Time) {
+	var req models.SendRawRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeParameterError(w, start, "The request body was not valid JSON")
+		return
+	}
+
+	switch {
+	case len(req.RcptTo) == 0:
+		writeParameterError(w, start, "`rcpt_to` is required")
+		return
+	case req.MailFrom == "":
+		writeParameterError(w, start, "`mail_from` is required")
+		return
+	case req.Data == "":
+		writeParameterError(w, start, "`data` is required")
+		return
+	case !s.authorizedSender(req.MailFrom):
+		writeError(w, start, postalclient.CodeUnauthenticatedFromAddress, "The From address is not authorised to send mail from this server", nil)
+		return
+	}
+	if _, err := base64.StdEncoding.DecodeString(req.Data); err != nil {
+		writeParameterError(w, start, "`data` must be base64 encoded")
+		return
+	}
+
+	writeSuccess(w, start, s.storeSend(&Message{Raw: &req}))
+}
+
+// idRequest is the request body of the /messages endpoints.
+type idRequest struct {
+	ID         *int            `json:"id"`
+	Expansions json.RawMessage `json:"_expansions"`
+}
+
+// lookupMessage decodes an idRequest from r and returns the message it
+// refers to along with the request. If the request is invalid or the
+// message doesn't exist, it writes the error response and returns nil.
+func (s *Server) lookupMessage(w http.ResponseWriter, r *http.Request, start time.Time) (*Message, *idRequest) {
+	var req idRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeParameterError(w, start, "The request body was not valid JSON")
+		return nil, nil
+	}
+	if req.ID == nil {
+		writeParameterError(w, start, "`id` is required")
+		return nil, nil
+	}
+
+	m := s.Message(*req.ID)
+	if m == nil {
+		writeError(w, start, postalclient.CodeMessageNotFound, "No message found matching provided ID", map[string]any{"id": *req.ID})
+		return nil, nil
+	}
+	return m, &req
+}
+
+// handleGetMessage implements /messages/message, including the
+// _expansions parameter.
+func (s *Server) handleGetMessage(w http.ResponseWriter, r *http.Request, start time.Time) {
+	m, req := s.lookupMessage(w, r, start)
+	if m == nil {
+		return
+	}
+	expansions, ok := parseExpansions(req.Expansions)
+	if !ok {
+		writeParameterError(w, start, "`_expansions` must be an array of strings or true")
+		return
+	}
+	writeSuccess(w, start, expandMessage(m, expansions))
+}
+
+// handleGetDeliveries implements /messages/deliveries.
+func (s *Server) handleGetDeliveries(w http.ResponseWriter, r *http.Request, start time.Time) {
+	m, _ := s.lookupMessage(w, r, start)
+	if m == nil {
+		return
+	}
+	deliveries := m.Deliveries
+	if deliveries == nil {
+		deliveries = []models.Delivery{}
+	}
+	writeSuccess(w, start, deliveries)
+}
+
+// authorizedSender reports whether the server may send from address.
+func (s *Server) authorizedSender(address string) bool {
+	if s.domains == nil {
+		return true
+	}
+
+	// Accept display-name forms such as "Name <user@example.com>"
+	if i := strings.LastIndex(address, "<"); i >= 0 {
+		address = strings.TrimSuffix(address[i+1:], ">")
+	}
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return false
+	}
+	return s.domains[strings.ToLower(strings.TrimSpace(address[at+1:]))]
+}
+
+// envelope is the JSON structure of every Postal API response.
+type envelope struct {
+	Status string          `json:"status"`
+	Time   float64         `json:"time"`
+	Flags  json.RawMessage `json:"flags"`
+	Data   any             `json:"data"`
+}
+
+// writeEnvelope writes a Postal response envelope with HTTP status 200,
+// which Postal uses for both successes and errors.
+func writeEnvelope(w http.ResponseWriter, start time.Time, status string, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(envelope{
+		Status: status,
+		Time:   time.Since(start).Seconds(),
+		Flags:  json.RawMessage(`{}`),
+		Data:   data,
+	})
+}
+
+// writeSuccess writes a successful response with the given data.
+func writeSuccess(w http.ResponseWriter, start time.Time, data any) {
+	writeEnvelope(w, start, "success", data)
+}
+
+// writeError writes an error response with the given code and message.
+// Extra fields are added to the data payload alongside them.
+func writeError(w http.ResponseWriter, start time.Time, code, message string, extra map[string]any) {
+	data := map[string]any{"code": code, "message": message}
+	for k, v := range extra {
+		data[k] = v
+	}
+	writeEnvelope(w, start, "error", data)
+}
+
+// writeParameterError writes a parameter-error response with the given
+// message.
+func writeParameterError(w http.ResponseWriter, start time.Time, message string) {
+	writeEnvelope(w, start, "parameter-error", map[string]any{"message": message})
+}
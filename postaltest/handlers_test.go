@@ -0,0 +1,145 @@
+package postaltest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+func TestServerRejectsBadAPIKeys(t *testing.T) {
+	server := NewServer(WithAPIKey("secret"))
+	defer server.Close()
+
+	req := &models.SendMessageRequest{To: []string{"a@example.com"}, From: "b@example.com", PlainBody: "Hi"}
+
+	// Wrong key
+	client := server.Client()
+	client.APIKey = "wrong"
+	_, err := client.SendMessage(req)
+
+	var authErr *postalclient.AuthError
+	if !errors.As(err, &authErr) {
+		t.Fatalf("Expected an *AuthError, got %v", err)
+	}
+
+	if authErr.Code != postalclient.CodeInvalidServerAPIKey || authErr.Token != "wrong" {
+		t.Errorf("Expected InvalidServerAPIKey with token, got %+v", authErr)
+	}
+
+	// Missing key
+	client.APIKey = ""
+	if _, err := client.SendMessage(req); !errors.Is(err, postalclient.ErrAccessDenied) {
+		t.Errorf("Expected ErrAccessDenied, got %v", err)
+	}
+
+	if len(server.Messages()) != 0 {
+		t.Error("Expected no messages to be stored")
+	}
+}
+
+func TestServerValidatesSendMessage(t *testing.T) {
+	server := NewServer(WithDomains("example.com"))
+	defer server.Close()
+	client := server.Client()
+
+	many := make([]string, 51)
+	for i := range many {
+		many[i] = "user@example.org"
+	}
+
+	tests := []struct {
+		name     string
+		req      models.SendMessageRequest
+		expected error
+	}{
+		{"no recipients", models.SendMessageRequest{From: "a@example.com", PlainBody: "Hi"}, postalclient.ErrNoRecipients},
+		{"too many to", models.SendMessageRequest{To: many, From: "a@example.com", PlainBody: "Hi"}, postalclient.ErrTooManyToAddresses},
+		{"too many cc", models.SendMessageRequest{To: many[:1], CC: many, From: "a@example.com", PlainBody: "Hi"}, postalclient.ErrTooManyCCAddresses},
+		{"too many bcc", models.SendMessageRequest{To: many[:1], BCC: many, From: "a@example.com", PlainBody: "Hi"}, postalclient.ErrTooManyBCCAddresses},
+		{"no from", models.SendMessageRequest{To: many[:1], PlainBody: "Hi"}, postalclient.ErrFromAddressMissing},
+		{"unauthenticated from", models.SendMessageRequest{To: many[:1], From: "a@other.com", PlainBody: "Hi"}, postalclient.ErrUnauthenticatedFromAddress},
+		{"no content", models.SendMessageRequest{To: many[:1], From: "Sender <a@example.com>"}, postalclient.ErrNoContent},
+		{"attachment name", models.SendMessageRequest{To: many[:1], From: "a@example.com", PlainBody: "Hi", Attachments: []models.Attachment{{Data: "aGk="}}}, postalclient.ErrAttachmentMissingName},
+		{"attachment data", models.SendMessageRequest{To: many[:1], From: "a@example.com", PlainBody: "Hi", Attachments: []models.Attachment{{Name: "a.txt"}}}, postalclient.ErrAttachmentMissingData},
+	}
+
+	for _, tt := range tests {
+		if _, err := client.SendMessage(&tt.req); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, err)
+		}
+	}
+
+	if len(server.Messages()) != 0 {
+		t.Error("Expected no messages to be stored")
+	}
+}
+
+func TestServerValidatesSendRaw(t *testing.T) {
+	server := NewServer(WithDomains("example.com"))
+	defer server.Close()
+	client := server.Client()
+
+	tests := []struct {
+		name     string
+		req      models.SendRawRequest
+		expected error
+		message  string
+	}{
+		{"no rcpt_to", models.SendRawRequest{MailFrom: "a@example.com", Data: "aGk="}, postalclient.ErrParameter, "`rcpt_to` is required"},
+		{"no mail_from", models.SendRawRequest{RcptTo: []string{"b@example.org"}, Data: "aGk="}, postalclient.ErrParameter, "`mail_from` is required"},
+		{"no data", models.SendRawRequest{MailFrom: "a@example.com", RcptTo: []string{"b@example.org"}}, postalclient.ErrParameter, "`data` is required"},
+		{"bad data", models.SendRawRequest{MailFrom: "a@example.com", RcptTo: []string{"b@example.org"}, Data: "not base64!"}, postalclient.ErrParameter, "base64"},
+		{"unauthenticated", models.SendRawRequest{MailFrom: "a@other.com", RcptTo: []string{"b@example.org"}, Data: "aGk="}, postalclient.ErrUnauthenticatedFromAddress, "not authorised"},
+	}
+
+	for _, tt := range tests {
+		_, err := client.SendRaw(&tt.req)
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected error to contain %q, got %q", tt.name, tt.message, err.Error())
+		}
+	}
+}
+
+func TestServerMessageNotFound(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	_, err := client.GetMessage(42)
+
+	var notFound *postalclient.NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Expected a *NotFoundError, got %v", err)
+	}
+
+	if notFound.ID != 42 {
+		t.Errorf("Expected ID to be 42, got %d", notFound.ID)
+	}
+
+	if _, err := client.GetMessageDeliveries(42); !errors.Is(err, postalclient.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+}
+
+func TestServerRejectsWrongMethod(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/send/message")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code to be 405, got %d", resp.StatusCode)
+	}
+}
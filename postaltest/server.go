@@ -0,0 +1,331 @@
+// Package postaltest provides an in-process fake Postal server for tests.
+//
+// The fake implements the /send/message, /send/raw, /messages/message and
+// /messages/deliveries endpoints with the same request and response formats
+// as Postal. Like Postal, it stores a message with its own ID and token for
+// every recipient of a send, so tests can make assertions about them, lets
+// tests simulate delivery attempts, and returns Postal's error responses for bad API keys
+// and invalid parameters. Tests can also script failures per endpoint, such
+// as 503 responses, latency, or dropped connections, with Server.Script.
+//
+// Basic usage:
+//
+//	func TestSignup(t *testing.T) {
+//	    server := postaltest.NewServer()
+//	    defer server.Close()
+//
+//	    app := NewApp(server.Client())
+//	    app.Signup("user@example.com")
+//
+//	    msg := server.LastMessage()
+//	    if msg == nil || msg.Request.To[0] != "user@example.com" {
+//	        t.Fatalf("expected welcome email, got %+v", msg)
+//	    }
+//	}
+package postaltest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+const (
+	// DefaultAPIKey is the API key accepted by a server created without
+	// WithAPIKey.
+	DefaultAPIKey = "test-api-key"
+
+	// APIPath is the path under which the server serves the API, the same
+	// as on a real Postal server.
+	APIPath = "/api/v1"
+)
+
+// Message is a message accepted by the fake server. A send creates one
+// for each of its recipients.
+type Message struct {
+	// ID is the message ID assigned by the server.
+	ID int
+
+	// Token is the message token assigned by the server.
+	Token string
+
+	// Rcpt is the recipient the message is for.
+	Rcpt string
+
+	// Request is the request the message was sent with, or nil if it was
+	// sent with /send/raw.
+	Request *models.SendMessageRequest
+
+	// Raw is the request the message was sent with, or nil if it was sent
+	// with /send/message.
+	Raw *models.SendRawRequest
+
+	// Deliveries are the delivery attempts recorded for the message,
+	// oldest first.
+	Deliveries []models.Delivery
+
+	// ReceivedAt is when the server accepted the message.
+	ReceivedAt time.Time
+}
+
+// Recipients returns every recipient of the send that created the message:
+// To, CC, and BCC for messages sent with /send/message, or RcptTo for raw
+// messages. Rcpt is the one the message is for.
+func (m *Message) Recipients() []string {
+	if m.Raw != nil {
+		return append([]string(nil), m.Raw.RcptTo...)
+	}
+	var recipients []string
+	recipients = append(recipients, m.Request.To...)
+	recipients = append(recipients, m.Request.CC...)
+	recipients = append(recipients, m.Request.BCC...)
+	return recipients
+}
+
+// String returns a short description of the message for test failures.
+func (m *Message) String() string {
+	if m.Raw != nil {
+		return fmt.Sprintf("raw message %d from %s to %s", m.ID, m.Raw.MailFrom, m.Rcpt)
+	}
+	return fmt.Sprintf("message %d from %s to %s: %q", m.ID, m.Request.From, m.Rcpt, m.Request.Subject)
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithAPIKey sets the API key the server accepts.
+func WithAPIKey(apiKey string) Option {
+	return func(s *Server) {
+		s.apiKey = apiKey
+	}
+}
+
+// WithDomains restricts the domains the server may send from. Sends from
+// any other domain fail with UnauthenticatedFromAddress, like they do on a
+// real Postal server. By default every domain is accepted.
+func WithDomains(domains ...string) Option {
+	return func(s *Server) {
+		s.domains = make(map[string]bool, len(domains))
+		for _, domain := range domains {
+			s.domains[strings.ToLower(domain)] = true
+		}
+	}
+}
+
+// WithAutoDeliver makes the server record a delivery attempt with the given
+// status (e.g. models.StatusSent) for every message as soon as it is
+// accepted.
+func WithAutoDeliver(status models.DeliveryStatus) Option {
+	return func(s *Server) {
+		s.autoDeliver = status
+	}
+}
+
+// Server is a fake Postal server. It is safe for concurrent use.
+type Server struct {
+	// URL is the base URL of the API, including APIPath, suitable for
+	// Client.BaseURL.
+	URL string
+
+	httpServer  *httptest.Server
+	mux         http.Handler
+	apiKey      string
+	domains     map[string]bool
+	autoDeliver models.DeliveryStatus
+
+	mu             sync.Mutex
+	nextMessageID  int
+	nextDeliveryID int
+	messages       []*Message
+	byID           map[int]*Message
+	scripts        map[string]*Script
+	calls          map[string]int
+}
+
+// NewServer starts and returns a new fake Postal server. The caller should
+// call Close when finished, to shut it down.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		apiKey:         DefaultAPIKey,
+		nextMessageID:  1,
+		nextDeliveryID: 1,
+		byID:           make(map[int]*Message),
+		scripts:        make(map[string]*Script),
+		calls:          make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.mux = s.routes()
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.httpServer.URL + APIPath
+	return s
+}
+
+// Close shuts down the server and blocks until all outstanding requests
+// have completed.
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// APIKey returns the API key the server accepts.
+func (s *Server) APIKey() string {
+	return s.apiKey
+}
+
+// Client returns a new client configured to talk to the server with its
+// API key.
+func (s *Server) Client() *postalclient.Client {
+	client := postalclient.NewClient(s.apiKey)
+	client.BaseURL = s.URL
+	client.HTTPClient = s.httpServer.Client()
+	return client
+}
+
+// Messages returns the messages accepted by the server, oldest first.
+func (s *Server) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := make([]*Message, len(s.messages))
+	for i, m := range s.messages {
+		messages[i] = m.clone()
+	}
+	return messages
+}
+
+// Message returns the message with the given ID, or nil if there is none.
+func (s *Server) Message(id int) *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.byID[id]; ok {
+		return m.clone()
+	}
+	return nil
+}
+
+// LastMessage returns the most recently accepted message, or nil if no
+// message has been accepted.
+func (s *Server) LastMessage() *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) == 0 {
+		return nil
+	}
+	return s.messages[len(s.messages)-1].clone()
+}
+
+// Reset forgets every accepted message and resets the call counts
+// returned by Calls. Scripts are kept; use ClearScripts to remove them. IDs
+// keep increasing, so IDs are never reused by a server.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+	s.byID = make(map[int]*Message)
+	s.calls = make(map[string]int)
+}
+
+// SimulateDelivery records a delivery attempt for the message with the
+// given ID and returns it. The delivery's ID and timestamp are assigned by
+// the server if they are zero. It returns false if there is no such message.
+func (s *Server) SimulateDelivery(messageID int, delivery models.Delivery) (models.Delivery, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.byID[messageID]
+	if !ok {
+		return models.Delivery{}, false
+	}
+	return s.addDeliveryLocked(m, delivery), true
+}
+
+// addDeliveryLocked appends a delivery to m, filling in its ID and
+// timestamp. s.mu must be held.
+func (s *Server) addDeliveryLocked(m *Message, delivery models.Delivery) models.Delivery {
+	if delivery.ID == 0 {
+		delivery.ID = s.nextDeliveryID
+		s.nextDeliveryID++
+	}
+	if delivery.Timestamp.IsZero() {
+		delivery.Timestamp = time.Now().UTC()
+	}
+	m.Deliveries = append(m.Deliveries, delivery)
+	return delivery
+}
+
+// storeSend stores a copy of send for each of its recipients, assigning
+// each an ID and token, records automatic deliveries if enabled, and
+// returns the response Postal gives for the send. Recipients listed more
+// than once get a single message.
+func (s *Server) storeSend(send *Message) models.SendMessageResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := models.SendMessageResponse{Messages: make(map[string]models.RecipientMessage)}
+	receivedAt := time.Now().UTC()
+	for _, rcpt := range send.Recipients() {
+		if _, ok := resp.Messages[rcpt]; ok {
+			continue
+		}
+
+		m := *send
+		m.ID = s.nextMessageID
+		s.nextMessageID++
+		m.Token = newToken()
+		m.Rcpt = rcpt
+		m.ReceivedAt = receivedAt
+		s.messages = append(s.messages, &m)
+		s.byID[m.ID] = &m
+
+		if s.autoDeliver != "" {
+			s.addDeliveryLocked(&m, models.Delivery{
+				Status:  s.autoDeliver,
+				Details: "Message for " + rcpt + " accepted by postaltest",
+				Output:  "250 2.0.0 OK",
+			})
+		}
+
+		resp.Messages[rcpt] = models.RecipientMessage{ID: m.ID, Token: m.Token}
+		if resp.MessageID == 0 {
+			resp.MessageID, resp.Token = m.ID, m.Token
+		}
+	}
+	return resp
+}
+
+// clone returns a copy of m whose Deliveries slice can be read without
+// holding the server's lock.
+func (m *Message) clone() *Message {
+	c := *m
+	c.Deliveries = append([]models.Delivery(nil), m.Deliveries...)
+	return &c
+}
+
+// tokenAlphabet is the set of characters used in message tokens.
+const tokenAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// newToken returns a random token in the same format as Postal's.
+func newToken() string {
+	b := make([]byte, 12)
+	for i := range b {
+		b[i] = tokenAlphabet[rand.IntN(len(tokenAlphabet))]
+	}
+	return string(b)
+}
+
+// routes returns the handler for the server's endpoints.
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(APIPath+EndpointSendMessage, s.authenticated(s.handleSendMessage))
+	mux.HandleFunc(APIPath+EndpointSendRaw, s.authenticated(s.handleSendRaw))
+	mux.HandleFunc(APIPath+EndpointMessage, s.authenticated(s.handleGetMessage))
+	mux.HandleFunc(APIPath+EndpointMessageDeliveries, s.authenticated(s.handleGetDeliveries))
+	return mux
+}
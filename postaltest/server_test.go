@@ -0,0 +1,216 @@
+package postaltest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+func TestServerStoresSentMessages(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	// Send a message
+	resp, err := client.SendMessage(&models.SendMessageRequest{
+		To:        []string{"recipient@example.com"},
+		BCC:       []string{"audit@example.com"},
+		From:      "sender@example.com",
+		Subject:   "Hello",
+		PlainBody: "Hi there",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if resp.MessageID != 1 {
+		t.Errorf("Expected message ID to be 1, got %d", resp.MessageID)
+	}
+
+	if len(resp.Token) != 12 {
+		t.Errorf("Expected a 12 character token, got %q", resp.Token)
+	}
+
+	// A message is stored for each recipient, like Postal does
+	messages := server.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 stored messages, got %v", messages)
+	}
+	for i, rcpt := range []string{"recipient@example.com", "audit@example.com"} {
+		msg := messages[i]
+		if msg.ID != i+1 || msg.Rcpt != rcpt || msg.Request.Subject != "Hello" {
+			t.Errorf("Expected message %d for %s, got %s", i+1, rcpt, msg)
+		}
+		if got := resp.Messages[rcpt]; got.ID != msg.ID || got.Token != msg.Token {
+			t.Errorf("Expected the response to list message %d for %s, got %+v", msg.ID, rcpt, got)
+		}
+		if got := msg.Recipients(); len(got) != 2 || got[1] != "audit@example.com" {
+			t.Errorf("Expected recipients to include BCC, got %v", got)
+		}
+	}
+
+	if messages[0].Token != resp.Token || messages[0].Token == messages[1].Token {
+		t.Errorf("Expected distinct tokens, the first in the response, got %s and %s", messages[0].Token, messages[1].Token)
+	}
+
+	// Send a raw message
+	raw, err := client.SendRaw(&models.SendRawRequest{
+		MailFrom: "sender@example.com",
+		RcptTo:   []string{"recipient@example.com"},
+		Data:     "U3ViamVjdDogSGkNCg0KSGk=",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if raw.MessageID != 3 || len(raw.Messages) != 1 {
+		t.Errorf("Expected message ID to be 3, got %+v", raw)
+	}
+
+	if messages := server.Messages(); len(messages) != 3 || messages[2].Raw == nil {
+		t.Errorf("Expected 3 messages with the third raw, got %v", messages)
+	}
+
+	// Reset forgets messages but keeps counting IDs
+	server.Reset()
+	if len(server.Messages()) != 0 || server.LastMessage() != nil {
+		t.Error("Expected no messages after reset")
+	}
+
+	resp, err = client.SendMessage(&models.SendMessageRequest{
+		To: []string{"recipient@example.com"}, From: "sender@example.com", PlainBody: "Again",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if resp.MessageID != 4 {
+		t.Errorf("Expected message ID to be 4, got %d", resp.MessageID)
+	}
+}
+
+func TestServerGetMessageAndDeliveries(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	resp, err := client.SendMessage(&models.SendMessageRequest{
+		To: []string{"recipient@example.com"}, From: "sender@example.com", PlainBody: "Hi",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Get the message back
+	message, err := client.GetMessage(resp.MessageID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if message.ID != resp.MessageID || message.Token != resp.Token {
+		t.Errorf("Expected message to match response, got %+v", message)
+	}
+
+	// No deliveries yet
+	deliveries, err := client.GetMessageDeliveries(resp.MessageID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(deliveries) != 0 {
+		t.Errorf("Expected no deliveries, got %d", len(deliveries))
+	}
+
+	// Simulate a soft failure followed by a successful delivery
+	if _, ok := server.SimulateDelivery(resp.MessageID, models.Delivery{Status: "SoftFail", Details: "Greylisted"}); !ok {
+		t.Fatal("Expected delivery to be recorded")
+	}
+	if _, ok := server.SimulateDelivery(resp.MessageID, models.Delivery{Status: "Sent", SentWithSSL: true}); !ok {
+		t.Fatal("Expected delivery to be recorded")
+	}
+
+	deliveries, err = client.GetMessageDeliveries(resp.MessageID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(deliveries) != 2 {
+		t.Fatalf("Expected 2 deliveries, got %d", len(deliveries))
+	}
+
+	if deliveries[0].Status != "SoftFail" || deliveries[1].Status != "Sent" {
+		t.Errorf("Expected deliveries in order, got %+v", deliveries)
+	}
+
+	if deliveries[0].ID == deliveries[1].ID || deliveries[1].Timestamp.IsZero() {
+		t.Errorf("Expected deliveries to get IDs and timestamps, got %+v", deliveries)
+	}
+
+	// Unknown message
+	if _, ok := server.SimulateDelivery(999, models.Delivery{Status: "Sent"}); ok {
+		t.Error("Expected delivery for unknown message to fail")
+	}
+}
+
+func TestServerAutoDeliver(t *testing.T) {
+	server := NewServer(WithAutoDeliver("Sent"))
+	defer server.Close()
+	client := server.Client()
+
+	resp, err := client.SendMessage(&models.SendMessageRequest{
+		To: []string{"a@example.com"}, CC: []string{"b@example.com"}, From: "sender@example.com", PlainBody: "Hi",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Each recipient's message gets a delivery
+	ids := resp.MessageIDs()
+	if len(ids) != 2 {
+		t.Fatalf("Expected a message per recipient, got %v", ids)
+	}
+	for _, id := range ids {
+		deliveries, err := client.GetMessageDeliveries(id)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(deliveries) != 1 || deliveries[0].Status != "Sent" {
+			t.Errorf("Expected a Sent delivery for message %d, got %+v", id, deliveries)
+		}
+	}
+}
+
+func TestServerConcurrentSends(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.SendMessage(&models.SendMessageRequest{
+				To: []string{"recipient@example.com"}, From: "sender@example.com", PlainBody: "Hi",
+			})
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every message gets a unique ID
+	seen := map[int]bool{}
+	for _, msg := range server.Messages() {
+		if seen[msg.ID] {
+			t.Errorf("Expected unique message IDs, got %d twice", msg.ID)
+		}
+		seen[msg.ID] = true
+	}
+
+	if len(seen) != 20 {
+		t.Errorf("Expected 20 messages, got %d", len(seen))
+	}
+}
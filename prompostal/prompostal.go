@@ -0,0 +1,246 @@
+// Package prompostal exports Prometheus metrics for a postalclient.Client.
+//
+// It lives in its own module so the core client keeps no dependencies.
+// Create a Collector, register it, and install its middleware on the
+// client:
+//
+//	collector := prompostal.NewCollector()
+//	prometheus.MustRegister(collector)
+//
+//	client, err := postalclient.New("your-api-key",
+//	    postalclient.WithBaseURL("https://postal.yourdomain.com/api/v1"),
+//	    postalclient.WithMiddleware(collector.Middleware()),
+//	)
+//
+// One collector can be shared by several clients. The metrics are:
+//
+//   - postal_client_requests_total{operation, outcome, error_code}: calls by
+//     outcome ("success" or "error") and error code, as classified by
+//     postalclient.ErrorType
+//   - postal_client_request_duration_seconds{operation}: call latency
+//     measured by the client, including retries and rate limiting
+//   - postal_client_server_duration_seconds{operation}: processing time
+//     reported by Postal in its responses
+//   - postal_client_retries_total{operation}: HTTP requests retried
+//   - postal_client_in_flight_requests{operation}: calls in progress
+//   - postal_client_attachment_bytes_total: decoded size of the attachments
+//     of messages sent with SendMessage
+//   - postal_client_rate_limit_wait_seconds{operation}: time spent waiting
+//     for the client's RateLimiter
+package prompostal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"github.com/Suhaibinator/postalclient-go/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcome label values.
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// Option configures a Collector.
+type Option func(*config)
+
+// config holds the settings for a Collector.
+type config struct {
+	namespace   string
+	constLabels prometheus.Labels
+	buckets     []float64
+}
+
+// WithNamespace sets the namespace prefixed to every metric name. The
+// default is "postal".
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithConstLabels adds labels with fixed values to every metric, e.g. to
+// tell apart clients for different Postal servers registered with
+// separate collectors.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(c *config) {
+		c.constLabels = labels
+	}
+}
+
+// WithBuckets sets the buckets of the duration histograms. The default is
+// prometheus.DefBuckets.
+func WithBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// Collector records metrics for the calls made through its Middleware. It
+// implements prometheus.Collector.
+type Collector struct {
+	requests        *prometheus.CounterVec
+	duration        *prometheus.HistogramVec
+	serverDuration  *prometheus.HistogramVec
+	retries         *prometheus.CounterVec
+	inFlight        *prometheus.GaugeVec
+	attachmentBytes prometheus.Counter
+	rateLimitWait   *prometheus.HistogramVec
+}
+
+// NewCollector creates a Collector with the given options.
+func NewCollector(opts ...Option) *Collector {
+	c := &config{
+		namespace: "postal",
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	const subsystem = "client"
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Subsystem:   subsystem,
+			Name:        "requests_total",
+			Help:        "Postal API calls by operation, outcome, and error code.",
+			ConstLabels: c.constLabels,
+		}, []string{"operation", "outcome", "error_code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.namespace,
+			Subsystem:   subsystem,
+			Name:        "request_duration_seconds",
+			Help:        "Latency of Postal API calls measured by the client, including retries and rate limiting.",
+			ConstLabels: c.constLabels,
+			Buckets:     c.buckets,
+		}, []string{"operation"}),
+		serverDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.namespace,
+			Subsystem:   subsystem,
+			Name:        "server_duration_seconds",
+			Help:        "Processing time reported by the Postal server.",
+			ConstLabels: c.constLabels,
+			Buckets:     c.buckets,
+		}, []string{"operation"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Subsystem:   subsystem,
+			Name:        "retries_total",
+			Help:        "HTTP requests to Postal that were retried.",
+			ConstLabels: c.constLabels,
+		}, []string{"operation"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   c.namespace,
+			Subsystem:   subsystem,
+			Name:        "in_flight_requests",
+			Help:        "Postal API calls in progress.",
+			ConstLabels: c.constLabels,
+		}, []string{"operation"}),
+		attachmentBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Subsystem:   subsystem,
+			Name:        "attachment_bytes_total",
+			Help:        "Decoded size of the attachments of messages sent.",
+			ConstLabels: c.constLabels,
+		}),
+		rateLimitWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.namespace,
+			Subsystem:   subsystem,
+			Name:        "rate_limit_wait_seconds",
+			Help:        "Time Postal API calls spent waiting for the client's rate limiter.",
+			ConstLabels: c.constLabels,
+			Buckets:     c.buckets,
+		}, []string{"operation"}),
+	}
+}
+
+// collectors returns the metrics of c.
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.requests,
+		c.duration,
+		c.serverDuration,
+		c.retries,
+		c.inFlight,
+		c.attachmentBytes,
+		c.rateLimitWait,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// Middleware returns a postalclient.Middleware that records the metrics of
+// every call.
+func (c *Collector) Middleware() postalclient.Middleware {
+	return func(next postalclient.Handler) postalclient.Handler {
+		return func(ctx context.Context, call *postalclient.Call) error {
+			inFlight := c.inFlight.WithLabelValues(call.Name)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			start := time.Now()
+			err := next(ctx, call)
+			c.duration.WithLabelValues(call.Name).Observe(time.Since(start).Seconds())
+
+			outcome := OutcomeSuccess
+			if err != nil {
+				outcome = OutcomeError
+			}
+			c.requests.WithLabelValues(call.Name, outcome, postalclient.ErrorType(err)).Inc()
+
+			if call.Attempts > 1 {
+				c.retries.WithLabelValues(call.Name).Add(float64(call.Attempts - 1))
+			}
+			c.rateLimitWait.WithLabelValues(call.Name).Observe(call.RateLimitWait.Seconds())
+
+			var apiErr *postalclient.Error
+			switch {
+			case errors.As(err, &apiErr):
+				c.serverDuration.WithLabelValues(call.Name).Observe(apiErr.Time)
+			case err == nil && call.Envelope != nil:
+				c.serverDuration.WithLabelValues(call.Name).Observe(call.Envelope.Time)
+			}
+
+			if req, ok := call.Request.(*models.SendMessageRequest); ok && err == nil {
+				var size int
+				for _, a := range req.Attachments {
+					size += decodedLen(a.Data)
+				}
+				c.attachmentBytes.Add(float64(size))
+			}
+			return err
+		}
+	}
+}
+
+// decodedLen returns the decoded size of base64 data without decoding it.
+// MIME base64 is often broken into lines, so white space and padding are
+// not counted.
+func decodedLen(data string) int {
+	var n int
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case ' ', '\t', '\r', '\n', '=':
+		default:
+			n++
+		}
+	}
+	return n * 3 / 4
+}
@@ -0,0 +1,198 @@
+package prompostal
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go"
+	"github.com/Suhaibinator/postalclient-go/models"
+	"github.com/Suhaibinator/postalclient-go/postaltest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestClient returns a fake Postal server and a client instrumented by
+// collector.
+func newTestClient(t *testing.T, collector *Collector, opts ...postalclient.Option) (*postaltest.Server, *postalclient.Client) {
+	t.Helper()
+	server := postaltest.NewServer()
+	t.Cleanup(server.Close)
+
+	opts = append([]postalclient.Option{
+		postalclient.WithBaseURL(server.URL),
+		postalclient.WithMiddleware(collector.Middleware()),
+	}, opts...)
+	client, err := postalclient.New(server.APIKey(), opts...)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return server, client
+}
+
+// testMessage returns a valid message with a 5-byte attachment.
+func testMessage() *models.SendMessageRequest {
+	return &models.SendMessageRequest{
+		From:      "a@example.com",
+		To:        []string{"b@example.org"},
+		PlainBody: "Hi",
+		Attachments: []models.Attachment{{
+			Name:        "hello.txt",
+			ContentType: "text/plain",
+			Data:        base64.StdEncoding.EncodeToString([]byte("hello")),
+		}},
+	}
+}
+
+func TestCollectorCountsOutcomes(t *testing.T) {
+	collector := NewCollector()
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+	_, client := newTestClient(t, collector)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.SendMessage(testMessage()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if _, err := client.SendMessage(&models.SendMessageRequest{From: "a@example.com"}); !errors.Is(err, postalclient.ErrNoRecipients) {
+		t.Fatalf("Expected ErrNoRecipients, got %v", err)
+	}
+	if _, err := client.GetMessage(99); !errors.Is(err, postalclient.ErrMessageNotFound) {
+		t.Fatalf("Expected ErrMessageNotFound, got %v", err)
+	}
+
+	want := `
+# HELP postal_client_requests_total Postal API calls by operation, outcome, and error code.
+# TYPE postal_client_requests_total counter
+postal_client_requests_total{error_code="",operation="send.message",outcome="success"} 2
+postal_client_requests_total{error_code="MessageNotFound",operation="messages.message",outcome="error"} 1
+postal_client_requests_total{error_code="NoRecipients",operation="send.message",outcome="error"} 1
+# HELP postal_client_attachment_bytes_total Decoded size of the attachments of messages sent.
+# TYPE postal_client_attachment_bytes_total counter
+postal_client_attachment_bytes_total 10
+# HELP postal_client_in_flight_requests Postal API calls in progress.
+# TYPE postal_client_in_flight_requests gauge
+postal_client_in_flight_requests{operation="messages.message"} 0
+postal_client_in_flight_requests{operation="send.message"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want),
+		"postal_client_requests_total",
+		"postal_client_attachment_bytes_total",
+		"postal_client_in_flight_requests",
+	); err != nil {
+		t.Error(err)
+	}
+
+	// Both latencies are recorded for every call that got a response
+	if got := testutil.CollectAndCount(collector, "postal_client_request_duration_seconds"); got != 2 {
+		t.Errorf("Expected 2 client duration series, got %d", got)
+	}
+
+	if got := testutil.CollectAndCount(collector, "postal_client_server_duration_seconds"); got != 2 {
+		t.Errorf("Expected 2 server duration series, got %d", got)
+	}
+
+	if problems, err := testutil.GatherAndLint(registry); err != nil || len(problems) > 0 {
+		t.Errorf("Expected no lint problems, got %v %v", problems, err)
+	}
+}
+
+func TestCollectorRetriesAndRateLimitWait(t *testing.T) {
+	collector := NewCollector(WithNamespace("mail"), WithConstLabels(prometheus.Labels{"server": "eu"}))
+	server, client := newTestClient(t, collector,
+		postalclient.WithRetry(&postalclient.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		postalclient.WithRateLimiter(postalclient.NewRateLimiter(postalclient.RateLimiterConfig{
+			Send: postalclient.RateLimit{Rate: 50, Burst: 1},
+		})),
+	)
+	server.Script(postaltest.EndpointSendMessage).Next(2, postaltest.Unavailable(0))
+
+	if _, err := client.SendMessage(testMessage()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := `
+# HELP mail_client_retries_total HTTP requests to Postal that were retried.
+# TYPE mail_client_retries_total counter
+mail_client_retries_total{operation="send.message",server="eu"} 2
+`
+	if err := testutil.CollectAndCompare(collector.retries, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// The retries had to wait for the limiter's 20ms interval
+	if sum := histogramSum(t, collector, "mail_client_rate_limit_wait_seconds"); sum < 0.02 {
+		t.Errorf("Expected at least 20ms of rate limit wait, got %vs", sum)
+	}
+}
+
+func TestCollectorInFlight(t *testing.T) {
+	collector := NewCollector()
+	server, client := newTestClient(t, collector)
+	server.Script(postaltest.EndpointMessage).Always(postaltest.Delay(100 * time.Millisecond))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = client.GetMessageContext(context.Background(), 1)
+		}()
+	}
+
+	// Wait for the calls to reach the server
+	deadline := time.Now().Add(time.Second)
+	for server.Calls(postaltest.EndpointMessage) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if got := testutil.ToFloat64(collector.inFlight.WithLabelValues("messages.message")); got != 3 {
+		t.Errorf("Expected 3 calls in flight, got %v", got)
+	}
+
+	wg.Wait()
+	if got := testutil.ToFloat64(collector.inFlight.WithLabelValues("messages.message")); got != 0 {
+		t.Errorf("Expected no calls in flight, got %v", got)
+	}
+}
+
+func TestDecodedLen(t *testing.T) {
+	for _, s := range []string{"", "a", "ab", "abc", "hello, world", strings.Repeat("x", 1000)} {
+		encoded := base64.StdEncoding.EncodeToString([]byte(s))
+		if got := decodedLen(encoded); got != len(s) {
+			t.Errorf("Expected decodedLen of %q to be %d, got %d", encoded, len(s), got)
+		}
+	}
+
+	// Line breaks in MIME base64 are not counted
+	data := strings.Repeat("x", 200)
+	encoded := base64.StdEncoding.EncodeToString([]byte(data))
+	wrapped := encoded[:76] + "\r\n" + encoded[76:152] + "\r\n" + encoded[152:] + "\r\n"
+	if got := decodedLen(wrapped); got != len(data) {
+		t.Errorf("Expected decodedLen of wrapped data to be %d, got %d", len(data), got)
+	}
+}
+
+// histogramSum returns the sum of the observations of the first series of
+// the named histogram.
+func histogramSum(t *testing.T, collector prometheus.Collector, name string) float64 {
+	t.Helper()
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == name && len(family.GetMetric()) > 0 {
+			return family.GetMetric()[0].GetHistogram().GetSampleSum()
+		}
+	}
+	t.Fatalf("Expected a %s histogram", name)
+	return 0
+}
@@ -0,0 +1,389 @@
+// This file contains the client-side rate limiter, which keeps a client
+// under a Postal server's limits and slows down further when the server
+// signals that it is being overloaded.
+package postalclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultThrottleFactor is the default factor by which a RateLimiter
+	// reduces its rate each time the server throttles a request.
+	DefaultThrottleFactor = 0.5
+
+	// DefaultMinRateFraction is the default lower bound for an adaptively
+	// reduced rate, as a fraction of the configured rate.
+	DefaultMinRateFraction = 0.1
+
+	// DefaultRecoveryPeriod is the default time a RateLimiter takes to
+	// climb from its minimum rate back to the configured rate.
+	DefaultRecoveryPeriod = time.Minute
+
+	// DefaultThrottlePause is the default time a RateLimiter stops sending
+	// after a throttled request that carried no Retry-After header.
+	DefaultThrottlePause = time.Second
+)
+
+// EndpointClass groups API endpoints that share a rate limit.
+type EndpointClass string
+
+const (
+	// EndpointClassSend covers the endpoints that send messages:
+	// /send/message and /send/raw.
+	EndpointClassSend EndpointClass = "send"
+
+	// EndpointClassRead covers every other endpoint, such as
+	// /messages/message and /messages/deliveries.
+	EndpointClassRead EndpointClass = "read"
+)
+
+// endpointClass returns the class of the given API path.
+func endpointClass(path string) EndpointClass {
+	if strings.HasPrefix(path, "/send/") {
+		return EndpointClassSend
+	}
+	return EndpointClassRead
+}
+
+// RateLimit configures a token bucket.
+type RateLimit struct {
+	// Rate is the sustained number of requests per second.
+	// Zero means requests are not limited, although the class still
+	// pauses when the server throttles a request.
+	Rate float64
+
+	// Burst is the number of requests that may be made at once after a
+	// quiet period. If less than 1, a burst of 1 is used.
+	Burst int
+}
+
+// RateLimiterConfig configures a RateLimiter.
+type RateLimiterConfig struct {
+	// Send limits the endpoints that send messages.
+	Send RateLimit
+
+	// Read limits every other endpoint.
+	Read RateLimit
+
+	// ThrottleFactor is the factor (between 0 and 1) by which the rate of
+	// a class is multiplied each time the server throttles one of its
+	// requests. If zero, DefaultThrottleFactor is used.
+	ThrottleFactor float64
+
+	// MinRateFraction bounds how far throttling can reduce the rate, as a
+	// fraction of the configured rate. If zero, DefaultMinRateFraction is
+	// used.
+	MinRateFraction float64
+
+	// RecoveryPeriod is how long the rate takes to climb linearly from its
+	// minimum back to the configured rate once throttling stops. If zero,
+	// DefaultRecoveryPeriod is used.
+	RecoveryPeriod time.Duration
+
+	// ThrottlePause is how long a class stops sending after a throttled
+	// request without a Retry-After header. A Retry-After header sent by
+	// the server takes precedence. If zero, DefaultThrottlePause is used.
+	ThrottlePause time.Duration
+
+	// ThrottleErrorCodes lists Postal Error.ErrorCode values that mean the
+	// server is rate limiting the client. Responses with HTTP status 429
+	// always count as throttled. Optional.
+	ThrottleErrorCodes []string
+}
+
+// RateLimiter is a client-side token bucket rate limiter with a separate
+// bucket for each EndpointClass.
+//
+// When the server throttles a request, by responding with HTTP status 429
+// or with one of the configured ThrottleErrorCodes, the limiter pauses that
+// class for the server's Retry-After delay (or ThrottlePause) and cuts its
+// rate by ThrottleFactor. The rate then climbs back to the configured value
+// over RecoveryPeriod.
+//
+// A RateLimiter is safe for concurrent use, and may be shared by several
+// clients that talk to the same server. A nil *RateLimiter (the default for
+// a new Client) does not limit requests.
+//
+// Example:
+//
+//	client := postalclient.NewClient("your-api-key")
+//	client.RateLimiter = postalclient.NewRateLimiter(postalclient.RateLimiterConfig{
+//	    Send: postalclient.RateLimit{Rate: 10, Burst: 20},
+//	    Read: postalclient.RateLimit{Rate: 50, Burst: 50},
+//	})
+type RateLimiter struct {
+	config RateLimiterConfig
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[EndpointClass]*bucket
+}
+
+// bucket is the token bucket for a single endpoint class.
+type bucket struct {
+	// limit is the configured rate, and rate the current adaptive rate.
+	limit, rate float64
+	burst       float64
+	tokens      float64
+	updated     time.Time
+	pausedUntil time.Time
+
+	throttled    int
+	lastThrottle time.Time
+}
+
+// NewRateLimiter creates a RateLimiter with the given configuration.
+// Both buckets start full.
+func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+	l := &RateLimiter{
+		config:  config,
+		now:     time.Now,
+		buckets: make(map[EndpointClass]*bucket),
+	}
+	for class, limit := range map[EndpointClass]RateLimit{
+		EndpointClassSend: config.Send,
+		EndpointClassRead: config.Read,
+	} {
+		burst := float64(max(limit.Burst, 1))
+		l.buckets[class] = &bucket{
+			limit:  max(limit.Rate, 0),
+			rate:   max(limit.Rate, 0),
+			burst:  burst,
+			tokens: burst,
+		}
+	}
+	return l
+}
+
+// RateLimitState is a snapshot of one bucket of a RateLimiter.
+type RateLimitState struct {
+	// Limit is the configured rate in requests per second, or 0 if the
+	// class is not limited.
+	Limit float64
+
+	// Rate is the current rate, which is lower than Limit after the
+	// server throttled requests.
+	Rate float64
+
+	// Burst is the capacity of the bucket.
+	Burst int
+
+	// Tokens is the number of requests that can be made right now.
+	Tokens float64
+
+	// PausedUntil is when the class resumes sending after being
+	// throttled, or the zero time if it is not paused.
+	PausedUntil time.Time
+
+	// Throttled counts the throttled responses seen for this class.
+	Throttled int
+
+	// LastThrottle is when the class was last throttled, or the zero time
+	// if it never was.
+	LastThrottle time.Time
+}
+
+// RateLimiterState is a snapshot of a RateLimiter, returned by State.
+type RateLimiterState struct {
+	// Send is the state of the send endpoints' bucket.
+	Send RateLimitState
+
+	// Read is the state of the other endpoints' bucket.
+	Read RateLimitState
+}
+
+// State returns a snapshot of the limiter's buckets. It returns the zero
+// value for a nil limiter.
+func (l *RateLimiter) State() RateLimiterState {
+	if l == nil {
+		return RateLimiterState{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	return RateLimiterState{
+		Send: l.stateLocked(EndpointClassSend, now),
+		Read: l.stateLocked(EndpointClassRead, now),
+	}
+}
+
+// stateLocked returns the state of one bucket. l.mu must be held.
+func (l *RateLimiter) stateLocked(class EndpointClass, now time.Time) RateLimitState {
+	b := l.buckets[class]
+	l.refillLocked(b, now)
+	state := RateLimitState{
+		Limit:        b.limit,
+		Rate:         b.rate,
+		Burst:        int(b.burst),
+		Tokens:       b.tokens,
+		Throttled:    b.throttled,
+		LastThrottle: b.lastThrottle,
+	}
+	if now.Before(b.pausedUntil) {
+		state.PausedUntil = b.pausedUntil
+	}
+	return state
+}
+
+// Wait blocks until a request of the given class may be made, or until ctx
+// is done. It returns how long it waited, and ctx.Err() if the context
+// ended the wait. A nil limiter never waits and never fails.
+//
+// The client calls Wait before every attempt; it is exported so other
+// code sharing the limiter can pace itself too.
+func (l *RateLimiter) Wait(ctx context.Context, class EndpointClass) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	var waited time.Duration
+	for {
+		delay := l.reserve(class)
+		if delay <= 0 {
+			return waited, nil
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return waited, err
+		}
+		waited += delay
+	}
+}
+
+// reserve takes a token from the class's bucket if one is available and
+// returns 0, or else returns how long to wait before trying again.
+func (l *RateLimiter) reserve(class EndpointClass) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b := l.bucket(class)
+	if now.Before(b.pausedUntil) {
+		return b.pausedUntil.Sub(now)
+	}
+	if b.limit == 0 {
+		return 0
+	}
+	l.refillLocked(b, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - b.tokens) / b.rate * float64(time.Second)))
+}
+
+// bucket returns the bucket for class, falling back to the read bucket
+// for unknown classes. l.mu must be held.
+func (l *RateLimiter) bucket(class EndpointClass) *bucket {
+	if b, ok := l.buckets[class]; ok {
+		return b
+	}
+	return l.buckets[EndpointClassRead]
+}
+
+// refillLocked adds the tokens earned since the bucket was last updated,
+// and lets a throttled rate recover towards the limit. l.mu must be held.
+func (l *RateLimiter) refillLocked(b *bucket, now time.Time) {
+	if b.updated.IsZero() {
+		b.updated = now
+		return
+	}
+	elapsed := now.Sub(b.updated)
+	if elapsed <= 0 {
+		return
+	}
+	b.updated = now
+
+	// Tokens are not earned while paused
+	if now.Before(b.pausedUntil) {
+		return
+	}
+	if !b.pausedUntil.IsZero() {
+		elapsed = min(elapsed, now.Sub(b.pausedUntil))
+	}
+
+	b.tokens = min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+	if b.rate < b.limit {
+		recovery := l.config.RecoveryPeriod
+		if recovery <= 0 {
+			recovery = DefaultRecoveryPeriod
+		}
+		b.rate = min(b.limit, b.rate+b.limit*(elapsed.Seconds()/recovery.Seconds()))
+	}
+}
+
+// throttled reports whether the outcome of an attempt means the server is
+// rate limiting the client.
+func (l *RateLimiter) throttled(statusCode int, err error) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	var apiErr *Error
+	return errors.As(err, &apiErr) && slices.Contains(l.config.ThrottleErrorCodes, apiErr.ErrorCode)
+}
+
+// observe records the outcome of an attempt, throttling the class if the
+// server asked the client to slow down. A nil limiter ignores it.
+func (l *RateLimiter) observe(class EndpointClass, statusCode int, retryAfter time.Duration, err error) {
+	if l == nil || !l.throttled(statusCode, err) {
+		return
+	}
+	l.Throttle(class, retryAfter)
+}
+
+// Throttle slows down the given class as if the server had throttled one
+// of its requests: the class is paused for pause (or ThrottlePause if pause
+// is zero), its rate is cut by ThrottleFactor, and its bucket is emptied.
+//
+// The client calls Throttle automatically. It is exported for callers that
+// learn about limits some other way, such as Postal's SendLimitApproaching
+// and SendLimitExceeded webhook events.
+func (l *RateLimiter) Throttle(class EndpointClass, pause time.Duration) {
+	if l == nil {
+		return
+	}
+	if pause <= 0 {
+		pause = l.config.ThrottlePause
+		if pause <= 0 {
+			pause = DefaultThrottlePause
+		}
+	}
+	factor := l.config.ThrottleFactor
+	if factor <= 0 || factor > 1 {
+		factor = DefaultThrottleFactor
+	}
+	minFraction := l.config.MinRateFraction
+	if minFraction <= 0 || minFraction > 1 {
+		minFraction = DefaultMinRateFraction
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b := l.bucket(class)
+	l.refillLocked(b, now)
+	b.rate = max(b.rate*factor, b.limit*minFraction)
+	b.tokens = 0
+	b.pausedUntil = maxTime(b.pausedUntil, now.Add(pause))
+	b.throttled++
+	b.lastThrottle = now
+}
+
+// maxTime returns the later of a and b.
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,287 @@
+package postalclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for rate limiter tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestRateLimiter returns a rate limiter driven by a fake clock.
+func newTestRateLimiter(config RateLimiterConfig) (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewRateLimiter(config)
+	l.now = clock.Now
+	return l, clock
+}
+
+func TestEndpointClass(t *testing.T) {
+	tests := map[string]EndpointClass{
+		"/send/message":        EndpointClassSend,
+		"/send/raw":            EndpointClassSend,
+		"/messages/message":    EndpointClassRead,
+		"/messages/deliveries": EndpointClassRead,
+	}
+	for path, want := range tests {
+		if got := endpointClass(path); got != want {
+			t.Errorf("Expected class of %s to be %s, got %s", path, want, got)
+		}
+	}
+}
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	l, clock := newTestRateLimiter(RateLimiterConfig{
+		Send: RateLimit{Rate: 10, Burst: 2},
+	})
+
+	// The burst is available immediately
+	for i := 0; i < 2; i++ {
+		if delay := l.reserve(EndpointClassSend); delay != 0 {
+			t.Fatalf("Expected request %d not to wait, got %v", i+1, delay)
+		}
+	}
+
+	// The next token arrives after 1/rate seconds
+	if delay := l.reserve(EndpointClassSend); delay != 100*time.Millisecond {
+		t.Errorf("Expected a 100ms wait, got %v", delay)
+	}
+
+	clock.Advance(50 * time.Millisecond)
+	if delay := l.reserve(EndpointClassSend); delay != 50*time.Millisecond {
+		t.Errorf("Expected a 50ms wait, got %v", delay)
+	}
+
+	clock.Advance(50 * time.Millisecond)
+	if delay := l.reserve(EndpointClassSend); delay != 0 {
+		t.Errorf("Expected no wait, got %v", delay)
+	}
+
+	// Tokens never exceed the burst
+	clock.Advance(time.Hour)
+	if tokens := l.State().Send.Tokens; tokens != 2 {
+		t.Errorf("Expected 2 tokens, got %v", tokens)
+	}
+
+	// The read class is unlimited
+	for i := 0; i < 100; i++ {
+		if delay := l.reserve(EndpointClassRead); delay != 0 {
+			t.Fatalf("Expected reads not to wait, got %v", delay)
+		}
+	}
+}
+
+func TestRateLimiterThrottle(t *testing.T) {
+	l, clock := newTestRateLimiter(RateLimiterConfig{
+		Send:           RateLimit{Rate: 10, Burst: 10},
+		Read:           RateLimit{Rate: 20, Burst: 20},
+		RecoveryPeriod: 10 * time.Second,
+	})
+
+	l.Throttle(EndpointClassSend, 2*time.Second)
+
+	state := l.State()
+	if state.Send.Rate != 5 || state.Send.Limit != 10 {
+		t.Errorf("Expected the send rate to be halved to 5 of 10, got %v of %v", state.Send.Rate, state.Send.Limit)
+	}
+
+	if state.Send.Tokens != 0 || state.Send.Throttled != 1 {
+		t.Errorf("Expected an empty bucket and 1 throttle, got %v tokens and %d throttles", state.Send.Tokens, state.Send.Throttled)
+	}
+
+	if want := clock.Now().Add(2 * time.Second); !state.Send.PausedUntil.Equal(want) {
+		t.Errorf("Expected the send class to be paused until %v, got %v", want, state.Send.PausedUntil)
+	}
+
+	// Other classes are unaffected
+	if state.Read.Rate != 20 || state.Read.Throttled != 0 || !state.Read.PausedUntil.IsZero() {
+		t.Errorf("Expected the read class to be unaffected, got %+v", state.Read)
+	}
+
+	// Requests wait for the pause to end
+	clock.Advance(500 * time.Millisecond)
+	if delay := l.reserve(EndpointClassSend); delay != 1500*time.Millisecond {
+		t.Errorf("Expected a 1.5s wait, got %v", delay)
+	}
+
+	// Repeated throttling stops at the minimum rate
+	for i := 0; i < 10; i++ {
+		l.Throttle(EndpointClassSend, 0)
+	}
+	if rate := l.State().Send.Rate; rate != 1 {
+		t.Errorf("Expected the rate to stop at 1, got %v", rate)
+	}
+
+	// The rate recovers linearly once the first, longer pause is over
+	clock.Advance(1500*time.Millisecond + 5*time.Second)
+	state = l.State()
+	if state.Send.Rate != 6 {
+		t.Errorf("Expected the rate to recover to 6, got %v", state.Send.Rate)
+	}
+
+	if !state.Send.PausedUntil.IsZero() {
+		t.Errorf("Expected the pause to be over, got %v", state.Send.PausedUntil)
+	}
+
+	clock.Advance(time.Minute)
+	if rate := l.State().Send.Rate; rate != 10 {
+		t.Errorf("Expected the rate to recover to 10, got %v", rate)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(RateLimiterConfig{Send: RateLimit{Rate: 100, Burst: 1}})
+
+	if waited, err := l.Wait(context.Background(), EndpointClassSend); err != nil || waited != 0 {
+		t.Errorf("Expected no wait, got %v (%v)", waited, err)
+	}
+
+	waited, err := l.Wait(context.Background(), EndpointClassSend)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if waited <= 0 || waited > 50*time.Millisecond {
+		t.Errorf("Expected to wait about 10ms, got %v", waited)
+	}
+
+	// Cancellation ends the wait
+	l.Throttle(EndpointClassSend, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Wait(ctx, EndpointClassSend); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// A nil limiter does nothing
+	var nilLimiter *RateLimiter
+	if waited, err := nilLimiter.Wait(ctx, EndpointClassSend); err != nil || waited != 0 {
+		t.Errorf("Expected a nil limiter not to wait, got %v (%v)", waited, err)
+	}
+
+	nilLimiter.Throttle(EndpointClassSend, time.Second)
+	if state := nilLimiter.State(); state != (RateLimiterState{}) {
+		t.Errorf("Expected a zero state, got %+v", state)
+	}
+}
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	l := NewRateLimiter(RateLimiterConfig{Send: RateLimit{Rate: 200, Burst: 5}})
+
+	// 25 requests at 200/s with a burst of 5 take at least 100ms
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := l.Wait(context.Background(), EndpointClassSend); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected the requests to take at least 100ms, took %v", elapsed)
+	}
+}
+
+func TestClientRateLimiterBacksOffOn429(t *testing.T) {
+	// Create a test server that throttles the first send
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/send/message" && atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"status":"error","message":"slow down"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":{}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	client.RetryPolicy = fastRetryPolicy(2)
+	client.RateLimiter = NewRateLimiter(RateLimiterConfig{
+		Send:          RateLimit{Rate: 1000, Burst: 10},
+		ThrottlePause: 50 * time.Millisecond,
+	})
+
+	start := time.Now()
+	if _, err := client.post("/send/message", map[string]string{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The retry waited for the pause, not just the retry backoff
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the retry to wait for the pause, took %v", elapsed)
+	}
+
+	state := client.RateLimiter.State()
+	if state.Send.Throttled != 1 || state.Send.Rate >= 1000 {
+		t.Errorf("Expected the send class to be throttled, got %+v", state.Send)
+	}
+
+	if state.Read.Throttled != 0 {
+		t.Errorf("Expected the read class not to be throttled, got %+v", state.Read)
+	}
+}
+
+func TestClientRateLimiterThrottleErrorCodes(t *testing.T) {
+	// Create a test server that returns a Postal error with HTTP 200
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"error","data":{"code":"TooManyRequests","message":"Slow down"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	client.RateLimiter = NewRateLimiter(RateLimiterConfig{
+		ThrottleErrorCodes: []string{"TooManyRequests"},
+	})
+
+	before := time.Now()
+	if _, err := client.post("/messages/message", map[string]int{"id": 1}); err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+
+	// The server's Retry-After sets the pause
+	state := client.RateLimiter.State()
+	if state.Read.Throttled != 1 || state.Read.PausedUntil.Before(before.Add(2*time.Second)) {
+		t.Errorf("Expected the read class to be paused for 3s, got %+v", state.Read)
+	}
+
+	// Requests made while paused give up with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.postContext(ctx, "/messages/message", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
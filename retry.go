@@ -0,0 +1,259 @@
+// This file contains the retry policy used by the client to recover from
+// transient failures such as dropped connections, gateway errors, and
+// timeouts reported by the Postal server or a proxy in front of it.
+package postalclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the default number of attempts (including the
+	// first one) made by DefaultRetryPolicy.
+	DefaultMaxAttempts = 3
+
+	// DefaultInitialBackoff is the default delay before the first retry.
+	DefaultInitialBackoff = 200 * time.Millisecond
+
+	// DefaultMaxBackoff is the default upper bound for a single backoff delay.
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// RetryPolicy controls how the client retries requests that fail with a
+// transient error.
+//
+// A nil *RetryPolicy (the default for a new Client) disables retries, so
+// every request is attempted exactly once.
+//
+// Note that Postal has no idempotency keys: retrying a send after the server
+// has accepted it but before the response reached the client can deliver
+// the same message twice. Only enable retries for sends where that risk is
+// acceptable.
+//
+// Example:
+//
+//	client := postalclient.NewClient("your-api-key")
+//	client.RetryPolicy = &postalclient.RetryPolicy{
+//	    MaxAttempts:    5,
+//	    InitialBackoff: 500 * time.Millisecond,
+//	    MaxBackoff:     10 * time.Second,
+//	    Jitter:         0.5,
+//	    OnAttempt: func(a postalclient.RetryAttempt) {
+//	        log.Printf("attempt %d for %s: %v", a.Attempt, a.Path, a.Err)
+//	    },
+//	}
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first
+	// one. A value of 0 or 1 disables retries.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	// If zero, DefaultInitialBackoff is used.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the computed backoff delay for a single retry.
+	// If zero, DefaultMaxBackoff is used. A Retry-After header sent by the
+	// server is honored even if it exceeds MaxBackoff.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor by which the backoff grows after each retry.
+	// If zero, a multiplier of 2 is used.
+	Multiplier float64
+
+	// Jitter is the fraction (between 0 and 1) of each backoff delay that is
+	// randomized. A value of 0.5 means each delay is picked uniformly from
+	// [delay/2, delay]. Jitter spreads retries from many clients over time.
+	Jitter float64
+
+	// RetryableStatusCodes lists the HTTP status codes that are retried.
+	// If nil, 429, 502, 503, and 504 are retried.
+	RetryableStatusCodes []int
+
+	// RetryableErrorCodes lists the Postal Error.ErrorCode values that are
+	// retried even though the server returned a well-formed error response.
+	// Optional.
+	RetryableErrorCodes []string
+
+	// DisableNetworkRetries disables retrying network errors such as
+	// refused connections, resets, timeouts, and response bodies cut short,
+	// whatever the status code. By default they are retried.
+	DisableNetworkRetries bool
+
+	// ShouldRetry, if set, overrides the built-in classification. It is
+	// called with the attempt that just failed and reports whether it
+	// should be retried. MaxAttempts and context cancellation still apply.
+	ShouldRetry func(attempt RetryAttempt) bool
+
+	// OnAttempt, if set, is called after every attempt, successful or not.
+	// It must not block for long, since it runs on the request path.
+	OnAttempt func(attempt RetryAttempt)
+}
+
+// RetryAttempt describes a single attempt made by the client.
+// It is passed to the RetryPolicy's ShouldRetry and OnAttempt hooks.
+type RetryAttempt struct {
+	// Attempt is the 1-based number of this attempt.
+	Attempt int
+
+	// Method is the HTTP method of the request.
+	Method string
+
+	// Path is the API path of the request, e.g. "/send/message".
+	Path string
+
+	// StatusCode is the HTTP status code of the response, or 0 if no
+	// response was received.
+	StatusCode int
+
+	// Err is the error returned by this attempt, or nil if it succeeded.
+	Err error
+
+	// Retry reports whether another attempt will be made.
+	// It is always false when passed to ShouldRetry.
+	Retry bool
+
+	// Delay is how long the client will wait before the next attempt.
+	// It is zero if Retry is false.
+	Delay time.Duration
+
+	// Duration is how long this attempt took.
+	Duration time.Duration
+}
+
+// DefaultRetryPolicy returns a RetryPolicy with sensible defaults: three
+// attempts, exponential backoff starting at 200ms capped at 5s with 50%
+// jitter, retrying network errors and 429/502/503/504 responses.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    DefaultMaxAttempts,
+		InitialBackoff: DefaultInitialBackoff,
+		MaxBackoff:     DefaultMaxBackoff,
+		Multiplier:     2,
+		Jitter:         0.5,
+	}
+}
+
+// defaultRetryableStatusCodes are the HTTP status codes retried when
+// RetryPolicy.RetryableStatusCodes is nil.
+var defaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// maxAttempts returns the effective number of attempts for the policy.
+// A nil policy always makes exactly one attempt.
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// retryable reports whether the given failed attempt should be retried,
+// ignoring the attempt limit.
+func (p *RetryPolicy) retryable(ctx context.Context, a RetryAttempt) bool {
+	// Never retry once the caller has given up
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if p.ShouldRetry != nil {
+		return p.ShouldRetry(a)
+	}
+
+	// Postal reported a well-formed error; only retry listed codes
+	var apiErr *Error
+	if errors.As(a.Err, &apiErr) && slices.Contains(p.RetryableErrorCodes, apiErr.ErrorCode) {
+		return true
+	}
+
+	// No complete response means the request failed on the network, even
+	// if the status line arrived before the body was cut short
+	var transportErr *TransportError
+	if a.StatusCode == 0 || errors.As(a.Err, &transportErr) {
+		return !p.DisableNetworkRetries
+	}
+
+	codes := p.RetryableStatusCodes
+	if codes == nil {
+		codes = defaultRetryableStatusCodes
+	}
+	return slices.Contains(codes, a.StatusCode)
+}
+
+// backoff returns the delay before the retry following the given attempt.
+// The server's Retry-After value takes precedence when it is longer than
+// the computed delay.
+func (p *RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = DefaultInitialBackoff
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	// Grow the delay exponentially and cap it
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if delay > float64(maxBackoff) {
+		delay = float64(maxBackoff)
+	}
+
+	// Randomize the configured fraction of the delay
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		delay -= delay * jitter * rand.Float64()
+	}
+
+	return max(time.Duration(delay), retryAfter)
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which may be
+// either a number of seconds or an HTTP date. It returns 0 if the header is
+// missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+// It returns ctx.Err() if the context ended the wait.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
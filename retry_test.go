@@ -0,0 +1,400 @@
+package postalclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetryPolicy returns a retry policy with tiny delays for tests.
+func fastRetryPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}
+}
+
+func TestRetryOnServiceUnavailable(t *testing.T) {
+	// Create a test server that fails twice before succeeding
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"status":"error","message":"unavailable"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":{}}`))
+	}))
+	defer server.Close()
+
+	// Create client with a retry policy that records every attempt
+	var attempts []RetryAttempt
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	client.RetryPolicy = fastRetryPolicy(3)
+	client.RetryPolicy.OnAttempt = func(a RetryAttempt) {
+		attempts = append(attempts, a)
+	}
+
+	// Make request
+	resp, err := client.post("/test", map[string]string{"test": "data"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if resp.Status != "success" {
+		t.Errorf("Expected response status to be success, got %s", resp.Status)
+	}
+
+	// Check attempts
+	if len(attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(attempts))
+	}
+
+	for i, a := range attempts[:2] {
+		if a.Attempt != i+1 {
+			t.Errorf("Expected attempt number to be %d, got %d", i+1, a.Attempt)
+		}
+		if a.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected attempt status code to be 503, got %d", a.StatusCode)
+		}
+		if !a.Retry {
+			t.Errorf("Expected attempt %d to be retried", a.Attempt)
+		}
+	}
+
+	if last := attempts[2]; last.Err != nil || last.Retry {
+		t.Errorf("Expected final attempt to succeed without retry, got %+v", last)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	// Create a test server that always fails
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(`{"status":"error","message":"bad gateway"}`))
+	}))
+	defer server.Close()
+
+	// Create client
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	client.RetryPolicy = fastRetryPolicy(4)
+
+	// Make request
+	_, err := client.post("/test", nil)
+
+	// Check the last error is returned with its status code
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected error to be of type *Error, got %T", err)
+	}
+
+	if apiErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected error status code to be 502, got %d", apiErr.StatusCode)
+	}
+
+	if calls != 4 {
+		t.Errorf("Expected 4 calls, got %d", calls)
+	}
+}
+
+func TestRetryDoesNotRetryPermanentErrors(t *testing.T) {
+	// Create a test server that returns a non-retryable error
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"error","error_code":"NoRecipients","message":"no recipients"}`))
+	}))
+	defer server.Close()
+
+	// Create client
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	client.RetryPolicy = fastRetryPolicy(3)
+
+	// Make request
+	if _, err := client.post("/test", nil); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryOnErrorCode(t *testing.T) {
+	// Create a test server that returns a retryable Postal error once
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			_, _ = w.Write([]byte(`{"status":"error","error_code":"ServerBusy","message":"busy"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{}}`))
+	}))
+	defer server.Close()
+
+	// Create client
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	client.RetryPolicy = fastRetryPolicy(3)
+	client.RetryPolicy.RetryableErrorCodes = []string{"ServerBusy"}
+
+	// Make request
+	if _, err := client.post("/test", nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
+
+func TestRetryOnNetworkError(t *testing.T) {
+	// Create client with a transport that always fails
+	var attempts int
+	client := NewClient("test-api-key")
+	client.HTTPClient = &http.Client{Transport: &mockTransport{}}
+	client.RetryPolicy = fastRetryPolicy(3)
+	client.RetryPolicy.OnAttempt = func(a RetryAttempt) {
+		attempts++
+	}
+
+	// Make request
+	if _, err := client.post("/test", nil); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	// Network retries can be disabled
+	attempts = 0
+	client.RetryPolicy.DisableNetworkRetries = true
+	if _, err := client.post("/test", nil); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestRetryOnTruncatedBody(t *testing.T) {
+	// Create a test server that cuts the first response body short
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Content-Length", "100")
+			_, _ = w.Write([]byte(`{"status":`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":{}}`))
+	}))
+	defer server.Close()
+
+	var statuses []int
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	client.RetryPolicy = fastRetryPolicy(2)
+	client.RetryPolicy.OnAttempt = func(a RetryAttempt) {
+		statuses = append(statuses, a.StatusCode)
+	}
+
+	if _, err := client.post("/test", nil); err != nil {
+		t.Fatalf("Expected the truncated response to be retried, got %v", err)
+	}
+
+	if len(statuses) != 2 || statuses[0] != http.StatusOK {
+		t.Errorf("Expected 2 attempts, the first with status 200, got %v", statuses)
+	}
+}
+
+func TestRetryShouldRetryOverride(t *testing.T) {
+	// Create a test server that always returns 500
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"status":"error"}`))
+	}))
+	defer server.Close()
+
+	// Create client that retries anything with a status code
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	client.RetryPolicy = fastRetryPolicy(2)
+	client.RetryPolicy.ShouldRetry = func(a RetryAttempt) bool {
+		return a.StatusCode == http.StatusInternalServerError
+	}
+
+	// Make request
+	_, _ = client.post("/test", nil)
+
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	// Create a test server that asks the client to wait
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"status":"error"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{}}`))
+	}))
+	defer server.Close()
+
+	// Create client
+	var delay time.Duration
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	client.RetryPolicy = fastRetryPolicy(2)
+	client.RetryPolicy.OnAttempt = func(a RetryAttempt) {
+		if a.Retry {
+			delay = a.Delay
+		}
+	}
+
+	// Make request
+	if _, err := client.post("/test", nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if delay != time.Second {
+		t.Errorf("Expected delay to be 1s, got %s", delay)
+	}
+}
+
+func TestRetryStopsWhenContextEnds(t *testing.T) {
+	// Create a test server that always fails
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"status":"error"}`))
+	}))
+	defer server.Close()
+
+	// Create client with a long backoff
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Make request
+	_, err := client.postContext(ctx, "/test", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error to wrap context.DeadlineExceeded, got '%v'", err)
+	}
+
+	// The last API error is kept alongside the context error
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Errorf("Expected error to wrap *Error, got '%v'", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+
+	// Without jitter the backoff grows exponentially up to the cap
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+	}
+	for i, want := range expected {
+		if got := policy.backoff(i+1, 0); got != want {
+			t.Errorf("Expected backoff for attempt %d to be %s, got %s", i+1, want, got)
+		}
+	}
+
+	// With jitter the backoff stays within [delay*(1-jitter), delay]
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		got := policy.backoff(2, 0)
+		if got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("Expected jittered backoff within [100ms, 200ms], got %s", got)
+		}
+	}
+
+	// Retry-After wins when it is longer than the computed delay
+	if got := policy.backoff(1, 3*time.Second); got != 3*time.Second {
+		t.Errorf("Expected backoff to honor Retry-After, got %s", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{"garbage", 0},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second},
+		{now.Add(-10 * time.Second).Format(http.TimeFormat), 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.expected {
+			t.Errorf("Expected parseRetryAfter(%q) to be %s, got %s", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestNilRetryPolicyMakesOneAttempt(t *testing.T) {
+	// Create a test server that always fails with a retryable status
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"status":"error"}`))
+	}))
+	defer server.Close()
+
+	// Create client without a retry policy
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+
+	// Make request
+	_, _ = client.post("/test", nil)
+
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
@@ -0,0 +1,137 @@
+// This file contains Render and Fill, which execute a message's templates.
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// RenderOption configures Render and Fill.
+type RenderOption func(*renderOptions)
+
+// renderOptions holds the settings collected from RenderOptions.
+type renderOptions struct {
+	locale string
+}
+
+// WithLocale renders the variants for locale, e.g. "fr-CA", falling back
+// to less specific ones and then to the default. Locales are matched
+// regardless of case, and "_" is treated as "-".
+func WithLocale(locale string) RenderOption {
+	return func(o *renderOptions) {
+		o.locale = locale
+	}
+}
+
+// Message is the output of Render.
+type Message struct {
+	// Subject is the rendered subject, with runs of white space, including
+	// line breaks, collapsed to single spaces. It is empty if the message
+	// has no subject template.
+	Subject string
+
+	// PlainBody is the rendered plain text body, or empty if the message
+	// has no body.txt.
+	PlainBody string
+
+	// HTMLBody is the rendered HTML body, or empty if the message has no
+	// body.html.
+	HTMLBody string
+}
+
+// Render executes the templates of the named message with data. It fails
+// with ErrNotFound for an unknown name, and with the template's error if
+// data is missing a variable the templates use.
+func (s *Set) Render(name string, data any, opts ...RenderOption) (*Message, error) {
+	m, ok := s.messages[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	o := &renderOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	locales := localeChain(o.locale)
+
+	var out Message
+	var buf bytes.Buffer
+	if t, ok := lookup(m.subject, locales); ok {
+		if err := t.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("error rendering %s subject: %w", name, err)
+		}
+		out.Subject = strings.Join(strings.Fields(buf.String()), " ")
+		buf.Reset()
+	}
+	if t, ok := lookup(m.text, locales); ok {
+		if err := t.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("error rendering %s plain text body: %w", name, err)
+		}
+		out.PlainBody = buf.String()
+		buf.Reset()
+	}
+	if t, ok := lookup(m.html, locales); ok {
+		if err := t.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("error rendering %s HTML body: %w", name, err)
+		}
+		out.HTMLBody = buf.String()
+	}
+	return &out, nil
+}
+
+// Fill renders the named message with data, like Render, and sets the
+// Subject, PlainBody, and HTMLBody of req to the parts the message has
+// templates for. Other fields of req are left alone. On error, req is not
+// changed.
+func (s *Set) Fill(req *models.SendMessageRequest, name string, data any, opts ...RenderOption) error {
+	out, err := s.Render(name, data, opts...)
+	if err != nil {
+		return err
+	}
+
+	m := s.messages[name]
+	if len(m.subject) > 0 {
+		req.Subject = out.Subject
+	}
+	if len(m.text) > 0 {
+		req.PlainBody = out.PlainBody
+	}
+	if len(m.html) > 0 {
+		req.HTMLBody = out.HTMLBody
+	}
+	return nil
+}
+
+// lookup returns the template for the first of locales that has one.
+func lookup[T any](templates map[string]T, locales []string) (T, bool) {
+	for _, locale := range locales {
+		if t, ok := templates[locale]; ok {
+			return t, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// localeChain returns locale and its less specific forms, ending with the
+// default "", e.g. ["fr-ca", "fr", ""] for "fr-CA".
+func localeChain(locale string) []string {
+	locale = normalizeLocale(locale)
+	var chain []string
+	for locale != "" {
+		chain = append(chain, locale)
+		i := strings.LastIndexByte(locale, '-')
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return append(chain, "")
+}
+
+// normalizeLocale lower-cases locale and replaces "_" with "-".
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
@@ -0,0 +1,135 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// welcomeData is the data used to render the welcome message.
+var welcomeData = map[string]any{"Name": "Ada <3", "Company": "Acme"}
+
+// loadTestSet loads testFS.
+func loadTestSet(t *testing.T) *Set {
+	t.Helper()
+	set, err := Load(testFS())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return set
+}
+
+func TestRender(t *testing.T) {
+	set := loadTestSet(t)
+
+	out, err := set.Render("welcome", welcomeData)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Subject != "Welcome to Acme, Ada <3!" {
+		t.Errorf("Expected the subject on one line, got %q", out.Subject)
+	}
+
+	if out.PlainBody != "Hello Ada <3\n-- \nThe Acme team" {
+		t.Errorf("Expected the plain body in the text layout, got %q", out.PlainBody)
+	}
+
+	if out.HTMLBody != "<html><body><p>Hello Ada &lt;3</p><footer>Acme</footer></body></html>" {
+		t.Errorf("Expected the escaped HTML body in the HTML layout, got %q", out.HTMLBody)
+	}
+}
+
+func TestRenderLocale(t *testing.T) {
+	set := loadTestSet(t)
+
+	for _, locale := range []string{"fr", "fr-CA", "FR_ca"} {
+		out, err := set.Render("welcome", welcomeData, WithLocale(locale))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		// Parts without a French variant use the default
+		if !strings.HasPrefix(out.Subject, "Bienvenue") || !strings.Contains(out.HTMLBody, "Bonjour") || !strings.HasPrefix(out.PlainBody, "Hello") {
+			t.Errorf("Expected the French variants for %s, got %+v", locale, out)
+		}
+	}
+
+	out, err := set.Render("welcome", welcomeData, WithLocale("de-DE"))
+	if err != nil || !strings.HasPrefix(out.Subject, "Welcome") {
+		t.Errorf("Expected the default variants for de-DE, got %+v and %v", out, err)
+	}
+}
+
+func TestRenderMissingVariable(t *testing.T) {
+	set := loadTestSet(t)
+
+	_, err := set.Render("welcome", map[string]any{"Name": "Ada"})
+	if err == nil || !strings.Contains(err.Error(), `map has no entry for key "Company"`) {
+		t.Errorf("Expected an error for the missing Company, got %v", err)
+	}
+
+	_, err = set.Render("reset", struct{ Name string }{"Ada"})
+	if err == nil || !strings.Contains(err.Error(), "Link") {
+		t.Errorf("Expected an error for the missing Link field, got %v", err)
+	}
+}
+
+func TestRenderNotFound(t *testing.T) {
+	set := loadTestSet(t)
+
+	if _, err := set.Render("goodbye", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRenderConcurrent(t *testing.T) {
+	set := loadTestSet(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := map[string]any{"Name": fmt.Sprint(i), "Company": "Acme"}
+			out, err := set.Render("welcome", data, WithLocale("fr"))
+			if err != nil || !strings.Contains(out.HTMLBody, "Bonjour "+fmt.Sprint(i)) {
+				t.Errorf("Expected the message for %d, got %+v and %v", i, out, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFill(t *testing.T) {
+	set := loadTestSet(t)
+
+	req := &models.SendMessageRequest{From: "hello@example.com", To: []string{"ada@example.org"}, Subject: "Keep me", HTMLBody: "Keep me"}
+	if err := set.Fill(req, "reset", map[string]any{"Link": "https://example.com/r"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Only the parts the message has templates for are set
+	if req.PlainBody != "Reset your password: https://example.com/r" || req.Subject != "Keep me" || req.HTMLBody != "Keep me" {
+		t.Errorf("Expected only the plain body to be set, got %+v", req)
+	}
+
+	if err := set.Fill(req, "welcome", welcomeData); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if req.Subject != "Welcome to Acme, Ada <3!" || !strings.Contains(req.HTMLBody, "<p>Hello") || req.From != "hello@example.com" {
+		t.Errorf("Expected every part to be set, got %+v", req)
+	}
+
+	// A failed render leaves the request alone
+	if err := set.Fill(req, "reset", nil); err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+	if !strings.HasPrefix(req.PlainBody, "Hello") {
+		t.Errorf("Expected the request to be unchanged, got %q", req.PlainBody)
+	}
+}
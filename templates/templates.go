@@ -0,0 +1,262 @@
+// Package templates renders email subjects and bodies from templates
+// loaded from an fs.FS, using text/template for subjects and plain text
+// bodies and html/template for HTML bodies.
+//
+// Each message is a directory holding its templates. Shared layouts and
+// partials live in the layouts and partials directories and can be used by
+// every message:
+//
+//	layouts/base.html      shared HTML layout
+//	layouts/base.txt       shared plain text layout
+//	partials/footer.html   shared HTML partial
+//	welcome/subject.txt    subject of the "welcome" message
+//	welcome/body.txt       plain text body
+//	welcome/body.html      HTML body
+//	welcome/subject.fr.txt French subject
+//	welcome/body.fr.html   French HTML body
+//
+// Shared templates are named after their file, e.g. "base.html", and are
+// available to the templates with the same extension; subjects see the
+// .txt ones. A body uses a layout by defining the blocks the layout
+// declares and then invoking it:
+//
+//	{{define "content"}}<p>Hello {{.Name}}</p>{{end}}
+//	{{template "base.html" .}}
+//
+// Locale variants add the locale before the extension. When rendering for
+// a locale such as "fr-CA", each part uses the most specific variant
+// available: "fr-CA", then "fr", then the default.
+//
+// Templates are executed with the missingkey=error option, so a variable
+// missing from the data fails the render instead of producing an email
+// with a blank where a value should be.
+//
+// Basic usage:
+//
+//	set, err := templates.Load(os.DirFS("emails"))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	req := &models.SendMessageRequest{From: "hello@yourdomain.com", To: []string{user.Email}}
+//	if err := set.Fill(req, "welcome", user, templates.WithLocale(user.Locale)); err != nil {
+//	    return err
+//	}
+//	resp, err := client.SendMessage(req)
+package templates
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Directories holding the templates shared by every message.
+const (
+	LayoutsDir  = "layouts"
+	PartialsDir = "partials"
+)
+
+// ErrNotFound is returned when rendering a message that isn't in the Set.
+var ErrNotFound = errors.New("templates: message not found")
+
+// Option configures Load.
+type Option func(*loadOptions)
+
+// loadOptions holds the settings collected from Options.
+type loadOptions struct {
+	funcs map[string]any
+}
+
+// WithFuncs adds functions that the templates can call, as with
+// text/template's Funcs. It may be used more than once.
+func WithFuncs(funcs map[string]any) Option {
+	return func(o *loadOptions) {
+		for name, fn := range funcs {
+			o.funcs[name] = fn
+		}
+	}
+}
+
+// Set holds the message templates loaded by Load. It is safe for
+// concurrent use.
+type Set struct {
+	messages map[string]*message
+}
+
+// message holds the templates of one message, by part and locale. The
+// default variant has the locale "".
+type message struct {
+	subject map[string]*texttemplate.Template
+	text    map[string]*texttemplate.Template
+	html    map[string]*htmltemplate.Template
+}
+
+// Load parses the templates in fsys. Every directory other than LayoutsDir
+// and PartialsDir is a message named after it, whose subject.txt,
+// body.txt, and body.html files (and their locale variants) are its
+// templates. Other files are ignored. A message needs at least one body.
+func Load(fsys fs.FS, opts ...Option) (*Set, error) {
+	o := &loadOptions{funcs: make(map[string]any)}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	textBase := texttemplate.New("").Funcs(o.funcs).Option("missingkey=error")
+	htmlBase := htmltemplate.New("").Funcs(o.funcs).Option("missingkey=error")
+	for _, dir := range []string{LayoutsDir, PartialsDir} {
+		if err := parseShared(fsys, dir, textBase, htmlBase); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("error reading templates: %w", err)
+	}
+	set := &Set{messages: make(map[string]*message)}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || name == LayoutsDir || name == PartialsDir {
+			continue
+		}
+		m, err := loadMessage(fsys, name, textBase, htmlBase)
+		if err != nil {
+			return nil, err
+		}
+		set.messages[name] = m
+	}
+	return set, nil
+}
+
+// Names returns the names of the messages in the set, sorted.
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.messages))
+	for name := range s.messages {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// parseShared adds the .txt and .html files in dir to textBase and
+// htmlBase. A missing dir is not an error.
+func parseShared(fsys fs.FS, dir string, textBase *texttemplate.Template, htmlBase *htmltemplate.Template) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		file := path.Join(dir, name)
+		src, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", file, err)
+		}
+
+		switch path.Ext(name) {
+		case ".txt":
+			if textBase.Lookup(name) != nil {
+				return fmt.Errorf("error parsing %s: %s is defined more than once", file, name)
+			}
+			_, err = textBase.New(name).Parse(string(src))
+		case ".html":
+			if htmlBase.Lookup(name) != nil {
+				return fmt.Errorf("error parsing %s: %s is defined more than once", file, name)
+			}
+			_, err = htmlBase.New(name).Parse(string(src))
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// loadMessage parses the templates of the message in dir, each in a copy
+// of the shared templates.
+func loadMessage(fsys fs.FS, dir string, textBase *texttemplate.Template, htmlBase *htmltemplate.Template) (*message, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", dir, err)
+	}
+
+	m := &message{
+		subject: make(map[string]*texttemplate.Template),
+		text:    make(map[string]*texttemplate.Template),
+		html:    make(map[string]*htmltemplate.Template),
+	}
+	for _, entry := range entries {
+		part, locale, ext, ok := parseFileName(entry.Name())
+		if entry.IsDir() || !ok {
+			continue
+		}
+		file := path.Join(dir, entry.Name())
+		src, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", file, err)
+		}
+
+		switch {
+		case part == "subject" && ext == ".txt":
+			m.subject[locale], err = parseText(textBase, entry.Name(), src)
+		case part == "body" && ext == ".txt":
+			m.text[locale], err = parseText(textBase, entry.Name(), src)
+		case part == "body" && ext == ".html":
+			m.html[locale], err = parseHTML(htmlBase, entry.Name(), src)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", file, err)
+		}
+	}
+
+	if len(m.text) == 0 && len(m.html) == 0 {
+		return nil, fmt.Errorf("error loading %s: no body.txt or body.html", dir)
+	}
+	return m, nil
+}
+
+// parseText parses src as the template name in a copy of base.
+func parseText(base *texttemplate.Template, name string, src []byte) (*texttemplate.Template, error) {
+	t, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return t.New(name).Parse(string(src))
+}
+
+// parseHTML parses src as the template name in a copy of base.
+func parseHTML(base *htmltemplate.Template, name string, src []byte) (*htmltemplate.Template, error) {
+	t, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return t.New(name).Parse(string(src))
+}
+
+// parseFileName splits a message file name such as "body.fr-CA.html" into
+// its part, normalized locale, and extension. It reports false for names
+// that aren't of that form.
+func parseFileName(name string) (part, locale, ext string, ok bool) {
+	ext = path.Ext(name)
+	part, locale, _ = strings.Cut(strings.TrimSuffix(name, ext), ".")
+	if part != "subject" && part != "body" {
+		return "", "", "", false
+	}
+	return part, normalizeLocale(locale), ext, true
+}
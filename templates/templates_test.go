@@ -0,0 +1,106 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testFS returns a file system with a layout, a partial, and two messages,
+// one of them with French variants.
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.html":      {Data: []byte(`<html><body>{{block "content" .}}{{end}}{{template "footer.html" .}}</body></html>`)},
+		"layouts/base.txt":       {Data: []byte("{{block \"content\" .}}{{end}}\n-- \n{{template \"signature.txt\" .}}")},
+		"partials/footer.html":   {Data: []byte(`<footer>{{.Company}}</footer>`)},
+		"partials/signature.txt": {Data: []byte(`The {{.Company}} team`)},
+		"welcome/subject.txt":    {Data: []byte("Welcome to {{.Company}},\n  {{.Name}}!\n")},
+		"welcome/subject.fr.txt": {Data: []byte(`Bienvenue chez {{.Company}}, {{.Name}} !`)},
+		"welcome/body.txt":       {Data: []byte(`{{define "content"}}Hello {{.Name}}{{end}}{{template "base.txt" .}}`)},
+		"welcome/body.html":      {Data: []byte(`{{define "content"}}<p>Hello {{.Name}}</p>{{end}}{{template "base.html" .}}`)},
+		"welcome/body.fr.html":   {Data: []byte(`{{define "content"}}<p>Bonjour {{.Name}}</p>{{end}}{{template "base.html" .}}`)},
+		"welcome/README.md":      {Data: []byte(`Not a template`)},
+		"reset/body.txt":         {Data: []byte(`Reset your password: {{.Link}}`)},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	set, err := Load(testFS())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if names := set.Names(); fmt.Sprint(names) != "[reset welcome]" {
+		t.Errorf("Expected the messages [reset welcome], got %v", names)
+	}
+
+	m := set.messages["welcome"]
+	if len(m.subject) != 2 || len(m.text) != 1 || len(m.html) != 2 {
+		t.Errorf("Expected 2 subjects, 1 text body, and 2 HTML bodies, got %d, %d, and %d", len(m.subject), len(m.text), len(m.html))
+	}
+	if m.html["fr"] == nil {
+		t.Error("Expected a French HTML body")
+	}
+}
+
+func TestLoadFuncs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"shout/body.txt": {Data: []byte(`{{upper .}}`)},
+	}
+	set, err := Load(fsys, WithFuncs(map[string]any{"upper": strings.ToUpper}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	out, err := set.Render("shout", "hi")
+	if err != nil || out.PlainBody != "HI" {
+		t.Errorf("Expected HI, got %q and %v", out.PlainBody, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"no body", fstest.MapFS{"welcome/subject.txt": {Data: []byte(`Hi`)}}, "welcome: no body.txt or body.html"},
+		{"syntax error", fstest.MapFS{"welcome/body.html": {Data: []byte(`{{.Name`)}}, "error parsing welcome/body.html"},
+		{"shared syntax error", fstest.MapFS{"partials/footer.txt": {Data: []byte(`{{end}}`)}}, "error parsing partials/footer.txt"},
+		{"duplicate shared", fstest.MapFS{
+			"layouts/base.txt":  {Data: []byte(`a`)},
+			"partials/base.txt": {Data: []byte(`b`)},
+		}, "base.txt is defined more than once"},
+		{"unknown function", fstest.MapFS{"welcome/body.txt": {Data: []byte(`{{upper .}}`)}}, `function "upper" not defined`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseFileName(t *testing.T) {
+	tests := []struct {
+		name              string
+		part, locale, ext string
+		ok                bool
+	}{
+		{"subject.txt", "subject", "", ".txt", true},
+		{"body.html", "body", "", ".html", true},
+		{"body.fr_CA.html", "body", "fr-ca", ".html", true},
+		{"README.md", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		part, locale, ext, ok := parseFileName(tt.name)
+		if part != tt.part || locale != tt.locale || ext != tt.ext || ok != tt.ok {
+			t.Errorf("Expected %s to parse as %q %q %q %v, got %q %q %q %v", tt.name, tt.part, tt.locale, tt.ext, tt.ok, part, locale, ext, ok)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// This file contains WaitForDelivery, WaitForDeliveries, and
+// WatchDeliveries, which poll the deliveries of messages until each is
+// sent, fails, or bounces.
+package postalclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+const (
+	// DefaultWatchInterval is the default delay before WatchDeliveries
+	// polls a message again after finding a new delivery.
+	DefaultWatchInterval = time.Second
+
+	// DefaultWatchMaxInterval is the default upper bound for the delay
+	// between polls, which doubles while nothing changes.
+	DefaultWatchMaxInterval = 30 * time.Second
+)
+
+// WatchOption configures WaitForDelivery and WatchDeliveries.
+type WatchOption func(*watchOptions)
+
+// watchOptions holds the settings collected from WatchOptions.
+type watchOptions struct {
+	interval    time.Duration
+	maxInterval time.Duration
+}
+
+// WithWatchInterval sets the delay after the first poll and after each
+// poll that found a new delivery, and the upper bound the delay doubles up
+// to after each later poll that found none. The defaults are
+// DefaultWatchInterval and DefaultWatchMaxInterval.
+func WithWatchInterval(initial, max time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		if initial > 0 {
+			o.interval = initial
+		}
+		if max > 0 {
+			o.maxInterval = max
+		}
+	}
+}
+
+// DeliveryEvent is sent by WatchDeliveries for each new delivery attempt,
+// or when watching fails.
+type DeliveryEvent struct {
+	// MessageID is the ID of the message the event is about.
+	MessageID int
+
+	// Delivery is the new delivery attempt. It is zero if Err is set.
+	Delivery models.Delivery
+
+	// Terminal is true if the message will not be delivered again after
+	// this attempt: it was sent, failed permanently, or bounced. It is the
+	// last event for the message.
+	Terminal bool
+
+	// Err is the error that ended the watch, if any. It is the last event.
+	Err error
+}
+
+// DeliveryResult is returned by WaitForDelivery and WaitForDeliveries for
+// each message.
+type DeliveryResult struct {
+	// MessageID is the ID of the message.
+	MessageID int
+
+	// Deliveries holds the delivery attempts seen, in order.
+	Deliveries []models.Delivery
+}
+
+// Final returns the last delivery attempt seen and whether it ended
+// delivery of the message.
+func (r *DeliveryResult) Final() (models.Delivery, bool) {
+	if len(r.Deliveries) == 0 {
+		return models.Delivery{}, false
+	}
+	last := r.Deliveries[len(r.Deliveries)-1]
+	return last, last.Status.IsTerminal()
+}
+
+// Delivered reports whether the message was sent.
+func (r *DeliveryResult) Delivered() bool {
+	last, _ := r.Final()
+	return last.Status.IsSuccess()
+}
+
+// WaitForDelivery polls the deliveries of a message until one of them
+// ends delivery, i.e. its status IsTerminal: the message was sent, failed
+// permanently, or bounced. Soft failures, which Postal retries, and held
+// messages are waited out. Use Delivered to find out whether the message
+// was sent.
+//
+// Postal stores a message for each recipient, so to wait until every
+// recipient of a send is done, use WaitForDeliveries with the response's
+// MessageIDs.
+//
+// If ctx ends or a poll fails for good first, WaitForDelivery returns the
+// deliveries seen so far along with the error. Temporary failures are
+// retried; see WatchDeliveries.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+//	defer cancel()
+//	result, err := client.WaitForDelivery(ctx, resp.MessageID)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	if !result.Delivered() {
+//	    last, _ := result.Final()
+//	    log.Printf("not delivered: %s %s", last.Status, last.Details)
+//	}
+func (c *Client) WaitForDelivery(ctx context.Context, messageID int, opts ...WatchOption) (*DeliveryResult, error) {
+	results, err := c.WaitForDeliveries(ctx, []int{messageID}, opts...)
+	return results[0], err
+}
+
+// WaitForDeliveries is like WaitForDelivery but waits for several messages,
+// such as the messages of every recipient of a send, and returns once each
+// of them has reached a terminal delivery. The results are in the order of
+// messageIDs.
+//
+// Example:
+//
+//	results, err := client.WaitForDeliveries(ctx, resp.MessageIDs())
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for _, result := range results {
+//	    if !result.Delivered() {
+//	        last, _ := result.Final()
+//	        log.Printf("message %d not delivered: %s %s", result.MessageID, last.Status, last.Details)
+//	    }
+//	}
+func (c *Client) WaitForDeliveries(ctx context.Context, messageIDs []int, opts ...WatchOption) ([]*DeliveryResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*DeliveryResult, len(messageIDs))
+	byID := make(map[int]*DeliveryResult, len(messageIDs))
+	for i, id := range messageIDs {
+		if byID[id] == nil {
+			byID[id] = &DeliveryResult{MessageID: id}
+		}
+		results[i] = byID[id]
+	}
+
+	for event := range c.WatchDeliveries(ctx, messageIDs, opts...) {
+		if event.Err != nil {
+			return results, event.Err
+		}
+		result := byID[event.MessageID]
+		result.Deliveries = append(result.Deliveries, event.Delivery)
+	}
+	for _, result := range results {
+		if _, terminal := result.Final(); !terminal {
+			return results, fmt.Errorf("error waiting for delivery of message %d: %w", result.MessageID, ctx.Err())
+		}
+	}
+	return results, nil
+}
+
+// WatchDeliveries polls the deliveries of the given messages and sends
+// each new delivery attempt on the returned channel once, in order for
+// each message. Polling backs off while nothing changes; see
+// WithWatchInterval.
+//
+// A poll that fails for a temporary reason, such as a network failure or a
+// 503 response, is tried again after the next delay, like one that found
+// nothing. A message is no longer polled after the event for its terminal
+// delivery (see WaitForDelivery). The channel is closed once every message
+// has had one, after an event carrying the error if a poll fails for good,
+// e.g. because a message does not exist, or when ctx ends. The caller must
+// read the channel until it is closed, or cancel ctx to stop watching.
+//
+// Example:
+//
+//	for event := range client.WatchDeliveries(ctx, resp.MessageIDs()) {
+//	    if event.Err != nil {
+//	        log.Fatal(event.Err)
+//	    }
+//	    fmt.Println(event.MessageID, event.Delivery.Status, event.Delivery.Details)
+//	}
+func (c *Client) WatchDeliveries(ctx context.Context, messageIDs []int, opts ...WatchOption) <-chan DeliveryEvent {
+	o := &watchOptions{interval: DefaultWatchInterval, maxInterval: DefaultWatchMaxInterval}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	events := make(chan DeliveryEvent)
+	go func() {
+		defer close(events)
+		send := func(event DeliveryEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// Messages still to be polled, each once
+		var pending []int
+		for _, id := range messageIDs {
+			if !slices.Contains(pending, id) {
+				pending = append(pending, id)
+			}
+		}
+
+		seen := make(map[deliveryKey]bool)
+		delay := o.interval
+		for slept := false; ; slept = true {
+			changed := false
+			for i := 0; i < len(pending); {
+				messageID := pending[i]
+				deliveries, err := c.GetMessageDeliveriesContext(ctx, messageID)
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					if permanentPollError(err) {
+						send(DeliveryEvent{MessageID: messageID, Err: fmt.Errorf("error watching deliveries of message %d: %w", messageID, err)})
+						return
+					}
+					// Otherwise poll again later, as if nothing had changed
+				}
+
+				terminal := false
+				for j, d := range deliveries {
+					key := deliveryKey{messageID, d.ID}
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+					changed = true
+
+					// Only the latest attempt says where the message stands
+					terminal = d.Status.IsTerminal() && j == len(deliveries)-1
+					if !send(DeliveryEvent{MessageID: messageID, Delivery: d, Terminal: terminal}) {
+						return
+					}
+				}
+				if terminal {
+					pending = slices.Delete(pending, i, i+1)
+				} else {
+					i++
+				}
+			}
+			if len(pending) == 0 {
+				return
+			}
+
+			// Back off only once a poll after a sleep finds nothing new
+			if changed || !slept {
+				delay = o.interval
+			} else {
+				delay = min(2*delay, o.maxInterval)
+			}
+			if err := sleepContext(ctx, delay); err != nil {
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// deliveryKey identifies a delivery attempt of a message.
+type deliveryKey struct {
+	messageID, deliveryID int
+}
+
+// permanentPollError reports whether a failed poll ends a watch. Network
+// failures, rate limiting, and server errors are temporary, and the poll is
+// tried again. Other responses, including unreadable ones such as the HTML
+// error page of a wrong base URL, end the watch.
+func permanentPollError(err error) bool {
+	var (
+		apiErr       *Error
+		transportErr *TransportError
+		decodeErr    *DecodeError
+	)
+	switch {
+	case errors.As(err, &transportErr):
+		return false
+	case errors.As(err, &decodeErr):
+		return !temporaryStatus(decodeErr.StatusCode)
+	case errors.As(err, &apiErr):
+		return !temporaryStatus(apiErr.StatusCode)
+	}
+	return true
+}
+
+// temporaryStatus reports whether a response with the given HTTP status
+// code is worth polling again: 0 for none, 429, or a server error.
+func temporaryStatus(code int) bool {
+	return code == 0 || code == http.StatusTooManyRequests || code >= 500
+}
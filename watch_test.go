@@ -0,0 +1,302 @@
+package postalclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// watchTestServer serves the deliveries of message 1, and of the messages
+// in others, adding the next scripted delivery of a message on every poll
+// of it.
+type watchTestServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	script []models.DeliveryStatus
+	polls  []time.Time
+	fail   bool
+
+	// others holds the scripts of messages other than 1, and otherPolls
+	// counts their polls
+	others     map[int][]models.DeliveryStatus
+	otherPolls map[int]int
+
+	// unavailable is the number of polls still to be answered with a 503
+	unavailable int
+}
+
+// newWatchTestServer starts a watchTestServer whose deliveries take the
+// given statuses, one per poll. An empty status adds nothing on that poll.
+func newWatchTestServer(t *testing.T, statuses ...models.DeliveryStatus) (*watchTestServer, *Client) {
+	t.Helper()
+	s := &watchTestServer{script: statuses, others: make(map[int][]models.DeliveryStatus), otherPolls: make(map[int]int)}
+	// Create a test server
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.fail {
+			_, _ = w.Write([]byte(`{"status":"error","time":0.1,"flags":{},"data":{"code":"MessageNotFound","message":"no"}}`))
+			return
+		}
+		if s.unavailable > 0 {
+			s.unavailable--
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var req DeliveriesRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		script, polls := s.script, 0
+		if req.ID == 1 {
+			s.polls = append(s.polls, time.Now())
+			polls = len(s.polls)
+		} else {
+			s.otherPolls[req.ID]++
+			script, polls = s.others[req.ID], s.otherPolls[req.ID]
+		}
+
+		var deliveries []models.Delivery
+		for i, status := range script[:min(polls, len(script))] {
+			if status != "" {
+				deliveries = append(deliveries, models.Delivery{ID: i + 1, Status: status})
+			}
+		}
+		data, _ := json.Marshal(deliveries)
+		_, _ = w.Write([]byte(`{"status":"success","time":0.1,"flags":{},"data":` + string(data) + `}`))
+	}))
+	t.Cleanup(s.Close)
+
+	client, err := New("test-api-key", WithBaseURL(s.URL+"/api/v1"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return s, client
+}
+
+func TestWaitForDelivery(t *testing.T) {
+	server, client := newWatchTestServer(t, "SoftFail", "", "Held", "Sent")
+
+	result, err := client.WaitForDelivery(context.Background(), 1, WithWatchInterval(time.Millisecond, 5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(result.Deliveries) != 3 {
+		t.Fatalf("Expected 3 deliveries, got %+v", result.Deliveries)
+	}
+	for i, want := range []models.DeliveryStatus{models.StatusSoftFail, models.StatusHeld, models.StatusSent} {
+		if result.Deliveries[i].Status != want {
+			t.Errorf("Expected delivery %d to be %s, got %s", i, want, result.Deliveries[i].Status)
+		}
+	}
+
+	if !result.Delivered() {
+		t.Error("Expected the message to be delivered")
+	}
+
+	if len(server.polls) != 4 {
+		t.Errorf("Expected 4 polls, got %d", len(server.polls))
+	}
+}
+
+func TestWaitForDeliveries(t *testing.T) {
+	server, client := newWatchTestServer(t, "Sent")
+	server.others[2] = []models.DeliveryStatus{"SoftFail", "", "Sent"}
+	server.others[3] = []models.DeliveryStatus{"", "HardFail"}
+
+	// Every message is waited for, each listed once
+	results, err := client.WaitForDeliveries(context.Background(), []int{1, 2, 3, 2}, WithWatchInterval(time.Millisecond, 5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 4 || results[3] != results[1] {
+		t.Fatalf("Expected a result for each ID, got %+v", results)
+	}
+	for i, want := range []models.DeliveryStatus{models.StatusSent, models.StatusSent, models.StatusHardFail} {
+		last, terminal := results[i].Final()
+		if results[i].MessageID != i+1 || !terminal || last.Status != want {
+			t.Errorf("Expected message %d to end with %s, got %+v", i+1, want, results[i])
+		}
+	}
+	if len(results[1].Deliveries) != 2 {
+		t.Errorf("Expected 2 deliveries of message 2, got %+v", results[1].Deliveries)
+	}
+
+	// Finished messages are no longer polled
+	if len(server.polls) != 1 || server.otherPolls[2] != 3 || server.otherPolls[3] != 2 {
+		t.Errorf("Expected 1, 3, and 2 polls, got %d, %d, and %d", len(server.polls), server.otherPolls[2], server.otherPolls[3])
+	}
+}
+
+func TestWaitForDeliveryFailed(t *testing.T) {
+	for _, status := range []models.DeliveryStatus{models.StatusHardFail, models.StatusBounced} {
+		t.Run(status.String(), func(t *testing.T) {
+			_, client := newWatchTestServer(t, "SoftFail", status)
+			result, err := client.WaitForDelivery(context.Background(), 1, WithWatchInterval(time.Millisecond, time.Millisecond))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			last, terminal := result.Final()
+			if !terminal || last.Status != status || result.Delivered() {
+				t.Errorf("Expected a terminal %s delivery, got %+v", status, last)
+			}
+		})
+	}
+}
+
+func TestWaitForDeliveryContextEnds(t *testing.T) {
+	_, client := newWatchTestServer(t, "SoftFail")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := client.WaitForDelivery(ctx, 1, WithWatchInterval(time.Millisecond, 5*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if len(result.Deliveries) != 1 || result.Delivered() {
+		t.Errorf("Expected the soft failure so far, got %+v", result.Deliveries)
+	}
+}
+
+func TestWaitForDeliveryError(t *testing.T) {
+	server, client := newWatchTestServer(t)
+	server.fail = true
+
+	_, err := client.WaitForDelivery(context.Background(), 1)
+	if !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+}
+
+func TestWaitForDeliveryUnavailable(t *testing.T) {
+	server, client := newWatchTestServer(t, "SoftFail", "Sent")
+	server.unavailable = 2
+
+	// Failed polls are tried again instead of ending the watch
+	result, err := client.WaitForDelivery(context.Background(), 1, WithWatchInterval(time.Millisecond, 5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !result.Delivered() || server.unavailable != 0 {
+		t.Errorf("Expected the message to be delivered after the failed polls, got %+v", result.Deliveries)
+	}
+}
+
+func TestWaitForDeliveryWrongURL(t *testing.T) {
+	// Create a test server that answers with an HTML page, like a wrong
+	// base URL
+	var polls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		http.Error(w, "<html>Not Found</html>", http.StatusNotFound)
+	}))
+	defer server.Close()
+	client, err := New("test-api-key", WithBaseURL(server.URL+"/api/v1"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The watch ends instead of polling forever
+	_, err = client.WaitForDelivery(context.Background(), 1, WithWatchInterval(time.Millisecond, time.Millisecond))
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.StatusCode != http.StatusNotFound || polls != 1 {
+		t.Errorf("Expected a *DecodeError for the 404 after 1 poll, got %v after %d", err, polls)
+	}
+}
+
+func TestPermanentPollError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&TransportError{Err: errors.New("reset")}, false},
+		{&DecodeError{StatusCode: http.StatusServiceUnavailable}, false},
+		{&DecodeError{StatusCode: http.StatusTooManyRequests}, false},
+		{&DecodeError{}, false},
+		{&DecodeError{StatusCode: http.StatusUnauthorized}, true},
+		{&DecodeError{StatusCode: http.StatusOK}, true},
+		{&Error{StatusCode: http.StatusBadGateway}, false},
+		{&Error{StatusCode: http.StatusOK, ErrorCode: CodeMessageNotFound}, true},
+		{errors.New("other"), true},
+	}
+	for _, tt := range tests {
+		if got := permanentPollError(tt.err); got != tt.want {
+			t.Errorf("Expected permanentPollError(%#v) to be %v, got %v", tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestWatchDeliveriesBackoff(t *testing.T) {
+	server, client := newWatchTestServer(t, "SoftFail", "", "", "", "SoftFail", "", "Sent")
+
+	var events []DeliveryEvent
+	for event := range client.WatchDeliveries(context.Background(), []int{1}, WithWatchInterval(10*time.Millisecond, 30*time.Millisecond)) {
+		events = append(events, event)
+	}
+
+	if len(events) != 3 || events[0].Terminal || events[1].Terminal || !events[2].Terminal {
+		t.Fatalf("Expected 2 soft failures and a terminal delivery, got %+v", events)
+	}
+
+	// The delay doubles while nothing changes, up to the maximum, and is
+	// reset by a new delivery
+	var gaps []time.Duration
+	for i := 1; i < len(server.polls); i++ {
+		gaps = append(gaps, server.polls[i].Sub(server.polls[i-1]))
+	}
+	want := []time.Duration{10, 20, 30, 30, 10, 20}
+	if len(gaps) != len(want) {
+		t.Fatalf("Expected %d gaps, got %v", len(want), gaps)
+	}
+	for i, w := range want {
+		if gaps[i] < w*time.Millisecond {
+			t.Errorf("Expected gap %d to be at least %dms, got %v", i, w, gaps[i])
+		}
+	}
+}
+
+func TestWatchDeliveriesFirstDelay(t *testing.T) {
+	server, client := newWatchTestServer(t, "", "Sent")
+
+	for range client.WatchDeliveries(context.Background(), []int{1}, WithWatchInterval(50*time.Millisecond, time.Second)) {
+	}
+
+	// A first poll that finds nothing is followed by the initial delay
+	if len(server.polls) != 2 {
+		t.Fatalf("Expected 2 polls, got %d", len(server.polls))
+	}
+	if gap := server.polls[1].Sub(server.polls[0]); gap < 50*time.Millisecond || gap >= 90*time.Millisecond {
+		t.Errorf("Expected a gap of about 50ms, got %v", gap)
+	}
+}
+
+func TestWatchDeliveriesCancel(t *testing.T) {
+	_, client := newWatchTestServer(t, "SoftFail")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := client.WatchDeliveries(ctx, []int{1}, WithWatchInterval(time.Millisecond, time.Millisecond))
+	if event := <-events; event.Delivery.Status != "SoftFail" {
+		t.Errorf("Expected the soft failure, got %+v", event)
+	}
+
+	// Cancelling closes the channel
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			for range events {
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the channel to be closed")
+	}
+}
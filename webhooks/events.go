@@ -0,0 +1,342 @@
+// Package webhooks provides an http.Handler for receiving webhooks sent by
+// a Postal server, with typed structures for each event.
+//
+// Postal sends a webhook as a JSON POST request whose body wraps an event
+// payload in an envelope:
+//
+//	{
+//	  "event": "MessageSent",
+//	  "timestamp": 1477945177.12994,
+//	  "uuid": "1b3f5c1e-...",
+//	  "payload": { ... }
+//	}
+//
+// Basic usage:
+//
+//	handler := &webhooks.Handler{
+//	    OnMessageSent: func(ctx context.Context, e *webhooks.Event, p *webhooks.MessageStatusPayload) error {
+//	        log.Printf("message %d sent to %s", p.Message.ID, p.Message.To)
+//	        return nil
+//	    },
+//	    OnMessageBounced: func(ctx context.Context, e *webhooks.Event, p *webhooks.MessageBouncedPayload) error {
+//	        return suppress(p.OriginalMessage.To)
+//	    },
+//	}
+//	http.Handle("/webhooks/postal", handler)
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Suhaibinator/postalclient-go/models"
+)
+
+// EventType identifies the kind of event a webhook describes.
+type EventType string
+
+// Event types sent by Postal.
+const (
+	// EventMessageSent is sent when a message is delivered to the
+	// recipient's mail server.
+	EventMessageSent EventType = "MessageSent"
+
+	// EventMessageDelayed is sent when a delivery attempt fails
+	// temporarily and will be retried.
+	EventMessageDelayed EventType = "MessageDelayed"
+
+	// EventMessageDeliveryFailed is sent when a message could not be
+	// delivered and will not be retried.
+	EventMessageDeliveryFailed EventType = "MessageDeliveryFailed"
+
+	// EventMessageHeld is sent when a message is held for review.
+	EventMessageHeld EventType = "MessageHeld"
+
+	// EventMessageBounced is sent when a bounce is received for a
+	// previously sent message.
+	EventMessageBounced EventType = "MessageBounced"
+
+	// EventMessageLinkClicked is sent when a tracked link in a message
+	// is clicked.
+	EventMessageLinkClicked EventType = "MessageLinkClicked"
+
+	// EventMessageLoaded is sent when a message with tracking enabled
+	// is opened.
+	EventMessageLoaded EventType = "MessageLoaded"
+
+	// EventDomainDNSError is sent when a DNS check for a domain fails.
+	EventDomainDNSError EventType = "DomainDNSError"
+
+	// EventSendLimitApproaching is sent when a server is close to its
+	// send limit.
+	EventSendLimitApproaching EventType = "SendLimitApproaching"
+
+	// EventSendLimitExceeded is sent when a server has exceeded its
+	// send limit.
+	EventSendLimitExceeded EventType = "SendLimitExceeded"
+)
+
+// Event is a decoded webhook. Payload holds a pointer to the typed payload
+// for the event type, e.g. *MessageStatusPayload for EventMessageSent, or
+// nil for event types this package doesn't know about. The undecoded
+// payload is always available in RawPayload.
+type Event struct {
+	// Type is the kind of event, e.g. EventMessageSent.
+	Type EventType `json:"event"`
+
+	// Timestamp is when the event occurred.
+	Timestamp models.UnixTime `json:"timestamp"`
+
+	// UUID uniquely identifies this webhook request. Postal may deliver
+	// the same webhook more than once, so it can be used to deduplicate.
+	UUID string `json:"uuid"`
+
+	// RawPayload is the undecoded event payload.
+	RawPayload json.RawMessage `json:"payload"`
+
+	// Payload is the decoded event payload. See the Event documentation
+	// for the types it may hold.
+	Payload any `json:"-"`
+}
+
+// Message describes the message an event relates to. It carries more
+// fields than models.Message, since Postal includes the envelope and
+// headers of the message in webhooks.
+type Message struct {
+	// ID is the unique identifier for the message.
+	ID int `json:"id"`
+
+	// Token is a unique token that can be used to reference the message.
+	Token string `json:"token"`
+
+	// Direction is "incoming" or "outgoing".
+	Direction string `json:"direction"`
+
+	// MessageID is the value of the message's Message-ID header.
+	MessageID string `json:"message_id"`
+
+	// To is the recipient address.
+	To string `json:"to"`
+
+	// From is the sender address.
+	From string `json:"from"`
+
+	// Subject is the subject line of the message.
+	Subject string `json:"subject"`
+
+	// Timestamp is when the message was received by Postal.
+	Timestamp models.UnixTime `json:"timestamp"`
+
+	// SpamStatus is the result of the spam check, e.g. "NotSpam".
+	SpamStatus string `json:"spam_status"`
+
+	// Tag is the tag assigned to the message, if any.
+	Tag string `json:"tag"`
+}
+
+// Model returns the message as a models.Message, which can be passed to
+// code that also handles messages fetched with Client.GetMessage.
+func (m Message) Model() models.Message {
+	return models.Message{ID: m.ID, Token: m.Token}
+}
+
+// MessageStatusPayload is the payload of the MessageSent, MessageDelayed,
+// MessageDeliveryFailed, and MessageHeld events. It describes a delivery
+// attempt, using the same fields as models.Delivery.
+type MessageStatusPayload struct {
+	// Message is the message the delivery attempt was for.
+	Message Message `json:"message"`
+
+	// Status is the status of the delivery attempt, e.g.
+	// models.StatusSent, models.StatusSoftFail, models.StatusHardFail, or
+	// models.StatusHeld.
+	Status models.DeliveryStatus `json:"status"`
+
+	// Details provides additional information about the delivery attempt.
+	Details string `json:"details"`
+
+	// Output contains the raw output from the receiving mail server.
+	Output string `json:"output"`
+
+	// SentWithSSL indicates whether the delivery was made using SSL/TLS.
+	SentWithSSL bool `json:"sent_with_ssl"`
+
+	// Timestamp is when the delivery attempt was made.
+	Timestamp models.UnixTime `json:"timestamp"`
+
+	// Time is the time taken for the delivery attempt in seconds.
+	Time float64 `json:"time"`
+}
+
+// Delivery returns the delivery attempt as a models.Delivery, the type
+// returned by Client.GetMessageDeliveries.
+func (p *MessageStatusPayload) Delivery() models.Delivery {
+	return models.Delivery{
+		Status:      p.Status,
+		Details:     p.Details,
+		Output:      p.Output,
+		SentWithSSL: p.SentWithSSL,
+		Time:        p.Time,
+		Timestamp:   p.Timestamp.Time,
+	}
+}
+
+// MessageBouncedPayload is the payload of the MessageBounced event.
+type MessageBouncedPayload struct {
+	// OriginalMessage is the message that bounced.
+	OriginalMessage Message `json:"original_message"`
+
+	// Bounce is the bounce message that was received.
+	Bounce Message `json:"bounce"`
+}
+
+// MessageLinkClickedPayload is the payload of the MessageLinkClicked event.
+type MessageLinkClickedPayload struct {
+	// Message is the message containing the link.
+	Message Message `json:"message"`
+
+	// URL is the URL of the link that was clicked.
+	URL string `json:"url"`
+
+	// Token identifies the tracked link.
+	Token string `json:"token"`
+
+	// IPAddress is the IP address of the client that clicked the link.
+	IPAddress string `json:"ip_address"`
+
+	// UserAgent is the user agent of the client that clicked the link.
+	UserAgent string `json:"user_agent"`
+}
+
+// MessageLoadedPayload is the payload of the MessageLoaded event.
+type MessageLoadedPayload struct {
+	// Message is the message that was opened.
+	Message Message `json:"message"`
+
+	// IPAddress is the IP address of the client that opened the message.
+	IPAddress string `json:"ip_address"`
+
+	// UserAgent is the user agent of the client that opened the message.
+	UserAgent string `json:"user_agent"`
+}
+
+// Organization identifies a Postal organization.
+type Organization struct {
+	// UUID is the unique identifier for the organization.
+	UUID string `json:"uuid"`
+
+	// Name is the name of the organization.
+	Name string `json:"name"`
+
+	// Permalink is the URL-safe short name of the organization.
+	Permalink string `json:"permalink"`
+}
+
+// Server identifies a Postal mail server.
+type Server struct {
+	// UUID is the unique identifier for the server.
+	UUID string `json:"uuid"`
+
+	// Name is the name of the server.
+	Name string `json:"name"`
+
+	// Permalink is the URL-safe short name of the server.
+	Permalink string `json:"permalink"`
+
+	// Organization is the organization the server belongs to.
+	Organization Organization `json:"organization"`
+}
+
+// DomainDNSErrorPayload is the payload of the DomainDNSError event.
+// Each status is "OK", "Missing", or "Invalid", with the matching error
+// field explaining any problem.
+type DomainDNSErrorPayload struct {
+	// Domain is the domain name that failed its DNS check.
+	Domain string `json:"domain"`
+
+	// UUID is the unique identifier for the domain.
+	UUID string `json:"uuid"`
+
+	// DNSCheckedAt is when the DNS check was performed.
+	DNSCheckedAt models.UnixTime `json:"dns_checked_at"`
+
+	// SPFStatus is the status of the SPF record.
+	SPFStatus string `json:"spf_status"`
+
+	// SPFError describes the problem with the SPF record, if any.
+	SPFError string `json:"spf_error"`
+
+	// DKIMStatus is the status of the DKIM record.
+	DKIMStatus string `json:"dkim_status"`
+
+	// DKIMError describes the problem with the DKIM record, if any.
+	DKIMError string `json:"dkim_error"`
+
+	// MXStatus is the status of the MX records.
+	MXStatus string `json:"mx_status"`
+
+	// MXError describes the problem with the MX records, if any.
+	MXError string `json:"mx_error"`
+
+	// ReturnPathStatus is the status of the return path record.
+	ReturnPathStatus string `json:"return_path_status"`
+
+	// ReturnPathError describes the problem with the return path record,
+	// if any.
+	ReturnPathError string `json:"return_path_error"`
+
+	// Server is the server the domain belongs to.
+	Server Server `json:"server"`
+}
+
+// SendLimitPayload is the payload of the SendLimitApproaching and
+// SendLimitExceeded events.
+type SendLimitPayload struct {
+	// Server is the server approaching or exceeding its limit.
+	Server Server `json:"server"`
+
+	// Volume is the number of messages sent in the current period.
+	Volume int `json:"volume"`
+
+	// Limit is the server's send limit for the period.
+	Limit int `json:"limit"`
+}
+
+// ParseEvent decodes a webhook request body into an Event, including its
+// typed payload. Unknown event types are not an error: their Payload is
+// nil and RawPayload holds the undecoded payload.
+func ParseEvent(body []byte) (*Event, error) {
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("error unmarshaling webhook: %w", err)
+	}
+	if event.Type == "" {
+		return nil, fmt.Errorf("webhook has no event type")
+	}
+
+	// Pick the payload type for the event
+	var payload any
+	switch event.Type {
+	case EventMessageSent, EventMessageDelayed, EventMessageDeliveryFailed, EventMessageHeld:
+		payload = &MessageStatusPayload{}
+	case EventMessageBounced:
+		payload = &MessageBouncedPayload{}
+	case EventMessageLinkClicked:
+		payload = &MessageLinkClickedPayload{}
+	case EventMessageLoaded:
+		payload = &MessageLoadedPayload{}
+	case EventDomainDNSError:
+		payload = &DomainDNSErrorPayload{}
+	case EventSendLimitApproaching, EventSendLimitExceeded:
+		payload = &SendLimitPayload{}
+	default:
+		return &event, nil
+	}
+
+	if err := json.Unmarshal(event.RawPayload, payload); err != nil {
+		return nil, fmt.Errorf("error unmarshaling %s payload: %w", event.Type, err)
+	}
+	event.Payload = payload
+
+	return &event, nil
+}
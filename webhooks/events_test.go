@@ -0,0 +1,155 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMessageSent(t *testing.T) {
+	body := []byte(`{
+		"event": "MessageSent",
+		"timestamp": 1477945177.12994,
+		"uuid": "7a5a2f4c-1d2e-4e5f-8a9b-0c1d2e3f4a5b",
+		"payload": {
+			"message": {
+				"id": 12345,
+				"token": "abcdef123",
+				"direction": "outgoing",
+				"message_id": "5817a64332f44_4ec93ff59e79d154565eb@app34.mail",
+				"to": "test@example.com",
+				"from": "sales@awesomeapp.com",
+				"subject": "Welcome to AwesomeApp",
+				"timestamp": 1477945177.12994,
+				"spam_status": "NotSpam",
+				"tag": "welcome"
+			},
+			"status": "Sent",
+			"details": "Message for test@example.com accepted by 1.2.3.4:25 (mx.example.com)",
+			"output": "250 2.0.0 OK",
+			"sent_with_ssl": true,
+			"timestamp": 1477945179.12994,
+			"time": 0.12
+		}
+	}`)
+
+	event, err := ParseEvent(body)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if event.Type != EventMessageSent {
+		t.Errorf("Expected event type to be MessageSent, got %s", event.Type)
+	}
+
+	if event.UUID != "7a5a2f4c-1d2e-4e5f-8a9b-0c1d2e3f4a5b" {
+		t.Errorf("Expected UUID to be set, got %s", event.UUID)
+	}
+
+	payload, ok := event.Payload.(*MessageStatusPayload)
+	if !ok {
+		t.Fatalf("Expected payload to be *MessageStatusPayload, got %T", event.Payload)
+	}
+
+	if payload.Message.ID != 12345 || payload.Message.To != "test@example.com" || payload.Message.Tag != "welcome" {
+		t.Errorf("Unexpected message: %+v", payload.Message)
+	}
+
+	// Check conversion to the models types
+	delivery := payload.Delivery()
+	if delivery.Status != "Sent" || delivery.Output != "250 2.0.0 OK" || !delivery.SentWithSSL {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+
+	if !delivery.Timestamp.Equal(time.Date(2016, 10, 31, 20, 19, 39, 129940000, time.UTC)) {
+		t.Errorf("Unexpected delivery timestamp: %s", delivery.Timestamp)
+	}
+
+	if message := payload.Message.Model(); message.ID != 12345 || message.Token != "abcdef123" {
+		t.Errorf("Unexpected model message: %+v", message)
+	}
+}
+
+func TestParseEventPayloadTypes(t *testing.T) {
+	tests := []struct {
+		event   EventType
+		payload string
+		check   func(t *testing.T, payload any)
+	}{
+		{EventMessageBounced, `{"original_message": {"id": 1, "to": "a@example.com"}, "bounce": {"id": 2}}`, func(t *testing.T, payload any) {
+			p := payload.(*MessageBouncedPayload)
+			if p.OriginalMessage.ID != 1 || p.Bounce.ID != 2 || p.OriginalMessage.To != "a@example.com" {
+				t.Errorf("Unexpected payload: %+v", p)
+			}
+		}},
+		{EventMessageLinkClicked, `{"url": "https://example.com", "token": "VJzsFA0S", "ip_address": "127.0.0.1", "user_agent": "Mozilla", "message": {"id": 1}}`, func(t *testing.T, payload any) {
+			p := payload.(*MessageLinkClickedPayload)
+			if p.URL != "https://example.com" || p.IPAddress != "127.0.0.1" || p.Message.ID != 1 {
+				t.Errorf("Unexpected payload: %+v", p)
+			}
+		}},
+		{EventMessageLoaded, `{"ip_address": "127.0.0.1", "user_agent": "Mozilla", "message": {"id": 1}}`, func(t *testing.T, payload any) {
+			p := payload.(*MessageLoadedPayload)
+			if p.UserAgent != "Mozilla" || p.Message.ID != 1 {
+				t.Errorf("Unexpected payload: %+v", p)
+			}
+		}},
+		{EventDomainDNSError, `{"domain": "example.com", "dns_checked_at": 1477945711.5502, "spf_status": "OK", "dkim_status": "Invalid", "dkim_error": "The DKIM record is not valid", "server": {"uuid": "54529725", "name": "Main", "permalink": "main", "organization": {"name": "Acme", "permalink": "acme"}}}`, func(t *testing.T, payload any) {
+			p := payload.(*DomainDNSErrorPayload)
+			if p.Domain != "example.com" || p.DKIMStatus != "Invalid" || p.Server.Organization.Permalink != "acme" {
+				t.Errorf("Unexpected payload: %+v", p)
+			}
+			if p.DNSCheckedAt.IsZero() {
+				t.Error("Expected DNSCheckedAt to be set")
+			}
+		}},
+		{EventSendLimitApproaching, `{"server": {"name": "Main"}, "volume": 90, "limit": 100}`, func(t *testing.T, payload any) {
+			p := payload.(*SendLimitPayload)
+			if p.Volume != 90 || p.Limit != 100 || p.Server.Name != "Main" {
+				t.Errorf("Unexpected payload: %+v", p)
+			}
+		}},
+		{EventSendLimitExceeded, `{"server": {"name": "Main"}, "volume": 101, "limit": 100}`, func(t *testing.T, payload any) {
+			if p := payload.(*SendLimitPayload); p.Volume != 101 {
+				t.Errorf("Unexpected payload: %+v", p)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		event, err := ParseEvent([]byte(`{"event": "` + string(tt.event) + `", "payload": ` + tt.payload + `}`))
+		if err != nil {
+			t.Errorf("Expected no error for %s, got %v", tt.event, err)
+			continue
+		}
+		tt.check(t, event.Payload)
+	}
+}
+
+func TestParseEventUnknownType(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"event": "SomethingNew", "payload": {"x": 1}}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if event.Payload != nil {
+		t.Errorf("Expected payload to be nil, got %T", event.Payload)
+	}
+
+	if string(event.RawPayload) != `{"x": 1}` {
+		t.Errorf("Expected raw payload to be kept, got %s", event.RawPayload)
+	}
+}
+
+func TestParseEventErrors(t *testing.T) {
+	bodies := []string{
+		`not json`,
+		`{"payload": {}}`,
+		`{"event": "MessageSent", "payload": {"message": "not an object"}}`,
+	}
+
+	for _, body := range bodies {
+		if _, err := ParseEvent([]byte(body)); err == nil {
+			t.Errorf("Expected error for %s, got nil", body)
+		}
+	}
+}
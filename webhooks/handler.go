@@ -0,0 +1,167 @@
+// This file contains the http.Handler that receives webhooks and
+// dispatches them to per-event callbacks.
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxBodyBytes is the default limit on the size of a webhook body.
+const DefaultMaxBodyBytes = 1 << 20
+
+// Handler is an http.Handler that decodes Postal webhooks and dispatches
+// them to the callback for their event type.
+//
+// The handler responds with 200 OK when the callback succeeds or when no
+// callback is set for the event type, so Postal doesn't retry webhooks the
+// application doesn't care about. It responds with 400 Bad Request if the
+// body can't be decoded, and with 500 Internal Server Error if the
+// callback returns an error, which makes Postal retry the webhook later.
+//
+// Callbacks may be called concurrently.
+type Handler struct {
+	// OnEvent, if set, is called for every event before the typed
+	// callback, including event types this package doesn't know about.
+	// If it returns an error, the typed callback is not called.
+	OnEvent func(ctx context.Context, event *Event) error
+
+	// OnMessageSent is called for MessageSent events.
+	OnMessageSent func(ctx context.Context, event *Event, payload *MessageStatusPayload) error
+
+	// OnMessageDelayed is called for MessageDelayed events.
+	OnMessageDelayed func(ctx context.Context, event *Event, payload *MessageStatusPayload) error
+
+	// OnMessageDeliveryFailed is called for MessageDeliveryFailed events.
+	OnMessageDeliveryFailed func(ctx context.Context, event *Event, payload *MessageStatusPayload) error
+
+	// OnMessageHeld is called for MessageHeld events.
+	OnMessageHeld func(ctx context.Context, event *Event, payload *MessageStatusPayload) error
+
+	// OnMessageBounced is called for MessageBounced events.
+	OnMessageBounced func(ctx context.Context, event *Event, payload *MessageBouncedPayload) error
+
+	// OnMessageLinkClicked is called for MessageLinkClicked events.
+	OnMessageLinkClicked func(ctx context.Context, event *Event, payload *MessageLinkClickedPayload) error
+
+	// OnMessageLoaded is called for MessageLoaded events.
+	OnMessageLoaded func(ctx context.Context, event *Event, payload *MessageLoadedPayload) error
+
+	// OnDomainDNSError is called for DomainDNSError events.
+	OnDomainDNSError func(ctx context.Context, event *Event, payload *DomainDNSErrorPayload) error
+
+	// OnSendLimitApproaching is called for SendLimitApproaching events.
+	OnSendLimitApproaching func(ctx context.Context, event *Event, payload *SendLimitPayload) error
+
+	// OnSendLimitExceeded is called for SendLimitExceeded events.
+	OnSendLimitExceeded func(ctx context.Context, event *Event, payload *SendLimitPayload) error
+
+	// OnError, if set, is called when a webhook can't be decoded or a
+	// callback returns an error. It can be used for logging.
+	OnError func(r *http.Request, err error)
+
+	// MaxBodyBytes limits the size of a webhook body.
+	// If zero, DefaultMaxBodyBytes is used.
+	MaxBodyBytes int64
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Read the body, refusing anything unreasonably large
+	limit := h.MaxBodyBytes
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		h.reportError(r, err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "webhook body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "error reading webhook body", http.StatusBadRequest)
+		return
+	}
+
+	// Decode the event and its payload
+	event, err := ParseEvent(body)
+	if err != nil {
+		h.reportError(r, err)
+		http.Error(w, "invalid webhook", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Dispatch(r.Context(), event); err != nil {
+		h.reportError(r, err)
+		http.Error(w, "error handling webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Dispatch calls OnEvent and then the typed callback for the event's type.
+// It can be used to process events that were received some other way,
+// for example from a queue. Events without a callback are ignored.
+func (h *Handler) Dispatch(ctx context.Context, event *Event) error {
+	if h.OnEvent != nil {
+		if err := h.OnEvent(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	switch payload := event.Payload.(type) {
+	case *MessageStatusPayload:
+		var callback func(context.Context, *Event, *MessageStatusPayload) error
+		switch event.Type {
+		case EventMessageSent:
+			callback = h.OnMessageSent
+		case EventMessageDelayed:
+			callback = h.OnMessageDelayed
+		case EventMessageDeliveryFailed:
+			callback = h.OnMessageDeliveryFailed
+		case EventMessageHeld:
+			callback = h.OnMessageHeld
+		}
+		return call(ctx, event, payload, callback)
+	case *MessageBouncedPayload:
+		return call(ctx, event, payload, h.OnMessageBounced)
+	case *MessageLinkClickedPayload:
+		return call(ctx, event, payload, h.OnMessageLinkClicked)
+	case *MessageLoadedPayload:
+		return call(ctx, event, payload, h.OnMessageLoaded)
+	case *DomainDNSErrorPayload:
+		return call(ctx, event, payload, h.OnDomainDNSError)
+	case *SendLimitPayload:
+		if event.Type == EventSendLimitExceeded {
+			return call(ctx, event, payload, h.OnSendLimitExceeded)
+		}
+		return call(ctx, event, payload, h.OnSendLimitApproaching)
+	}
+
+	return nil
+}
+
+// call invokes callback with the event and payload if it is set.
+func call[P any](ctx context.Context, event *Event, payload P, callback func(context.Context, *Event, P) error) error {
+	if callback == nil {
+		return nil
+	}
+	return callback(ctx, event, payload)
+}
+
+// reportError passes err to the OnError hook if it is set.
+func (h *Handler) reportError(r *http.Request, err error) {
+	if h.OnError != nil {
+		h.OnError(r, err)
+	}
+}
@@ -0,0 +1,166 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const messageSentBody = `{"event": "MessageSent", "uuid": "abc", "payload": {"message": {"id": 1}, "status": "Sent"}}`
+
+func TestHandlerDispatchesToCallback(t *testing.T) {
+	var sent, events int
+	handler := &Handler{
+		OnEvent: func(ctx context.Context, event *Event) error {
+			events++
+			return nil
+		},
+		OnMessageSent: func(ctx context.Context, event *Event, payload *MessageStatusPayload) error {
+			sent++
+			if payload.Message.ID != 1 {
+				t.Errorf("Expected message ID to be 1, got %d", payload.Message.ID)
+			}
+			return nil
+		},
+		OnMessageDelayed: func(ctx context.Context, event *Event, payload *MessageStatusPayload) error {
+			t.Error("Expected OnMessageDelayed not to be called")
+			return nil
+		},
+	}
+
+	// Send a webhook
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(messageSentBody))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status code to be 200, got %d", rec.Code)
+	}
+
+	if sent != 1 || events != 1 {
+		t.Errorf("Expected callbacks to be called once, got sent=%d events=%d", sent, events)
+	}
+}
+
+func TestHandlerDispatchesEveryEventType(t *testing.T) {
+	called := map[EventType]bool{}
+	record := func(event *Event) error {
+		called[event.Type] = true
+		return nil
+	}
+	handler := &Handler{
+		OnMessageSent:           func(ctx context.Context, e *Event, p *MessageStatusPayload) error { return record(e) },
+		OnMessageDelayed:        func(ctx context.Context, e *Event, p *MessageStatusPayload) error { return record(e) },
+		OnMessageDeliveryFailed: func(ctx context.Context, e *Event, p *MessageStatusPayload) error { return record(e) },
+		OnMessageHeld:           func(ctx context.Context, e *Event, p *MessageStatusPayload) error { return record(e) },
+		OnMessageBounced:        func(ctx context.Context, e *Event, p *MessageBouncedPayload) error { return record(e) },
+		OnMessageLinkClicked:    func(ctx context.Context, e *Event, p *MessageLinkClickedPayload) error { return record(e) },
+		OnMessageLoaded:         func(ctx context.Context, e *Event, p *MessageLoadedPayload) error { return record(e) },
+		OnDomainDNSError:        func(ctx context.Context, e *Event, p *DomainDNSErrorPayload) error { return record(e) },
+		OnSendLimitApproaching:  func(ctx context.Context, e *Event, p *SendLimitPayload) error { return record(e) },
+		OnSendLimitExceeded:     func(ctx context.Context, e *Event, p *SendLimitPayload) error { return record(e) },
+	}
+
+	types := []EventType{
+		EventMessageSent, EventMessageDelayed, EventMessageDeliveryFailed, EventMessageHeld,
+		EventMessageBounced, EventMessageLinkClicked, EventMessageLoaded, EventDomainDNSError,
+		EventSendLimitApproaching, EventSendLimitExceeded,
+	}
+	for _, eventType := range types {
+		event, err := ParseEvent([]byte(`{"event": "` + string(eventType) + `", "payload": {}}`))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := handler.Dispatch(context.Background(), event); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if !called[eventType] {
+			t.Errorf("Expected callback for %s to be called", eventType)
+		}
+	}
+}
+
+func TestHandlerIgnoresUnhandledEvents(t *testing.T) {
+	handler := &Handler{}
+
+	for _, body := range []string{messageSentBody, `{"event": "SomethingNew", "payload": {}}`} {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status code to be 200, got %d", rec.Code)
+		}
+	}
+}
+
+func TestHandlerCallbackError(t *testing.T) {
+	var reported error
+	handler := &Handler{
+		OnMessageSent: func(ctx context.Context, event *Event, payload *MessageStatusPayload) error {
+			return errors.New("database unavailable")
+		},
+		OnError: func(r *http.Request, err error) {
+			reported = err
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(messageSentBody))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code to be 500, got %d", rec.Code)
+	}
+
+	if reported == nil || reported.Error() != "database unavailable" {
+		t.Errorf("Expected error to be reported, got %v", reported)
+	}
+}
+
+func TestHandlerOnEventErrorSkipsCallback(t *testing.T) {
+	handler := &Handler{
+		OnEvent: func(ctx context.Context, event *Event) error {
+			return errors.New("duplicate")
+		},
+		OnMessageSent: func(ctx context.Context, event *Event, payload *MessageStatusPayload) error {
+			t.Error("Expected OnMessageSent not to be called")
+			return nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(messageSentBody))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code to be 500, got %d", rec.Code)
+	}
+}
+
+func TestHandlerRejectsBadRequests(t *testing.T) {
+	handler := &Handler{MaxBodyBytes: 64}
+
+	tests := []struct {
+		method   string
+		body     string
+		expected int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "not json", http.StatusBadRequest},
+		{http.MethodPost, `{"event": "MessageSent", "payload": {"message": {"subject": "` + strings.Repeat("x", 100) + `"}}}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/webhooks", strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.expected {
+			t.Errorf("Expected status code for %s %q to be %d, got %d", tt.method, tt.body, tt.expected, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package webhooks
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec // Postal's original signature header uses RSA-SHA1
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	testKeysOnce sync.Once
+	testKeys     [2]*rsa.PrivateKey
+)
+
+// testKey returns one of two RSA keys generated once per test run.
+func testKey(t *testing.T, i int) *rsa.PrivateKey {
+	t.Helper()
+	testKeysOnce.Do(func() {
+		for j := range testKeys {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				panic(err)
+			}
+			testKeys[j] = key
+		}
+	})
+	return testKeys[i]
+}
+
+// sign returns the base64-encoded signature of body with key.
+func sign(t *testing.T, key *rsa.PrivateKey, hash crypto.Hash, body []byte) string {
+	t.Helper()
+	var digest []byte
+	if hash == crypto.SHA256 {
+		sum := sha256.Sum256(body)
+		digest = sum[:]
+	} else {
+		sum := sha1.Sum(body) //nolint:gosec // required by Postal's signature format
+		digest = sum[:]
+	}
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+	if err != nil {
+		t.Fatalf("Error signing body: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func TestParsePublicKeyPEM(t *testing.T) {
+	key := testKey(t, 0)
+
+	pkix, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Error marshaling key: %v", err)
+	}
+
+	blocks := []*pem.Block{
+		{Type: "PUBLIC KEY", Bytes: pkix},
+		{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)},
+	}
+	for _, block := range blocks {
+		parsed, err := ParsePublicKeyPEM(pem.EncodeToMemory(block))
+		if err != nil {
+			t.Errorf("Expected no error for %s, got %v", block.Type, err)
+			continue
+		}
+		if !parsed.Equal(&key.PublicKey) {
+			t.Errorf("Expected parsed %s key to match", block.Type)
+		}
+	}
+
+	// Invalid inputs
+	invalid := [][]byte{
+		[]byte("not pem"),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pkix}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("garbage")}),
+	}
+	for _, data := range invalid {
+		if _, err := ParsePublicKeyPEM(data); err == nil {
+			t.Errorf("Expected error for %q, got nil", data)
+		}
+	}
+}
+
+func TestParsePublicKeyTXT(t *testing.T) {
+	key := testKey(t, 0)
+
+	pkix, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Error marshaling key: %v", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(pkix)
+
+	records := []string{
+		"v=DKIM1; t=s; h=sha256; p=" + encoded,
+		"p=" + encoded,
+		encoded,
+		`"v=DKIM1; t=s; h=sha256; p=` + encoded[:100] + `" "` + encoded[100:] + `"`,
+		"v=DKIM1; p=" + encoded[:100] + "\n  " + encoded[100:],
+		base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PublicKey(&key.PublicKey)),
+	}
+	for _, record := range records {
+		parsed, err := ParsePublicKeyTXT(record)
+		if err != nil {
+			t.Errorf("Expected no error for %q, got %v", record, err)
+			continue
+		}
+		if !parsed.Equal(&key.PublicKey) {
+			t.Errorf("Expected parsed key to match for %q", record)
+		}
+	}
+
+	// Invalid inputs
+	invalid := []string{
+		"v=DKIM1; t=s",
+		"p=!!!",
+		base64.StdEncoding.EncodeToString([]byte("garbage")),
+	}
+	for _, record := range invalid {
+		if _, err := ParsePublicKeyTXT(record); err == nil {
+			t.Errorf("Expected error for %q, got nil", record)
+		}
+	}
+}
+
+func TestVerifierVerify(t *testing.T) {
+	key := testKey(t, 0)
+	other := testKey(t, 1)
+	body := []byte(messageSentBody)
+	verifier := NewVerifier(&key.PublicKey)
+
+	// Valid signatures with either hash
+	for _, tt := range []struct {
+		header string
+		hash   crypto.Hash
+	}{
+		{SignatureHeader, crypto.SHA1},
+		{SignatureSHA256Header, crypto.SHA256},
+	} {
+		header := http.Header{}
+		header.Set(tt.header, sign(t, key, tt.hash, body))
+		if err := verifier.Verify(body, header); err != nil {
+			t.Errorf("Expected valid %s signature, got %v", tt.header, err)
+		}
+	}
+
+	// Missing signature
+	if err := verifier.Verify(body, http.Header{}); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("Expected ErrMissingSignature, got %v", err)
+	}
+
+	// Tampered body
+	header := http.Header{}
+	header.Set(SignatureHeader, sign(t, key, crypto.SHA1, body))
+	if err := verifier.Verify([]byte(strings.Replace(messageSentBody, "Sent", "Held", 1)), header); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for tampered body, got %v", err)
+	}
+
+	// Signature from an unknown key
+	header.Set(SignatureHeader, sign(t, other, crypto.SHA1, body))
+	if err := verifier.Verify(body, header); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for unknown key, got %v", err)
+	}
+
+	// Malformed signature
+	header.Set(SignatureHeader, "not base64!")
+	if err := verifier.Verify(body, header); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for malformed signature, got %v", err)
+	}
+
+	// No keys
+	if err := NewVerifier().Verify(body, header); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Expected ErrNoKeys, got %v", err)
+	}
+}
+
+func TestVerifierKeyRotation(t *testing.T) {
+	oldKey := testKey(t, 0)
+	newKey := testKey(t, 1)
+	body := []byte(messageSentBody)
+
+	// Both keys are accepted during rotation
+	verifier := NewVerifier(&oldKey.PublicKey, &newKey.PublicKey)
+	for _, key := range []*rsa.PrivateKey{oldKey, newKey} {
+		header := http.Header{}
+		header.Set(SignatureHeader, sign(t, key, crypto.SHA1, body))
+		if err := verifier.Verify(body, header); err != nil {
+			t.Errorf("Expected valid signature, got %v", err)
+		}
+	}
+
+	// Once rotation is done, the old key is rejected
+	verifier.SetKeys(&newKey.PublicKey)
+	header := http.Header{}
+	header.Set(SignatureHeader, sign(t, oldKey, crypto.SHA1, body))
+	if err := verifier.Verify(body, header); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+
+	if keys := verifier.Keys(); len(keys) != 1 {
+		t.Errorf("Expected 1 key, got %d", len(keys))
+	}
+}
+
+func TestVerifierMiddleware(t *testing.T) {
+	key := testKey(t, 0)
+	body := messageSentBody
+
+	// The wrapped handler must see the original body
+	var received string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = string(data)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var rejected []error
+	verifier := NewVerifier(&key.PublicKey)
+	verifier.OnError = func(r *http.Request, err error) {
+		rejected = append(rejected, err)
+	}
+	handler := verifier.Middleware(next)
+
+	// Signed request passes through
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+	req.Header.Set(SignatureHeader, sign(t, key, crypto.SHA1, []byte(body)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status code to be 200, got %d", rec.Code)
+	}
+
+	if received != body {
+		t.Errorf("Expected next handler to receive the body, got %q", received)
+	}
+
+	// Unsigned request is rejected
+	req = httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code to be 401, got %d", rec.Code)
+	}
+
+	if len(rejected) != 1 || !errors.Is(rejected[0], ErrMissingSignature) {
+		t.Errorf("Expected ErrMissingSignature to be reported, got %v", rejected)
+	}
+
+	// Oversized request is rejected
+	verifier.MaxBodyBytes = 8
+	req = httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status code to be 413, got %d", rec.Code)
+	}
+}
+
+func TestVerifierMiddlewareWithHandler(t *testing.T) {
+	key := testKey(t, 0)
+	body := messageSentBody
+
+	var sent int
+	handler := NewVerifier(&key.PublicKey).Middleware(&Handler{
+		OnMessageSent: func(ctx context.Context, event *Event, payload *MessageStatusPayload) error {
+			sent++
+			return nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+	req.Header.Set(SignatureSHA256Header, sign(t, key, crypto.SHA256, []byte(body)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || sent != 1 {
+		t.Errorf("Expected webhook to be handled, got status %d and %d calls", rec.Code, sent)
+	}
+}